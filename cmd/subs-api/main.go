@@ -8,6 +8,7 @@ import (
 	"github.com/azaliaz/subs-api/internal/storage"
 	"github.com/azaliaz/subs-api/pkg/config"
 	"github.com/azaliaz/subs-api/pkg/service"
+	"github.com/azaliaz/subs-api/pkg/tracing"
 	"log/slog"
 	"os"
 )
@@ -16,6 +17,7 @@ type Config struct {
 	App     application.Config `envPrefix:"APP_" yaml:"app"`
 	Storage storage.Config     `envPrefix:"STORAGE_" yaml:"storage"`
 	Rest    rest.Config        `envPrefix:"REST_" yaml:"rest"`
+	Tracing tracing.Config     `envPrefix:"TRACING_" yaml:"tracing"`
 }
 
 func main() {
@@ -33,19 +35,22 @@ func main() {
 	err := config.ReadConfig(*configFile, &cfg)
 	if err != nil {
 		logger.Error("config parse error:", "err_msg", err)
-		return
+		os.Exit(1)
 	}
 
+	tracer := tracing.NewProvider(&cfg.Tracing, logger)
 	db := storage.NewDB(&cfg.Storage, logger)
 	repo := storage.NewService(db, logger)
 	app := application.NewService(logger, &cfg.App, repo)
 	api := rest.NewAPI(logger, &cfg.Rest, app)
 
 	mgr := service.NewManager(logger)
-	mgr.AddService(db, app, api)
+	mgr.AddService(tracer, db, app, api)
+	api.WithHealthChecker(mgr)
 
 	ctx := context.Background()
 	if err := mgr.Run(ctx); err != nil {
 		logger.Error("can't start services:", slog.String("err", err.Error()))
+		os.Exit(1)
 	}
 }
@@ -0,0 +1,204 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/azaliaz/subs-api/pkg/tracing"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+type Principal struct {
+	Subject uuid.UUID
+	Role    string
+}
+
+func (p *Principal) IsAdmin() bool {
+	return p != nil && p.Role == RoleAdmin
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok && principal != nil
+}
+
+type tokenClaims struct {
+	Role string `json:"role,omitempty"`
+	jwt.RegisteredClaims
+}
+
+func (s *Service) Authenticate(ctx context.Context, token string) (_ *Principal, err error) {
+	_, span := tracer.Start(ctx, "application.Authenticate")
+	defer func() { tracing.End(span, err) }()
+
+	log := s.requestLogger(ctx)
+
+	if s.config == nil || s.config.Secret == "" {
+		log.Error("authentication secret is not configured")
+		return nil, fmt.Errorf("%w: authentication is not configured", ErrUnauthorized)
+	}
+	if token == "" {
+		return nil, fmt.Errorf("%w: token is required", ErrUnauthorized)
+	}
+
+	var claims tokenClaims
+	_, err = jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (interface{}, error) {
+		return []byte(s.config.Secret), nil
+	}, jwt.WithValidMethods([]string{
+		jwt.SigningMethodHS256.Alg(),
+		jwt.SigningMethodHS384.Alg(),
+		jwt.SigningMethodHS512.Alg(),
+	}), jwt.WithExpirationRequired())
+	if err != nil {
+		log.Warn("invalid token in application layer", "error", err)
+		return nil, fmt.Errorf("%w: %w", ErrUnauthorized, err)
+	}
+
+	subject, err := uuid.Parse(claims.Subject)
+	if err != nil || subject == uuid.Nil {
+		log.Warn("invalid token subject in application layer", "sub", claims.Subject)
+		return nil, fmt.Errorf("%w: token subject must be a user id", ErrUnauthorized)
+	}
+
+	role := claims.Role
+	if role == "" {
+		role = RoleUser
+	}
+	if role != RoleUser && role != RoleAdmin {
+		log.Warn("unknown role in token", "role", role)
+		return nil, fmt.Errorf("%w: unknown role %q", ErrUnauthorized, role)
+	}
+
+	return &Principal{Subject: subject, Role: role}, nil
+}
+
+// principal returns the caller of a service method. Every public method is
+// reached through an authenticated request, so a missing principal is an
+// error rather than an internal call; background jobs use storage directly.
+func (s *Service) principal(ctx context.Context) (*Principal, error) {
+	principal, ok := PrincipalFromContext(ctx)
+	if !ok {
+		s.requestLogger(ctx).Warn("no principal in application layer")
+		return nil, fmt.Errorf("%w: no principal in context", ErrUnauthorized)
+	}
+	return principal, nil
+}
+
+// authorize checks that the caller may access rows owned by userID.
+func (s *Service) authorize(ctx context.Context, userID uuid.UUID) error {
+	log := s.requestLogger(ctx)
+
+	principal, err := s.principal(ctx)
+	if err != nil {
+		return err
+	}
+	if principal.IsAdmin() || principal.Subject == userID {
+		return nil
+	}
+	log.Warn("access denied in application layer", "sub", principal.Subject, "user_id", userID)
+	return ErrForbidden
+}
+
+// scopeUserID narrows a user_id filter to the caller's own rows unless the
+// caller is an admin.
+func (s *Service) scopeUserID(ctx context.Context, userID *uuid.UUID) (*uuid.UUID, error) {
+	log := s.requestLogger(ctx)
+
+	principal, err := s.principal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if principal.IsAdmin() {
+		return userID, nil
+	}
+	if userID != nil && *userID != uuid.Nil && *userID != principal.Subject {
+		log.Warn("access denied in application layer", "sub", principal.Subject, "user_id", *userID)
+		return nil, ErrForbidden
+	}
+	subject := principal.Subject
+	return &subject, nil
+}
+
+// scopeUserIDs is scopeUserID for a filter on several users: a caller who is
+// not an admin may only name themselves.
+func (s *Service) scopeUserIDs(ctx context.Context, userIDs []uuid.UUID) ([]uuid.UUID, error) {
+	log := s.requestLogger(ctx)
+
+	principal, err := s.principal(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if principal.IsAdmin() {
+		return userIDs, nil
+	}
+	for _, userID := range userIDs {
+		if userID != principal.Subject {
+			log.Warn("access denied in application layer", "sub", principal.Subject, "user_id", userID)
+			return nil, ErrForbidden
+		}
+	}
+	return []uuid.UUID{principal.Subject}, nil
+}
+
+// authorizeOwner checks that the caller may access the subscription with the
+// given id owned by userID. Subscriptions of other users are reported as not
+// found, so their ids cannot be probed.
+func (s *Service) authorizeOwner(ctx context.Context, id, userID uuid.UUID) error {
+	err := s.authorize(ctx, userID)
+	if errors.Is(err, ErrForbidden) {
+		return fmt.Errorf("%w: subscription with id %s", ErrNotFound, id)
+	}
+	return err
+}
+
+// authorizeSubscription checks that the caller owns the subscription with the
+// given id. Missing subscriptions are left for the caller to report.
+func (s *Service) authorizeSubscription(ctx context.Context, id uuid.UUID) error {
+	log := s.requestLogger(ctx)
+
+	principal, err := s.principal(ctx)
+	if err != nil {
+		return err
+	}
+	if principal.IsAdmin() {
+		return nil
+	}
+
+	sub, err := s.db.GetInfo(ctx, id)
+	if err != nil {
+		log.Error("failed to get subscription owner in storage layer", "error", err)
+		return fmt.Errorf("failed to get subscription info: %w", err)
+	}
+	if sub == nil {
+		return nil
+	}
+
+	return s.authorizeOwner(ctx, id, sub.UserID)
+}
+
+// requireAdmin restricts shared data, such as exchange rates, to admins.
+func (s *Service) requireAdmin(ctx context.Context) error {
+	log := s.requestLogger(ctx)
+
+	principal, err := s.principal(ctx)
+	if err != nil {
+		return err
+	}
+	if principal.IsAdmin() {
+		return nil
+	}
+	log.Warn("admin role required in application layer", "sub", principal.Subject)
+	return ErrForbidden
+}
@@ -0,0 +1,51 @@
+package application
+
+import (
+	"github.com/azaliaz/subs-api/internal/storage"
+	"github.com/azaliaz/subs-api/pkg/money"
+)
+
+// Billing periods. A custom period is given in billing_period_months.
+const (
+	BillingMonth   = "month"
+	BillingQuarter = "quarter"
+	BillingYear    = "year"
+	BillingCustom  = "custom"
+
+	MaxBillingPeriodMonths = storage.MaxBillingPeriodMonths
+)
+
+var billingPeriods = map[string]int{
+	BillingMonth:   1,
+	BillingQuarter: 3,
+	BillingYear:    12,
+}
+
+// billingPeriodMonths is the length of a validated billing period. Without
+// billing_period the number of months decides, and without either the
+// subscription is monthly.
+func billingPeriodMonths(period string, months *int) int {
+	if n, ok := billingPeriods[period]; ok {
+		return n
+	}
+	if months != nil {
+		return *months
+	}
+	return 1
+}
+
+// billingPeriodName reports the named period for the common lengths.
+func billingPeriodName(months int) string {
+	for name, n := range billingPeriods {
+		if n == months {
+			return name
+		}
+	}
+	return BillingCustom
+}
+
+// monthlyPrice spreads price evenly over the months of its billing period,
+// rounded to minor units.
+func monthlyPrice(price money.Amount, months int) money.Amount {
+	return price.Div(int64(max(months, 1)))
+}
@@ -1,6 +1,22 @@
 package application
 
+import "time"
+
 type Config struct {
 	Name   string `env:"NAME" envDefault:"labels-api" yaml:"name"`
 	Secret string `env:"SECRET" yaml:"secret"`
+	// IdempotencyTTL is how long an Idempotency-Key of a create request is
+	// remembered; the janitor removes expired keys every cleanup interval.
+	IdempotencyTTL             time.Duration `env:"IDEMPOTENCY_TTL" envDefault:"24h" yaml:"idempotency-ttl"`
+	IdempotencyCleanupInterval time.Duration `env:"IDEMPOTENCY_CLEANUP_INTERVAL" envDefault:"10m" yaml:"idempotency-cleanup-interval"`
+	// OverlapPolicy is one of off, warn and reject; see OverlapOff.
+	OverlapPolicy string `env:"OVERLAP_POLICY" envDefault:"off" yaml:"overlap-policy"`
+	// DefaultCurrency is used for subscriptions created without a currency
+	// and for totals requested without one.
+	DefaultCurrency string `env:"DEFAULT_CURRENCY" envDefault:"RUB" yaml:"default-currency"`
+	// DeletedRetention is how long a deleted subscription can be restored;
+	// every purge interval the ones deleted earlier are removed for good.
+	// A zero retention keeps them forever.
+	DeletedRetention time.Duration `env:"DELETED_RETENTION" envDefault:"720h" yaml:"deleted-retention"`
+	PurgeInterval    time.Duration `env:"PURGE_INTERVAL" envDefault:"1h" yaml:"purge-interval"`
 }
@@ -0,0 +1,20 @@
+package application
+
+import (
+	"errors"
+	"github.com/azaliaz/subs-api/internal/storage"
+)
+
+// Errors returned by the service are wrapped around one of these sentinels so
+// that callers can map them with errors.Is. Storage errors share the same
+// sentinels and pass through unchanged.
+var (
+	ErrNotFound            = storage.ErrNotFound
+	ErrValidation          = storage.ErrValidation
+	ErrConflict            = storage.ErrConflict
+	ErrPreconditionFailed  = storage.ErrPreconditionFailed
+	ErrIdempotencyMismatch = storage.ErrIdempotencyMismatch
+	ErrMissingFXRate       = storage.ErrMissingFXRate
+	ErrUnauthorized        = errors.New("unauthorized")
+	ErrForbidden           = errors.New("forbidden")
+)
@@ -0,0 +1,234 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/azaliaz/subs-api/internal/storage"
+	"github.com/azaliaz/subs-api/pkg/money"
+	"github.com/azaliaz/subs-api/pkg/tracing"
+)
+
+// FXRate converts one unit of Base into Rate units of Quote from Month on,
+// until a rate for a later month of the same pair takes over.
+type FXRate struct {
+	Base  string        `json:"base"`
+	Quote string        `json:"quote"`
+	Month string        `json:"month"`
+	Rate  money.Decimal `json:"rate"`
+}
+
+type FXRatesRequest struct {
+	Base  *string `json:"base"`
+	Quote *string `json:"quote"`
+}
+
+type FXRatesResponse struct {
+	Rates []FXRate `json:"rates"`
+}
+
+type ImportFXRatesRequest struct {
+	Rates []FXRate `json:"rates"`
+}
+
+type ImportFXRatesResponse struct {
+	Imported int `json:"imported"`
+}
+
+type FXRateKey struct {
+	Base  string `json:"base"`
+	Quote string `json:"quote"`
+	Month string `json:"month"`
+}
+
+func (f FieldErrors) fxRate(prefix string, rate *FXRate) {
+	f.fxRateKey(prefix, &FXRateKey{Base: rate.Base, Quote: rate.Quote, Month: rate.Month})
+	amount, err := rate.Rate.Amount(storage.FXRateScale)
+	switch {
+	case rate.Rate == "":
+		f.Add(prefix+"rate", "is required")
+	case err != nil:
+		f.Add(prefix+"rate", fmt.Sprintf("must be a decimal number with at most %d decimal places", storage.FXRateScale))
+	case amount.Minor <= 0:
+		f.Add(prefix+"rate", "must be greater than 0")
+	}
+}
+
+func (f FieldErrors) fxRateKey(prefix string, key *FXRateKey) {
+	f.required(prefix+"base", key.Base)
+	f.currency(prefix+"base", &key.Base)
+	f.required(prefix+"quote", key.Quote)
+	f.currency(prefix+"quote", &key.Quote)
+	if key.Base != "" && key.Base == key.Quote {
+		f.Add(prefix+"quote", "must differ from "+prefix+"base")
+	}
+	f.required(prefix+"month", key.Month)
+	f.month(prefix+"month", &key.Month)
+}
+
+func (r *FXRatesRequest) Validate() error {
+	f := FieldErrors{}
+	f.currency("base", r.Base)
+	f.currency("quote", r.Quote)
+	return f.Err()
+}
+
+func (r *FXRate) Validate() error {
+	f := FieldErrors{}
+	f.fxRate("", r)
+	return f.Err()
+}
+
+// Validate reports the errors of each rate under rates[i]. A pair may appear
+// only once per month.
+func (r *ImportFXRatesRequest) Validate() error {
+	f := FieldErrors{}
+	if len(r.Rates) == 0 {
+		f.Add("rates", "must not be empty")
+	}
+	seen := make(map[FXRateKey]int, len(r.Rates))
+	for i := range r.Rates {
+		rate := &r.Rates[i]
+		prefix := fmt.Sprintf("rates[%d].", i)
+		f.fxRate(prefix, rate)
+		key := FXRateKey{Base: rate.Base, Quote: rate.Quote, Month: rate.Month}
+		if j, ok := seen[key]; ok {
+			f.Add(prefix+"month", fmt.Sprintf("duplicates rates[%d]", j))
+			continue
+		}
+		seen[key] = i
+	}
+	return f.Err()
+}
+
+func (r *FXRateKey) Validate() error {
+	f := FieldErrors{}
+	f.fxRateKey("", r)
+	return f.Err()
+}
+
+func (s *Service) ListFXRates(ctx context.Context, request *FXRatesRequest) (_ *FXRatesResponse, err error) {
+	ctx, span := tracer.Start(ctx, "application.ListFXRates")
+	defer func() { tracing.End(span, err) }()
+
+	log := s.requestLogger(ctx)
+
+	if request == nil {
+		request = &FXRatesRequest{}
+	}
+	if err := request.Validate(); err != nil {
+		log.Warn("invalid fx rates request in application layer", "error", err)
+		return nil, err
+	}
+
+	rates, err := s.db.ListFXRates(ctx, &storage.FXRatesRequest{
+		Base:  request.Base,
+		Quote: request.Quote,
+	})
+	if err != nil {
+		log.Error("failed to list fx rates in storage layer", "error", err)
+		return nil, fmt.Errorf("list fx rates: %w", err)
+	}
+
+	resp := &FXRatesResponse{Rates: make([]FXRate, 0, len(rates))}
+	for _, rate := range rates {
+		resp.Rates = append(resp.Rates, FXRate(rate))
+	}
+	return resp, nil
+}
+
+func (s *Service) PutFXRate(ctx context.Context, request *FXRate) (_ *FXRate, err error) {
+	ctx, span := tracer.Start(ctx, "application.PutFXRate")
+	defer func() { tracing.End(span, err) }()
+
+	log := s.requestLogger(ctx)
+
+	if request == nil {
+		log.Warn("request is nil in application layer")
+		return nil, fmt.Errorf("%w: request cannot be nil", ErrValidation)
+	}
+
+	if err := s.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if err := request.Validate(); err != nil {
+		log.Warn("invalid fx rate in application layer", "error", err)
+		return nil, err
+	}
+
+	if err := s.db.SaveFXRates(ctx, []storage.FXRate{storage.FXRate(*request)}); err != nil {
+		log.Error("failed to save fx rate in storage layer", "error", err)
+		return nil, fmt.Errorf("put fx rate: %w", err)
+	}
+
+	rate := *request
+	return &rate, nil
+}
+
+// ImportFXRates saves every rate of the request or, if any is invalid, none.
+func (s *Service) ImportFXRates(ctx context.Context, request *ImportFXRatesRequest) (_ *ImportFXRatesResponse, err error) {
+	ctx, span := tracer.Start(ctx, "application.ImportFXRates")
+	defer func() { tracing.End(span, err) }()
+
+	log := s.requestLogger(ctx)
+
+	if request == nil {
+		log.Warn("request is nil in application layer")
+		return nil, fmt.Errorf("%w: request cannot be nil", ErrValidation)
+	}
+
+	if err := s.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if err := request.Validate(); err != nil {
+		log.Warn("invalid fx rates import in application layer", "error", err)
+		return nil, err
+	}
+
+	rates := make([]storage.FXRate, 0, len(request.Rates))
+	for _, rate := range request.Rates {
+		rates = append(rates, storage.FXRate(rate))
+	}
+	if err := s.db.SaveFXRates(ctx, rates); err != nil {
+		log.Error("failed to import fx rates in storage layer", "error", err)
+		return nil, fmt.Errorf("import fx rates: %w", err)
+	}
+
+	return &ImportFXRatesResponse{Imported: len(rates)}, nil
+}
+
+func (s *Service) DeleteFXRate(ctx context.Context, request *FXRateKey) (_ *DeleteResponse, err error) {
+	ctx, span := tracer.Start(ctx, "application.DeleteFXRate")
+	defer func() { tracing.End(span, err) }()
+
+	log := s.requestLogger(ctx)
+
+	if request == nil {
+		log.Warn("request is nil in application layer")
+		return nil, fmt.Errorf("%w: request cannot be nil", ErrValidation)
+	}
+
+	if err := s.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	if err := request.Validate(); err != nil {
+		log.Warn("invalid fx rate key in application layer", "error", err)
+		return nil, err
+	}
+
+	err = s.db.DeleteFXRate(ctx, &storage.FXRateKey{
+		Base:  request.Base,
+		Quote: request.Quote,
+		Month: request.Month,
+	})
+	if errors.Is(err, ErrNotFound) {
+		log.Warn("fx rate not found in application layer", "base", request.Base, "quote", request.Quote, "month", request.Month)
+		return nil, err
+	}
+	if err != nil {
+		log.Error("failed to delete fx rate in storage layer", "error", err)
+		return nil, fmt.Errorf("delete fx rate: %w", err)
+	}
+
+	return &DeleteResponse{Deleted: true}, nil
+}
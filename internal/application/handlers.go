@@ -5,207 +5,392 @@ import (
 	"errors"
 	"fmt"
 	"github.com/azaliaz/subs-api/internal/storage"
+	"github.com/azaliaz/subs-api/pkg/optional"
+	"github.com/azaliaz/subs-api/pkg/tracing"
 	"github.com/google/uuid"
-	"time"
 )
 
-func validateDates(start, end *string) error {
-	var startTime, endTime time.Time
-	var err error
+func (s *Service) Create(ctx context.Context, request *CreateRequest) (_ *CreateResponse, err error) {
+	ctx, span := tracer.Start(ctx, "application.Create")
+	defer func() { tracing.End(span, err) }()
 
-	if start != nil {
-		startTime, err = time.Parse("01-2006", *start)
-		if err != nil {
-			return fmt.Errorf("invalid start_date format, expected MM-YYYY: %w", err)
-		}
-	}
-
-	if end != nil {
-		endTime, err = time.Parse("01-2006", *end)
-		if err != nil {
-			return fmt.Errorf("invalid end_date format, expected MM-YYYY: %w", err)
-		}
-	}
-
-	if start != nil && end != nil && endTime.Before(startTime) {
-		return errors.New("end_date cannot be before start_date")
-	}
+	log := s.requestLogger(ctx)
 
-	return nil
-}
-
-func (s *Service) Create(ctx context.Context, request *CreateRequest) (*CreateResponse, error) {
 	if request == nil {
-		s.log.Warn("request is nil in application layer")
-		return nil, errors.New("request cannot be nil")
+		log.Warn("request is nil in application layer")
+		return nil, fmt.Errorf("%w: request cannot be nil", ErrValidation)
 	}
 
-	if request.UserID == uuid.Nil {
-		return nil, errors.New("user_id is required")
-	}
-	if request.ServiceName == "" {
-		return nil, errors.New("service_name is required")
+	if err := request.Validate(); err != nil {
+		log.Warn("invalid create request in application layer", "error", err)
+		return nil, err
 	}
-	if request.Price <= 0 {
-		return nil, errors.New("price must be greater than 0")
+	currency := s.currency(request.Currency)
+	price, err := request.amount(currency)
+	if err != nil {
+		log.Warn("invalid create request in application layer", "error", err)
+		return nil, err
 	}
-	if err := validateDates(&request.StartDate, request.EndDate); err != nil {
-		s.log.Warn("invalid start date format in application layer", "error", err)
+	if err := s.authorize(ctx, request.UserID); err != nil {
 		return nil, err
 	}
+	idempotency, err := s.idempotency(ctx, request)
+	if err != nil {
+		log.Error("failed to hash create request", "error", err)
+		return nil, fmt.Errorf("create request: %w", err)
+	}
 	resp, err := s.db.Create(ctx, &storage.CreateRequest{
-		UserID:      request.UserID,
-		ServiceName: request.ServiceName,
-		Price:       request.Price,
-		StartDate:   request.StartDate,
-		EndDate:     request.EndDate,
+		UserID:              request.UserID,
+		ServiceName:         request.ServiceName,
+		Price:               price,
+		Currency:            currency,
+		StartDate:           request.StartDate,
+		EndDate:             request.EndDate,
+		BillingPeriodMonths: billingPeriodMonths(request.BillingPeriod, request.BillingPeriodMonths),
+		BillingAnchor:       request.BillingAnchor,
+		Idempotency:         idempotency,
+		Overlap:             s.overlapPolicy(),
 	})
 	if err != nil {
-		s.log.Error("failed to create subscription in storage layer", "error", err)
+		log.Error("failed to create subscription in storage layer", "error", err)
 		return nil, fmt.Errorf("create request: %w", err)
 	}
-	return &CreateResponse{ID: resp.ID}, nil
+	if !resp.Replayed {
+		subscriptionsCreated.Inc()
+	}
+	if len(resp.Overlaps) > 0 {
+		log.Warn("subscription overlaps existing ones", "id", resp.ID, "overlaps", resp.Overlaps)
+	}
+	return &CreateResponse{ID: resp.ID, Overlaps: resp.Overlaps, Replayed: resp.Replayed}, nil
 
 }
 
-func (s *Service) GetInfo(ctx context.Context, request *GetInfoRequest) (*GetInfoResponse, error) {
+func (s *Service) GetInfo(ctx context.Context, request *GetInfoRequest) (_ *GetInfoResponse, err error) {
+	ctx, span := tracer.Start(ctx, "application.GetInfo")
+	defer func() { tracing.End(span, err) }()
+
+	log := s.requestLogger(ctx)
+
 	if request == nil {
-		s.log.Warn("request is nil in application layer")
-		return nil, errors.New("request cannot be nil")
+		log.Warn("request is nil in application layer")
+		return nil, fmt.Errorf("%w: request cannot be nil", ErrValidation)
 	}
 
 	if request.ID == uuid.Nil {
-		s.log.Warn("invalid ID in application layer")
-		return nil, errors.New("id is required")
+		log.Warn("invalid ID in application layer")
+		return nil, fmt.Errorf("%w: id is required", ErrValidation)
 	}
 
 	resp, err := s.db.GetInfo(ctx, request.ID)
 	if err != nil {
-		s.log.Error("failed to get info in storage layer", "error", err)
+		log.Error("failed to get info in storage layer", "error", err)
 		return nil, fmt.Errorf("failed to get subscription info: %w", err)
 	}
 	if resp == nil {
-		return nil, nil
+		log.Warn("subscription not found in application layer", "id", request.ID)
+		return nil, fmt.Errorf("%w: subscription with id %s", ErrNotFound, request.ID)
+	}
+	if err := s.authorizeOwner(ctx, request.ID, resp.UserID); err != nil {
+		return nil, err
 	}
 
-	return &GetInfoResponse{
-		ID:          resp.ID,
-		UserID:      resp.UserID,
-		ServiceName: resp.ServiceName,
-		Price:       resp.Price,
-		StartDate:   resp.StartDate,
-		EndDate:     resp.EndDate,
-	}, nil
+	info := toGetInfoResponse(resp)
+	return &info, nil
 }
 
-func (s *Service) List(ctx context.Context, request *ListRequest) (*ListResponse, error) {
+func (s *Service) List(ctx context.Context, request *ListRequest) (_ *ListResponse, err error) {
+	ctx, span := tracer.Start(ctx, "application.List")
+	defer func() { tracing.End(span, err) }()
+
+	log := s.requestLogger(ctx)
+
 	if request == nil {
-		s.log.Warn("request is nil in application layer")
-		return nil, errors.New("request cannot be nil")
+		log.Warn("request is nil in application layer")
+		return nil, fmt.Errorf("%w: request cannot be nil", ErrValidation)
 	}
 
-	if err := validateDates(request.From, request.To); err != nil {
-		s.log.Warn("invalid date range in application layer", "error", err)
+	if err := request.Validate(); err != nil {
+		log.Warn("invalid list request in application layer", "error", err)
+		return nil, err
+	}
+	if request.IncludeDeleted {
+		if err := s.requireAdmin(ctx); err != nil {
+			return nil, err
+		}
+	}
+	userIDs, err := s.scopeUserIDs(ctx, request.UserIDs)
+	if err != nil {
 		return nil, err
 	}
+	// Validate has already rejected a malformed sort or token.
+	sortField, desc, _ := parseSort(request.Sort)
+	var after *storage.ListCursor
+	if request.PageToken != "" {
+		_, after, _ = decodePageToken(request.PageToken)
+	}
 	storageResp, err := s.db.List(ctx, &storage.ListRequest{
-		UserID:      request.UserID,
-		ServiceName: request.ServiceName,
-		From:        request.From,
-		To:          request.To,
-		Limit:       request.Limit,
-		Offset:      request.Offset,
+		UserIDs:          userIDs,
+		ServiceName:      request.ServiceName,
+		ServiceNameExact: request.ServiceMatch == ServiceMatchExact,
+		From:             request.From,
+		To:               request.To,
+		PriceMin:         request.PriceMin,
+		PriceMax:         request.PriceMax,
+		Status:           request.Status,
+		AsOf:             request.AsOf,
+		Sort:             sortField,
+		Desc:             desc,
+		Limit:            request.Limit,
+		Offset:           request.Offset,
+		IncludeDeleted:   request.IncludeDeleted,
+		After:            after,
 	})
 	if err != nil {
-		s.log.Error("failed to list subscriptions in storage layer", "error", err)
+		log.Error("failed to list subscriptions in storage layer", "error", err)
 		return nil, fmt.Errorf("failed to list subscriptions: %w", err)
 	}
 
-	var appResp ListResponse
+	appResp := ListResponse{
+		Total:   storageResp.Total,
+		HasMore: storageResp.HasMore,
+	}
 	for _, sub := range storageResp.Subscriptions {
-		appResp.Subscriptions = append(appResp.Subscriptions, GetInfoResponse{
-			ID:          sub.ID,
-			UserID:      sub.UserID,
-			ServiceName: sub.ServiceName,
-			Price:       sub.Price,
-			StartDate:   sub.StartDate,
-			EndDate:     sub.EndDate,
-		})
+		appResp.Subscriptions = append(appResp.Subscriptions, toGetInfoResponse(&sub))
+	}
+	if storageResp.Next != nil {
+		appResp.NextPageToken = encodePageToken(canonicalSort(request.Sort), storageResp.Next)
 	}
 
 	return &appResp, nil
 }
 
-func (s *Service) Update(ctx context.Context, id uuid.UUID, request *UpdateRequest) (*UpdateResponse, error) {
+func (s *Service) Update(ctx context.Context, id uuid.UUID, request *UpdateRequest) (_ *UpdateResponse, err error) {
+	ctx, span := tracer.Start(ctx, "application.Update")
+	defer func() { tracing.End(span, err) }()
+
+	log := s.requestLogger(ctx)
+
 	if request == nil {
-		s.log.Warn("request is nil in application layer")
-		return nil, errors.New("request cannot be nil")
+		log.Warn("request is nil in application layer")
+		return nil, fmt.Errorf("%w: request cannot be nil", ErrValidation)
+	}
+
+	if err := request.Validate(); err != nil {
+		log.Warn("invalid update request in application layer", "error", err)
+		return nil, err
 	}
 
-	if request.Price != nil && *request.Price <= 0 {
-		s.log.Warn("price must be greater than 0 in application layer")
-		return nil, errors.New("price must be greater than 0")
+	current, err := s.db.GetInfo(ctx, id)
+	if err != nil {
+		log.Error("failed to get info in storage layer", "error", err)
+		return nil, fmt.Errorf("failed to get subscription info: %w", err)
+	}
+	if current == nil {
+		log.Warn("subscription not found in application layer", "id", id)
+		return nil, fmt.Errorf("%w: subscription with id %s", ErrNotFound, id)
 	}
-	if err := validateDates(request.StartDate, request.EndDate); err != nil {
-		s.log.Warn("invalid date range in application layer", "error", err)
+	if err := s.authorizeOwner(ctx, id, current.UserID); err != nil {
 		return nil, err
 	}
-	resp, err := s.db.Update(ctx, id, &storage.UpdateRequest{
-		ServiceName: request.ServiceName,
-		Price:       request.Price,
-		StartDate:   request.StartDate,
-		EndDate:     request.EndDate,
+	if request.Version != nil && *request.Version != current.Version {
+		log.Warn("subscription version mismatch in application layer", "id", id, "version", current.Version, "expected", *request.Version)
+		return nil, fmt.Errorf("%w: subscription with id %s is no longer at version %d", ErrPreconditionFailed, id, *request.Version)
+	}
+	if err := request.validateMerged(current.StartDate, current.EndDate); err != nil {
+		log.Warn("invalid update request in application layer", "error", err)
+		return nil, err
+	}
+	price, err := request.amount(current.Currency)
+	if err != nil {
+		log.Warn("invalid update request in application layer", "error", err)
+		return nil, err
+	}
+	// The currency belongs to the new price; without one it is unchanged.
+	currency := request.Currency
+	if !price.Set {
+		currency = optional.Value[string]{}
+	}
+
+	return s.update(ctx, id, &storage.UpdateRequest{
+		ServiceName:         request.ServiceName,
+		Price:               price,
+		PriceEffectiveFrom:  request.PriceEffectiveFrom,
+		Currency:            currency,
+		StartDate:           request.StartDate,
+		EndDate:             request.EndDate,
+		BillingPeriodMonths: request.billingPeriodMonths(),
+		BillingAnchor:       request.BillingAnchor,
+		Version:             request.Version,
+		Overlap:             s.overlapPolicy(),
+	})
+}
+
+// Replace overwrites every field of the subscription; an absent end_date
+// makes it open-ended.
+func (s *Service) Replace(ctx context.Context, id uuid.UUID, request *ReplaceRequest) (_ *UpdateResponse, err error) {
+	ctx, span := tracer.Start(ctx, "application.Replace")
+	defer func() { tracing.End(span, err) }()
+
+	log := s.requestLogger(ctx)
+
+	if request == nil {
+		log.Warn("request is nil in application layer")
+		return nil, fmt.Errorf("%w: request cannot be nil", ErrValidation)
+	}
+
+	if err := request.Validate(); err != nil {
+		log.Warn("invalid replace request in application layer", "error", err)
+		return nil, err
+	}
+	currency := s.currency(request.Currency)
+	price, err := request.amount(currency)
+	if err != nil {
+		log.Warn("invalid replace request in application layer", "error", err)
+		return nil, err
+	}
+	if err := s.authorizeSubscription(ctx, id); err != nil {
+		return nil, err
+	}
+	if err := s.authorize(ctx, request.UserID); err != nil {
+		return nil, err
+	}
+
+	return s.update(ctx, id, &storage.UpdateRequest{
+		UserID:              optional.Of(request.UserID),
+		ServiceName:         optional.Of(request.ServiceName),
+		Price:               optional.Of(price),
+		Currency:            optional.Of(currency),
+		StartDate:           optional.Of(request.StartDate),
+		EndDate:             optional.FromPtr(request.EndDate),
+		BillingPeriodMonths: optional.Of(billingPeriodMonths(request.BillingPeriod, request.BillingPeriodMonths)),
+		BillingAnchor:       optional.FromPtr(request.BillingAnchor),
+		Version:             request.Version,
+		Overlap:             s.overlapPolicy(),
 	})
+}
+
+func (s *Service) update(ctx context.Context, id uuid.UUID, request *storage.UpdateRequest) (*UpdateResponse, error) {
+	log := s.requestLogger(ctx)
+
+	resp, err := s.db.Update(ctx, id, request)
+	if errors.Is(err, ErrNotFound) || errors.Is(err, ErrPreconditionFailed) {
+		log.Warn("subscription not updated in application layer", "id", id, "error", err)
+		return nil, err
+	}
 	if err != nil {
-		s.log.Error("failed to update subscription in storage layer", "error", err)
+		log.Error("failed to update subscription in storage layer", "error", err)
 		return nil, fmt.Errorf("update request: %w", err)
 	}
+	subscriptionsUpdated.Inc()
+	if len(resp.Overlaps) > 0 {
+		log.Warn("subscription overlaps existing ones", "id", id, "overlaps", resp.Overlaps)
+	}
 
-	return &UpdateResponse{Updated: resp.Updated}, nil
+	return &UpdateResponse{
+		GetInfoResponse: toGetInfoResponse(&resp.GetInfoResponse),
+		Overlaps:        resp.Overlaps,
+	}, nil
+}
+
+func toGetInfoResponse(sub *storage.GetInfoResponse) GetInfoResponse {
+	return GetInfoResponse{
+		ID:                  sub.ID,
+		UserID:              sub.UserID,
+		ServiceName:         sub.ServiceName,
+		Price:               sub.Price,
+		Currency:            sub.Currency,
+		StartDate:           sub.StartDate,
+		EndDate:             sub.EndDate,
+		BillingPeriod:       billingPeriodName(sub.BillingPeriodMonths),
+		BillingPeriodMonths: sub.BillingPeriodMonths,
+		BillingAnchor:       sub.BillingAnchor,
+		MonthlyPrice:        monthlyPrice(sub.Price, sub.BillingPeriodMonths),
+		CreatedAt:           sub.CreatedAt,
+		UpdatedAt:           sub.UpdatedAt,
+		Version:             sub.Version,
+		DeletedAt:           sub.DeletedAt,
+	}
 }
 
-func (s *Service) Delete(ctx context.Context, request *DeleteRequest) (*DeleteResponse, error) {
+func (s *Service) Delete(ctx context.Context, request *DeleteRequest) (_ *DeleteResponse, err error) {
+	ctx, span := tracer.Start(ctx, "application.Delete")
+	defer func() { tracing.End(span, err) }()
+
+	log := s.requestLogger(ctx)
+
 	if request == nil {
-		s.log.Warn("request is nil in application layer")
-		return nil, errors.New("request cannot be nil")
+		log.Warn("request is nil in application layer")
+		return nil, fmt.Errorf("%w: request cannot be nil", ErrValidation)
 	}
 
 	if request.ID == uuid.Nil {
-		s.log.Warn("invalid ID in application layer")
-		return nil, errors.New("id is required")
+		log.Warn("invalid ID in application layer")
+		return nil, fmt.Errorf("%w: id is required", ErrValidation)
 	}
-	err := s.db.Delete(ctx, &storage.DeleteRequest{
-		ID: request.ID,
+	if err := s.authorizeSubscription(ctx, request.ID); err != nil {
+		return nil, err
+	}
+	err = s.db.Delete(ctx, &storage.DeleteRequest{
+		ID:      request.ID,
+		Version: request.Version,
 	})
 	if err != nil {
-		s.log.Error("failed to delete subscription in storage layer", "error", err)
+		log.Error("failed to delete subscription in storage layer", "error", err)
 		return nil, fmt.Errorf("delete request: %w", err)
 	}
+	subscriptionsDeleted.Inc()
 
 	return &DeleteResponse{Deleted: true}, nil
 }
 
-func (s *Service) GetTotalSubscriptionsPrice(ctx context.Context, request *TotalRequest) (*TotalResponse, error) {
+func (s *Service) GetTotalSubscriptionsPrice(ctx context.Context, request *TotalRequest) (_ *TotalResponse, err error) {
+	ctx, span := tracer.Start(ctx, "application.GetTotalSubscriptionsPrice")
+	defer func() { tracing.End(span, err) }()
+
+	log := s.requestLogger(ctx)
+
 	if request == nil {
-		s.log.Warn("request is nil in application layer")
-		return nil, errors.New("request cannot be nil")
+		log.Warn("request is nil in application layer")
+		return nil, fmt.Errorf("%w: request cannot be nil", ErrValidation)
 	}
 
-	if err := validateDates(&request.From, &request.To); err != nil {
-		s.log.Warn("invalid date range in application layer", "error", err)
+	if err := request.Validate(); err != nil {
+		log.Warn("invalid total request in application layer", "error", err)
+		return nil, err
+	}
+	userID, err := s.scopeUserID(ctx, request.UserID)
+	if err != nil {
 		return nil, err
 	}
 
 	total, err := s.db.GetTotalSubscriptionsPrice(ctx, &storage.TotalRequest{
-		UserID:      request.UserID,
+		UserID:      userID,
 		ServiceName: request.ServiceName,
 		From:        request.From,
 		To:          request.To,
+		GroupBy:     request.GroupBy,
+		Currency:    s.currency(request.Currency),
 	})
+	if errors.Is(err, ErrMissingFXRate) {
+		log.Warn("cannot convert total in application layer", "error", err)
+		return nil, err
+	}
 	if err != nil {
-		s.log.Error("failed to get total subscriptions price", "error", err)
+		log.Error("failed to get total subscriptions price", "error", err)
 		return nil, fmt.Errorf("get total subscriptions price: %w", err)
 	}
 
-	return &TotalResponse{Total: total}, nil
+	resp := &TotalResponse{Currency: total.Currency, Total: total.Total, NormalizedTotal: total.NormalizedTotal}
+	for _, bucket := range total.Buckets {
+		resp.Buckets = append(resp.Buckets, TotalBucket{
+			Month:           bucket.Month,
+			ServiceName:     bucket.ServiceName,
+			UserID:          bucket.UserID,
+			Total:           bucket.Total,
+			NormalizedTotal: bucket.NormalizedTotal,
+			Count:           bucket.Count,
+		})
+	}
+
+	return resp, nil
 }
@@ -0,0 +1,59 @@
+package application
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/azaliaz/subs-api/internal/storage"
+	"github.com/azaliaz/subs-api/pkg/tracing"
+	"time"
+)
+
+// idempotency describes the key of a create request for storage. Keys are
+// scoped to the caller, so that two users cannot see each other's responses.
+func (s *Service) idempotency(ctx context.Context, request *CreateRequest) (*storage.Idempotency, error) {
+	if request.IdempotencyKey == "" {
+		return nil, nil
+	}
+
+	// The key is excluded from the JSON form, which leaves exactly the
+	// fields that make up the subscription.
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(body)
+
+	var scope string
+	if principal, ok := PrincipalFromContext(ctx); ok {
+		scope = principal.Subject.String()
+	}
+
+	ttl := 24 * time.Hour
+	if s.config != nil && s.config.IdempotencyTTL > 0 {
+		ttl = s.config.IdempotencyTTL
+	}
+
+	return &storage.Idempotency{
+		Scope:       scope,
+		Key:         request.IdempotencyKey,
+		RequestHash: hex.EncodeToString(hash[:]),
+		ExpiresAt:   time.Now().Add(ttl),
+	}, nil
+}
+
+func (s *Service) deleteExpiredIdempotencyKeys(ctx context.Context) {
+	ctx, span := tracer.Start(ctx, "application.deleteExpiredIdempotencyKeys")
+	var err error
+	defer func() { tracing.End(span, err) }()
+
+	deleted, err := s.db.DeleteExpiredIdempotencyKeys(ctx)
+	if err != nil {
+		s.log.Error("failed to delete expired idempotency keys", "error", err)
+		return
+	}
+	if deleted > 0 {
+		s.log.Info("deleted expired idempotency keys", "count", deleted)
+	}
+}
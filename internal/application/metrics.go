@@ -0,0 +1,34 @@
+package application
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	subscriptionsCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "subs_api",
+		Name:      "subscriptions_created_total",
+		Help:      "Number of subscriptions created.",
+	})
+	subscriptionsUpdated = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "subs_api",
+		Name:      "subscriptions_updated_total",
+		Help:      "Number of subscriptions updated.",
+	})
+	subscriptionsDeleted = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "subs_api",
+		Name:      "subscriptions_deleted_total",
+		Help:      "Number of subscriptions deleted.",
+	})
+	subscriptionsRestored = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "subs_api",
+		Name:      "subscriptions_restored_total",
+		Help:      "Number of deleted subscriptions restored.",
+	})
+	subscriptionsPurged = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "subs_api",
+		Name:      "subscriptions_purged_total",
+		Help:      "Number of deleted subscriptions removed after the retention period.",
+	})
+)
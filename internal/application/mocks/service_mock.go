@@ -36,6 +36,21 @@ func (m *MockSubscriptionsService) EXPECT() *MockSubscriptionsServiceMockRecorde
 	return m.recorder
 }
 
+// Authenticate mocks base method.
+func (m *MockSubscriptionsService) Authenticate(ctx context.Context, token string) (*application.Principal, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Authenticate", ctx, token)
+	ret0, _ := ret[0].(*application.Principal)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Authenticate indicates an expected call of Authenticate.
+func (mr *MockSubscriptionsServiceMockRecorder) Authenticate(ctx, token interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Authenticate", reflect.TypeOf((*MockSubscriptionsService)(nil).Authenticate), ctx, token)
+}
+
 // Create mocks base method.
 func (m *MockSubscriptionsService) Create(ctx context.Context, request *application.CreateRequest) (*application.CreateResponse, error) {
 	m.ctrl.T.Helper()
@@ -66,6 +81,21 @@ func (mr *MockSubscriptionsServiceMockRecorder) Delete(ctx, request interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockSubscriptionsService)(nil).Delete), ctx, request)
 }
 
+// DeleteFXRate mocks base method.
+func (m *MockSubscriptionsService) DeleteFXRate(ctx context.Context, request *application.FXRateKey) (*application.DeleteResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteFXRate", ctx, request)
+	ret0, _ := ret[0].(*application.DeleteResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteFXRate indicates an expected call of DeleteFXRate.
+func (mr *MockSubscriptionsServiceMockRecorder) DeleteFXRate(ctx, request interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteFXRate", reflect.TypeOf((*MockSubscriptionsService)(nil).DeleteFXRate), ctx, request)
+}
+
 // GetInfo mocks base method.
 func (m *MockSubscriptionsService) GetInfo(ctx context.Context, request *application.GetInfoRequest) (*application.GetInfoResponse, error) {
 	m.ctrl.T.Helper()
@@ -81,6 +111,21 @@ func (mr *MockSubscriptionsServiceMockRecorder) GetInfo(ctx, request interface{}
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetInfo", reflect.TypeOf((*MockSubscriptionsService)(nil).GetInfo), ctx, request)
 }
 
+// GetPrices mocks base method.
+func (m *MockSubscriptionsService) GetPrices(ctx context.Context, request *application.PricesRequest) (*application.PricesResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPrices", ctx, request)
+	ret0, _ := ret[0].(*application.PricesResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPrices indicates an expected call of GetPrices.
+func (mr *MockSubscriptionsServiceMockRecorder) GetPrices(ctx, request interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPrices", reflect.TypeOf((*MockSubscriptionsService)(nil).GetPrices), ctx, request)
+}
+
 // GetTotalSubscriptionsPrice mocks base method.
 func (m *MockSubscriptionsService) GetTotalSubscriptionsPrice(ctx context.Context, request *application.TotalRequest) (*application.TotalResponse, error) {
 	m.ctrl.T.Helper()
@@ -96,6 +141,21 @@ func (mr *MockSubscriptionsServiceMockRecorder) GetTotalSubscriptionsPrice(ctx,
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTotalSubscriptionsPrice", reflect.TypeOf((*MockSubscriptionsService)(nil).GetTotalSubscriptionsPrice), ctx, request)
 }
 
+// ImportFXRates mocks base method.
+func (m *MockSubscriptionsService) ImportFXRates(ctx context.Context, request *application.ImportFXRatesRequest) (*application.ImportFXRatesResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ImportFXRates", ctx, request)
+	ret0, _ := ret[0].(*application.ImportFXRatesResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ImportFXRates indicates an expected call of ImportFXRates.
+func (mr *MockSubscriptionsServiceMockRecorder) ImportFXRates(ctx, request interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImportFXRates", reflect.TypeOf((*MockSubscriptionsService)(nil).ImportFXRates), ctx, request)
+}
+
 // List mocks base method.
 func (m *MockSubscriptionsService) List(ctx context.Context, request *application.ListRequest) (*application.ListResponse, error) {
 	m.ctrl.T.Helper()
@@ -111,6 +171,66 @@ func (mr *MockSubscriptionsServiceMockRecorder) List(ctx, request interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockSubscriptionsService)(nil).List), ctx, request)
 }
 
+// ListFXRates mocks base method.
+func (m *MockSubscriptionsService) ListFXRates(ctx context.Context, request *application.FXRatesRequest) (*application.FXRatesResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListFXRates", ctx, request)
+	ret0, _ := ret[0].(*application.FXRatesResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListFXRates indicates an expected call of ListFXRates.
+func (mr *MockSubscriptionsServiceMockRecorder) ListFXRates(ctx, request interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListFXRates", reflect.TypeOf((*MockSubscriptionsService)(nil).ListFXRates), ctx, request)
+}
+
+// PutFXRate mocks base method.
+func (m *MockSubscriptionsService) PutFXRate(ctx context.Context, request *application.FXRate) (*application.FXRate, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PutFXRate", ctx, request)
+	ret0, _ := ret[0].(*application.FXRate)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PutFXRate indicates an expected call of PutFXRate.
+func (mr *MockSubscriptionsServiceMockRecorder) PutFXRate(ctx, request interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PutFXRate", reflect.TypeOf((*MockSubscriptionsService)(nil).PutFXRate), ctx, request)
+}
+
+// Replace mocks base method.
+func (m *MockSubscriptionsService) Replace(ctx context.Context, id uuid.UUID, req *application.ReplaceRequest) (*application.UpdateResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Replace", ctx, id, req)
+	ret0, _ := ret[0].(*application.UpdateResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Replace indicates an expected call of Replace.
+func (mr *MockSubscriptionsServiceMockRecorder) Replace(ctx, id, req interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Replace", reflect.TypeOf((*MockSubscriptionsService)(nil).Replace), ctx, id, req)
+}
+
+// Restore mocks base method.
+func (m *MockSubscriptionsService) Restore(ctx context.Context, request *application.RestoreRequest) (*application.UpdateResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Restore", ctx, request)
+	ret0, _ := ret[0].(*application.UpdateResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Restore indicates an expected call of Restore.
+func (mr *MockSubscriptionsServiceMockRecorder) Restore(ctx, request interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Restore", reflect.TypeOf((*MockSubscriptionsService)(nil).Restore), ctx, request)
+}
+
 // Update mocks base method.
 func (m *MockSubscriptionsService) Update(ctx context.Context, id uuid.UUID, req *application.UpdateRequest) (*application.UpdateResponse, error) {
 	m.ctrl.T.Helper()
@@ -0,0 +1,72 @@
+package application
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/azaliaz/subs-api/internal/storage"
+	"github.com/google/uuid"
+	"strings"
+)
+
+var errInvalidPageToken = errors.New("invalid page token")
+
+// pageToken is the content of page_token: the order of the listing and the
+// sort key of the last subscription of the previous page. Clients treat it
+// as opaque.
+type pageToken struct {
+	Sort  string    `json:"o"`
+	Value string    `json:"v"`
+	ID    uuid.UUID `json:"i"`
+}
+
+func encodePageToken(sort string, cursor *storage.ListCursor) string {
+	data, _ := json.Marshal(pageToken{Sort: sort, Value: cursor.Value, ID: cursor.ID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodePageToken returns the cursor of token and the order it was issued
+// for.
+func decodePageToken(token string) (string, *storage.ListCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return "", nil, errInvalidPageToken
+	}
+	var t pageToken
+	if err := json.Unmarshal(data, &t); err != nil || t.ID == uuid.Nil {
+		return "", nil, errInvalidPageToken
+	}
+	return t.Sort, &storage.ListCursor{Value: t.Value, ID: t.ID}, nil
+}
+
+// parseSort splits a sort parameter such as "price:desc" into the field and
+// direction. An empty value sorts by start_date ascending.
+func parseSort(value string) (field string, desc bool, ok bool) {
+	field, direction, _ := strings.Cut(value, ":")
+	if field == "" {
+		field = SortByStartDate
+	}
+	switch field {
+	case SortByPrice, SortByStartDate, SortByEndDate, SortByServiceName, SortByCreatedAt:
+	default:
+		return "", false, false
+	}
+	switch direction {
+	case "", "asc":
+		return field, false, true
+	case "desc":
+		return field, true, true
+	default:
+		return "", false, false
+	}
+}
+
+// canonicalSort is the sort parameter with the defaults filled in, so that
+// "" and "start_date:asc" issue interchangeable page tokens.
+func canonicalSort(value string) string {
+	field, desc, _ := parseSort(value)
+	if desc {
+		return field + ":desc"
+	}
+	return field + ":asc"
+}
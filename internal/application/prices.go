@@ -0,0 +1,93 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"github.com/azaliaz/subs-api/pkg/money"
+	"github.com/azaliaz/subs-api/pkg/month"
+	"github.com/azaliaz/subs-api/pkg/tracing"
+	"github.com/google/uuid"
+)
+
+type PricesRequest struct {
+	ID uuid.UUID `json:"id"`
+}
+
+// PricesResponse is the price history of a subscription, oldest first.
+// Currency is the one of the current price; each entry has its own.
+type PricesResponse struct {
+	ID       uuid.UUID     `json:"id"`
+	Currency string        `json:"currency"`
+	Prices   []PriceChange `json:"prices"`
+}
+
+// PriceChange is a price of the subscription and the months it applies in.
+// The first price also applies before EffectiveFrom, back to start_date.
+type PriceChange struct {
+	EffectiveFrom string `json:"effective_from"`
+	// EffectiveTo is the last month of the price, null for the latest one.
+	EffectiveTo  *string      `json:"effective_to"`
+	Price        money.Amount `json:"price"`
+	MonthlyPrice money.Amount `json:"monthly_price"`
+	Currency     string       `json:"currency"`
+}
+
+func (s *Service) GetPrices(ctx context.Context, request *PricesRequest) (_ *PricesResponse, err error) {
+	ctx, span := tracer.Start(ctx, "application.GetPrices")
+	defer func() { tracing.End(span, err) }()
+
+	log := s.requestLogger(ctx)
+
+	if request == nil {
+		log.Warn("request is nil in application layer")
+		return nil, fmt.Errorf("%w: request cannot be nil", ErrValidation)
+	}
+
+	if request.ID == uuid.Nil {
+		log.Warn("invalid ID in application layer")
+		return nil, fmt.Errorf("%w: id is required", ErrValidation)
+	}
+
+	sub, err := s.db.GetInfo(ctx, request.ID)
+	if err != nil {
+		log.Error("failed to get info in storage layer", "error", err)
+		return nil, fmt.Errorf("failed to get subscription info: %w", err)
+	}
+	if sub == nil {
+		log.Warn("subscription not found in application layer", "id", request.ID)
+		return nil, fmt.Errorf("%w: subscription with id %s", ErrNotFound, request.ID)
+	}
+	if err := s.authorizeOwner(ctx, request.ID, sub.UserID); err != nil {
+		return nil, err
+	}
+
+	history, err := s.db.ListPrices(ctx, request.ID)
+	if err != nil {
+		log.Error("failed to list prices in storage layer", "error", err)
+		return nil, fmt.Errorf("failed to list subscription prices: %w", err)
+	}
+
+	resp := &PricesResponse{ID: sub.ID, Currency: sub.Currency, Prices: make([]PriceChange, len(history))}
+	for i, change := range history {
+		resp.Prices[i] = PriceChange{
+			EffectiveFrom: change.EffectiveFrom,
+			Price:         change.Price,
+			MonthlyPrice:  monthlyPrice(change.Price, sub.BillingPeriodMonths),
+			Currency:      change.Currency,
+		}
+		if i > 0 {
+			resp.Prices[i-1].EffectiveTo = previousMonth(change.EffectiveFrom)
+		}
+	}
+	return resp, nil
+}
+
+// previousMonth is the month before value, both in MM-YYYY format.
+func previousMonth(value string) *string {
+	t, err := month.Parse(value)
+	if err != nil {
+		return nil
+	}
+	prev := month.Format(t.AddDate(0, -1, 0))
+	return &prev
+}
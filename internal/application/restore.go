@@ -0,0 +1,75 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"github.com/azaliaz/subs-api/internal/storage"
+	"github.com/azaliaz/subs-api/pkg/tracing"
+	"github.com/google/uuid"
+	"time"
+)
+
+type RestoreRequest struct {
+	ID uuid.UUID `json:"id"`
+}
+
+// Restore undoes the deletion of a subscription that has not been purged
+// yet. Users may restore only their own subscriptions; the ones of others
+// are reported as not found.
+func (s *Service) Restore(ctx context.Context, request *RestoreRequest) (_ *UpdateResponse, err error) {
+	ctx, span := tracer.Start(ctx, "application.Restore")
+	defer func() { tracing.End(span, err) }()
+
+	log := s.requestLogger(ctx)
+
+	if request == nil {
+		log.Warn("request is nil in application layer")
+		return nil, fmt.Errorf("%w: request cannot be nil", ErrValidation)
+	}
+
+	if request.ID == uuid.Nil {
+		log.Warn("invalid ID in application layer")
+		return nil, fmt.Errorf("%w: id is required", ErrValidation)
+	}
+	// A deleted subscription cannot be read to check its owner, so the
+	// storage only restores it when it belongs to the caller.
+	userID, err := s.scopeUserID(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.db.Restore(ctx, &storage.RestoreRequest{
+		ID:      request.ID,
+		UserID:  userID,
+		Overlap: s.overlapPolicy(),
+	})
+	if err != nil {
+		log.Error("failed to restore subscription in storage layer", "error", err)
+		return nil, fmt.Errorf("restore request: %w", err)
+	}
+	subscriptionsRestored.Inc()
+	if len(resp.Overlaps) > 0 {
+		log.Warn("subscription overlaps existing ones", "id", request.ID, "overlaps", resp.Overlaps)
+	}
+
+	return &UpdateResponse{
+		GetInfoResponse: toGetInfoResponse(&resp.GetInfoResponse),
+		Overlaps:        resp.Overlaps,
+	}, nil
+}
+
+func (s *Service) purgeDeleted(ctx context.Context) {
+	ctx, span := tracer.Start(ctx, "application.purgeDeleted")
+	var err error
+	defer func() { tracing.End(span, err) }()
+
+	purged, err := s.db.PurgeDeleted(ctx, time.Now().Add(-s.config.DeletedRetention))
+	if err != nil {
+		s.log.Error("failed to purge deleted subscriptions", "error", err)
+		return
+	}
+	if purged > 0 {
+		subscriptionsPurged.Add(float64(purged))
+		s.log.Info("purged deleted subscriptions", "count", purged)
+	}
+}
@@ -2,85 +2,229 @@ package application
 
 import (
 	"context"
+	"fmt"
 	"github.com/azaliaz/subs-api/internal/storage"
+	"github.com/azaliaz/subs-api/pkg/logging"
+	"github.com/azaliaz/subs-api/pkg/money"
+	"github.com/azaliaz/subs-api/pkg/optional"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
 	"log/slog"
+	"sync"
+	"time"
 )
 //go:generate mockgen -source=service.go -destination=./mocks/service_mock.go -package=mocks
 
+var tracer = otel.Tracer("github.com/azaliaz/subs-api/internal/application")
+
 type SubscriptionsService interface {
+	Authenticate(ctx context.Context, token string) (*Principal, error)
 	Create(ctx context.Context, request *CreateRequest) (*CreateResponse, error)
 	GetInfo(ctx context.Context, request *GetInfoRequest) (*GetInfoResponse, error)
 	List(ctx context.Context, request *ListRequest) (*ListResponse, error)
 	Update(ctx context.Context, id uuid.UUID, req *UpdateRequest) (*UpdateResponse, error)
+	Replace(ctx context.Context, id uuid.UUID, req *ReplaceRequest) (*UpdateResponse, error)
 	Delete(ctx context.Context, request *DeleteRequest) (*DeleteResponse, error)
+	Restore(ctx context.Context, request *RestoreRequest) (*UpdateResponse, error)
 	GetTotalSubscriptionsPrice(ctx context.Context, request *TotalRequest) (*TotalResponse, error)
+	GetPrices(ctx context.Context, request *PricesRequest) (*PricesResponse, error)
+	ListFXRates(ctx context.Context, request *FXRatesRequest) (*FXRatesResponse, error)
+	PutFXRate(ctx context.Context, request *FXRate) (*FXRate, error)
+	ImportFXRates(ctx context.Context, request *ImportFXRatesRequest) (*ImportFXRatesResponse, error)
+	DeleteFXRate(ctx context.Context, request *FXRateKey) (*DeleteResponse, error)
 }
 
 type CreateRequest struct {
 	UserID      uuid.UUID `json:"user_id"`
 	ServiceName string    `json:"service_name"`
-	Price       int       `json:"price"`
-	StartDate   string    `json:"start_date"`
-	EndDate     *string   `json:"end_date"`
+	// Price is a decimal in major units of Currency, such as 299.90, with
+	// at most as many decimal places as the currency has.
+	Price money.Decimal `json:"price"`
+	// Currency is an ISO 4217 code, the configured default when empty.
+	Currency  string  `json:"currency,omitempty"`
+	StartDate string  `json:"start_date"`
+	EndDate   *string `json:"end_date"`
+	// BillingPeriod is month, the default, quarter, year or custom, in which
+	// case the period is BillingPeriodMonths long. The price is charged once
+	// per period, counting from BillingAnchor or from start_date. The fields
+	// are omitted when empty so that the idempotency hash of a request
+	// without them stays the same.
+	BillingPeriod       string  `json:"billing_period,omitempty"`
+	BillingPeriodMonths *int    `json:"billing_period_months,omitempty"`
+	BillingAnchor       *string `json:"billing_anchor,omitempty"`
+	// IdempotencyKey makes a retried create return the first response
+	// instead of creating the subscription again.
+	IdempotencyKey string `json:"-"`
 }
 type CreateResponse struct {
 	ID uuid.UUID `json:"id"`
+	// Overlaps lists the subscriptions of the same user and service that
+	// are active in the same months, when the overlap policy is warn.
+	Overlaps []uuid.UUID `json:"overlaps,omitempty"`
+	Replayed bool        `json:"-"`
 }
 
 type GetInfoRequest struct {
 	ID uuid.UUID `json:"id"`
 }
 type GetInfoResponse struct {
-	ID          uuid.UUID `json:"id"`
-	UserID      uuid.UUID `json:"user_id"`
-	ServiceName string    `json:"service_name"`
-	Price       int       `json:"price"`
-	StartDate   string    `json:"start_date"`
-	EndDate     *string   `json:"end_date"`
+	ID                  uuid.UUID    `json:"id"`
+	UserID              uuid.UUID    `json:"user_id"`
+	ServiceName         string       `json:"service_name"`
+	Price               money.Amount `json:"price"`
+	Currency            string       `json:"currency"`
+	StartDate           string       `json:"start_date"`
+	EndDate             *string      `json:"end_date"`
+	BillingPeriod       string       `json:"billing_period"`
+	BillingPeriodMonths int          `json:"billing_period_months"`
+	BillingAnchor       *string      `json:"billing_anchor"`
+	// MonthlyPrice is the price spread evenly over the billing period.
+	MonthlyPrice money.Amount `json:"monthly_price"`
+	CreatedAt    time.Time    `json:"created_at"`
+	UpdatedAt    time.Time    `json:"updated_at"`
+	Version      int64        `json:"version"`
+	// DeletedAt is set only on deleted subscriptions, which are listed to
+	// admins with include_deleted.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
 type ListRequest struct {
-	UserID      *uuid.UUID `json:"user_id"`
-	ServiceName *string    `json:"service_name"`
-	From        *string    `json:"from"`
-	To          *string    `json:"to"`
-	Limit       *int       `json:"limit"`
-	Offset      *int       `json:"offset"`
+	UserIDs     []uuid.UUID `json:"user_id"`
+	ServiceName *string     `json:"service_name"`
+	// ServiceMatch is ServiceMatchSubstring, the default, or
+	// ServiceMatchExact.
+	ServiceMatch string  `json:"service_match"`
+	From         *string `json:"from"`
+	To           *string `json:"to"`
+	// PriceMin and PriceMax bound the price in major units, whatever the
+	// currency.
+	PriceMin *money.Decimal `json:"price_min"`
+	PriceMax *money.Decimal `json:"price_max"`
+	// Status keeps the subscriptions that are active, ended or future in
+	// the AsOf month, the current one by default.
+	Status string  `json:"status"`
+	AsOf   *string `json:"as_of"`
+	// Sort is a SortBy field, optionally followed by ":asc" or ":desc".
+	Sort   string `json:"sort"`
+	Limit  *int   `json:"limit"`
+	Offset *int   `json:"offset"`
+	// IncludeDeleted lists deleted subscriptions too; only admins may set
+	// it.
+	IncludeDeleted bool `json:"include_deleted"`
+	// PageToken is the next_page_token of the previous page.
+	PageToken string `json:"page_token"`
 }
 type ListResponse struct {
 	Subscriptions []GetInfoResponse
+	Total         int    `json:"total"`
+	HasMore       bool   `json:"has_more"`
+	NextPageToken string `json:"next_page_token,omitempty"`
 }
+
+// UpdateRequest is a JSON Merge Patch (RFC 7396): absent fields are left
+// unchanged and a null end_date makes the subscription open-ended.
 type UpdateRequest struct {
-	ServiceName *string `json:"service_name"`
-	Price       *int    `json:"price"`
-	StartDate   *string `json:"start_date"`
-	EndDate     *string `json:"end_date"`
+	ServiceName optional.Value[string] `json:"service_name"`
+	// A new price applies from PriceEffectiveFrom, the current month or
+	// start_date if it is later by default, and earlier prices stay in the
+	// history; a future month schedules the change. A new currency needs
+	// a price and applies from the same month, earlier prices keep theirs.
+	Price              optional.Value[money.Decimal] `json:"price"`
+	PriceEffectiveFrom optional.Value[string]        `json:"price_effective_from"`
+	Currency           optional.Value[string]        `json:"currency"`
+	StartDate          optional.Value[string]        `json:"start_date"`
+	EndDate            optional.Value[string]        `json:"end_date"`
+	// A null billing_anchor charges the subscription from its start_date
+	// again.
+	BillingPeriod       optional.Value[string] `json:"billing_period"`
+	BillingPeriodMonths optional.Value[int]    `json:"billing_period_months"`
+	BillingAnchor       optional.Value[string] `json:"billing_anchor"`
+	// Version, when set, is the version the caller expects to change.
+	Version *int64 `json:"-"`
 }
 
+// ReplaceRequest is a complete subscription, as for Create.
+type ReplaceRequest struct {
+	CreateRequest
+	Version *int64 `json:"-"`
+}
+
+// UpdateResponse is the subscription after the update.
 type UpdateResponse struct {
-	Updated bool `json:"updated"`
+	GetInfoResponse
+	Overlaps []uuid.UUID `json:"overlaps,omitempty"`
 }
 type DeleteRequest struct {
-	ID uuid.UUID `json:"id"`
+	ID      uuid.UUID `json:"id"`
+	Version *int64    `json:"-"`
 }
 type DeleteResponse struct {
 	Deleted bool `json:"deleted"`
 }
+
+// Overlap policies. With OverlapWarn an overlapping subscription is saved
+// and reported in the response; with OverlapReject it fails with ErrConflict.
+const (
+	OverlapOff    = string(storage.OverlapOff)
+	OverlapWarn   = string(storage.OverlapWarn)
+	OverlapReject = string(storage.OverlapReject)
+)
+
+const (
+	SortByPrice       = storage.SortByPrice
+	SortByStartDate   = storage.SortByStartDate
+	SortByEndDate     = storage.SortByEndDate
+	SortByServiceName = storage.SortByServiceName
+	SortByCreatedAt   = storage.SortByCreatedAt
+
+	StatusActive = storage.StatusActive
+	StatusEnded  = storage.StatusEnded
+	StatusFuture = storage.StatusFuture
+
+	ServiceMatchSubstring = "substring"
+	ServiceMatchExact     = "exact"
+)
+
+const (
+	GroupByMonth       = storage.GroupByMonth
+	GroupByServiceName = storage.GroupByServiceName
+	GroupByUserID      = storage.GroupByUserID
+)
+
 type TotalRequest struct {
 	UserID      *uuid.UUID `json:"user_id"`
 	ServiceName *string    `json:"service_name"`
 	From        string     `json:"from"`
 	To          string     `json:"to"`
+	GroupBy     []string   `json:"group_by"`
+	// Currency is the currency the totals are converted into, the
+	// configured default when empty.
+	Currency string `json:"currency"`
+}
+type TotalBucket struct {
+	Month       *string    `json:"month,omitempty"`
+	ServiceName *string    `json:"service_name,omitempty"`
+	UserID      *uuid.UUID `json:"user_id,omitempty"`
+	// Total is what is charged in the period; NormalizedTotal counts the
+	// monthly price of every subscription for each month it is active.
+	// Both are exact sums in minor units of the currency.
+	Total           money.Amount `json:"total"`
+	NormalizedTotal money.Amount `json:"normalized_total"`
+	Count           int          `json:"count"`
 }
 type TotalResponse struct {
-	Total int `json:"total"`
+	Currency        string        `json:"currency"`
+	Total           money.Amount  `json:"total"`
+	NormalizedTotal money.Amount  `json:"normalized_total"`
+	Buckets         []TotalBucket `json:"buckets,omitempty"`
 }
 
 type Service struct {
-	log    *slog.Logger
-	config *Config
-	db     storage.SubscriptionsStorage
+	log      *slog.Logger
+	config   *Config
+	db       storage.SubscriptionsStorage
+	stop     chan struct{}
+	stopOnce sync.Once
 }
 
 func NewService(
@@ -92,17 +236,99 @@ func NewService(
 		log:    logger,
 		config: config,
 		db:     db,
+		stop:   make(chan struct{}),
 	}
 }
 
+// requestLogger returns the request-scoped logger from ctx, falling back to
+// the service logger.
+func (s *Service) requestLogger(ctx context.Context) *slog.Logger {
+	return logging.FromContext(ctx, s.log)
+}
+
 func (s *Service) Init() error {
+	if s.config == nil {
+		return nil
+	}
+	switch s.config.OverlapPolicy {
+	case "", OverlapOff, OverlapWarn, OverlapReject:
+	default:
+		return fmt.Errorf("unknown overlap policy %q", s.config.OverlapPolicy)
+	}
+	if s.config.DefaultCurrency != "" && !validCurrency(s.config.DefaultCurrency) {
+		return fmt.Errorf("invalid default currency %q", s.config.DefaultCurrency)
+	}
 	return nil
 }
 
-func (s *Service) Run(ctx context.Context) {
+func (s *Service) overlapPolicy() storage.OverlapPolicy {
+	if s.config == nil {
+		return storage.OverlapOff
+	}
+	return storage.OverlapPolicy(s.config.OverlapPolicy)
+}
 
+// currency is the given currency or, when it is empty, the configured
+// default.
+func (s *Service) currency(currency string) string {
+	switch {
+	case currency != "":
+		return currency
+	case s.config != nil && s.config.DefaultCurrency != "":
+		return s.config.DefaultCurrency
+	default:
+		return storage.DefaultCurrency
+	}
 }
 
-func (s *Service) Stop() {
+// Run removes expired idempotency keys and purges subscriptions deleted
+// longer than the retention ago until ctx is done or the service is stopped.
+func (s *Service) Run(ctx context.Context) error {
+	if s.config == nil {
+		return nil
+	}
+
+	cleanup, stopCleanup := every(s.config.IdempotencyCleanupInterval)
+	defer stopCleanup()
 
+	purgeInterval := s.config.PurgeInterval
+	if s.config.DeletedRetention <= 0 {
+		purgeInterval = 0
+	}
+	purge, stopPurge := every(purgeInterval)
+	defer stopPurge()
+
+	if cleanup == nil && purge == nil {
+		return nil
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.stop:
+			return nil
+		case <-cleanup:
+			s.deleteExpiredIdempotencyKeys(ctx)
+		case <-purge:
+			s.purgeDeleted(ctx)
+		}
+	}
+}
+
+// every ticks once per interval, or never when the interval is not
+// positive.
+func every(interval time.Duration) (<-chan time.Time, func()) {
+	if interval <= 0 {
+		return nil, func() {}
+	}
+	ticker := time.NewTicker(interval)
+	return ticker.C, ticker.Stop
+}
+
+func (s *Service) Health(_ context.Context) error {
+	return nil
+}
+
+func (s *Service) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
 }
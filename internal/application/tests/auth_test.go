@@ -0,0 +1,249 @@
+package tests
+
+import (
+	"context"
+	"github.com/azaliaz/subs-api/internal/application"
+	"github.com/azaliaz/subs-api/internal/storage"
+	"github.com/azaliaz/subs-api/internal/storage/mocks"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+const testSecret = "test"
+
+func signToken(t *testing.T, key interface{}, method jwt.SigningMethod, claims jwt.MapClaims) string {
+	t.Helper()
+	if secret, ok := key.(string); ok {
+		key = []byte(secret)
+	}
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	require.NoError(t, err)
+	return token
+}
+
+func TestAuthenticate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	userID := uuid.New()
+	expires := time.Now().Add(time.Hour).Unix()
+
+	tests := []struct {
+		name    string
+		token   string
+		want    *application.Principal
+		wantErr bool
+	}{
+		{
+			name:  "user token",
+			token: signToken(t, testSecret, jwt.SigningMethodHS256, jwt.MapClaims{"sub": userID.String(), "exp": expires}),
+			want:  &application.Principal{Subject: userID, Role: application.RoleUser},
+		},
+		{
+			name: "admin token",
+			token: signToken(t, testSecret, jwt.SigningMethodHS512, jwt.MapClaims{
+				"sub": userID.String(), "role": application.RoleAdmin, "exp": expires,
+			}),
+			want: &application.Principal{Subject: userID, Role: application.RoleAdmin},
+		},
+		{
+			name:    "empty token",
+			token:   "",
+			wantErr: true,
+		},
+		{
+			name:    "wrong secret",
+			token:   signToken(t, "other", jwt.SigningMethodHS256, jwt.MapClaims{"sub": userID.String(), "exp": expires}),
+			wantErr: true,
+		},
+		{
+			name:    "expired",
+			token:   signToken(t, testSecret, jwt.SigningMethodHS256, jwt.MapClaims{"sub": userID.String(), "exp": time.Now().Add(-time.Minute).Unix()}),
+			wantErr: true,
+		},
+		{
+			name:    "missing expiration",
+			token:   signToken(t, testSecret, jwt.SigningMethodHS256, jwt.MapClaims{"sub": userID.String()}),
+			wantErr: true,
+		},
+		{
+			name:    "subject is not a uuid",
+			token:   signToken(t, testSecret, jwt.SigningMethodHS256, jwt.MapClaims{"sub": "alice", "exp": expires}),
+			wantErr: true,
+		},
+		{
+			name:    "unknown role",
+			token:   signToken(t, testSecret, jwt.SigningMethodHS256, jwt.MapClaims{"sub": userID.String(), "role": "root", "exp": expires}),
+			wantErr: true,
+		},
+		{
+			name:    "unsigned token",
+			token:   signToken(t, jwt.UnsafeAllowNoneSignatureType, jwt.SigningMethodNone, jwt.MapClaims{"sub": userID.String(), "exp": expires}),
+			wantErr: true,
+		},
+	}
+
+	svc := application.NewService(slog.Default(), &application.Config{Secret: testSecret}, mocks.NewMockSubscriptionsStorage(ctrl))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := svc.Authenticate(context.Background(), tt.token)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, application.ErrUnauthorized)
+				assert.Nil(t, got)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestAuthorization(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	owner := uuid.New()
+	stranger := uuid.New()
+	subID := uuid.New()
+
+	userCtx := func(id uuid.UUID) context.Context {
+		return application.WithPrincipal(context.Background(), &application.Principal{Subject: id, Role: application.RoleUser})
+	}
+	adminCtx := application.WithPrincipal(context.Background(), &application.Principal{Subject: stranger, Role: application.RoleAdmin})
+
+	newService := func() (*application.Service, *mocks.MockSubscriptionsStorage) {
+		mockStorage := mocks.NewMockSubscriptionsStorage(ctrl)
+		return application.NewService(slog.Default(), &application.Config{Secret: testSecret}, mockStorage), mockStorage
+	}
+
+	t.Run("create for another user is forbidden", func(t *testing.T) {
+		svc, _ := newService()
+		_, err := svc.Create(userCtx(stranger), &application.CreateRequest{
+			UserID: owner, ServiceName: "Netflix", Price: "10", StartDate: "09-2025",
+		})
+		assert.ErrorIs(t, err, application.ErrForbidden)
+	})
+
+	t.Run("get info of another user is not found", func(t *testing.T) {
+		svc, mockStorage := newService()
+		mockStorage.EXPECT().GetInfo(gomock.Any(), subID).
+			Return(&storage.GetInfoResponse{ID: subID, UserID: owner}, nil)
+
+		_, err := svc.GetInfo(userCtx(stranger), &application.GetInfoRequest{ID: subID})
+		assert.ErrorIs(t, err, application.ErrNotFound)
+	})
+
+	t.Run("update of another user is not found", func(t *testing.T) {
+		svc, mockStorage := newService()
+		mockStorage.EXPECT().GetInfo(gomock.Any(), subID).
+			Return(&storage.GetInfoResponse{ID: subID, UserID: owner}, nil)
+
+		_, err := svc.Update(userCtx(stranger), subID, &application.UpdateRequest{})
+		assert.ErrorIs(t, err, application.ErrNotFound)
+	})
+
+	t.Run("delete of another user is not found", func(t *testing.T) {
+		svc, mockStorage := newService()
+		mockStorage.EXPECT().GetInfo(gomock.Any(), subID).
+			Return(&storage.GetInfoResponse{ID: subID, UserID: owner}, nil)
+
+		_, err := svc.Delete(userCtx(stranger), &application.DeleteRequest{ID: subID})
+		assert.ErrorIs(t, err, application.ErrNotFound)
+	})
+
+	t.Run("owner can delete", func(t *testing.T) {
+		svc, mockStorage := newService()
+		mockStorage.EXPECT().GetInfo(gomock.Any(), subID).
+			Return(&storage.GetInfoResponse{ID: subID, UserID: owner}, nil)
+		mockStorage.EXPECT().Delete(gomock.Any(), &storage.DeleteRequest{ID: subID}).Return(nil)
+
+		resp, err := svc.Delete(userCtx(owner), &application.DeleteRequest{ID: subID})
+		require.NoError(t, err)
+		assert.True(t, resp.Deleted)
+	})
+
+	t.Run("list is narrowed to the caller", func(t *testing.T) {
+		svc, mockStorage := newService()
+		mockStorage.EXPECT().List(gomock.Any(), &storage.ListRequest{UserIDs: []uuid.UUID{owner}, Sort: storage.SortByStartDate}).
+			Return(&storage.ListResponse{}, nil)
+
+		_, err := svc.List(userCtx(owner), &application.ListRequest{})
+		require.NoError(t, err)
+	})
+
+	t.Run("list of another user is forbidden", func(t *testing.T) {
+		svc, _ := newService()
+		_, err := svc.List(userCtx(stranger), &application.ListRequest{UserIDs: []uuid.UUID{owner}})
+		assert.ErrorIs(t, err, application.ErrForbidden)
+
+		_, err = svc.List(userCtx(stranger), &application.ListRequest{UserIDs: []uuid.UUID{stranger, owner}})
+		assert.ErrorIs(t, err, application.ErrForbidden)
+	})
+
+	t.Run("total is narrowed to the caller", func(t *testing.T) {
+		svc, mockStorage := newService()
+		mockStorage.EXPECT().GetTotalSubscriptionsPrice(gomock.Any(), &storage.TotalRequest{
+			UserID: &owner, From: "01-2025", To: "12-2025", Currency: "RUB",
+		}).Return(&storage.TotalResponse{Total: rub(10)}, nil)
+
+		resp, err := svc.GetTotalSubscriptionsPrice(userCtx(owner), &application.TotalRequest{From: "01-2025", To: "12-2025"})
+		require.NoError(t, err)
+		assert.Equal(t, rub(10), resp.Total)
+	})
+
+	t.Run("admin can total across users", func(t *testing.T) {
+		svc, mockStorage := newService()
+		mockStorage.EXPECT().GetTotalSubscriptionsPrice(gomock.Any(), &storage.TotalRequest{
+			From: "01-2025", To: "12-2025", Currency: "RUB",
+		}).Return(&storage.TotalResponse{Total: rub(30)}, nil)
+
+		resp, err := svc.GetTotalSubscriptionsPrice(adminCtx, &application.TotalRequest{From: "01-2025", To: "12-2025"})
+		require.NoError(t, err)
+		assert.Equal(t, rub(30), resp.Total)
+	})
+
+	t.Run("admin can list another user", func(t *testing.T) {
+		svc, mockStorage := newService()
+		mockStorage.EXPECT().List(gomock.Any(), &storage.ListRequest{UserIDs: []uuid.UUID{owner}, Sort: storage.SortByStartDate}).
+			Return(&storage.ListResponse{}, nil)
+
+		_, err := svc.List(adminCtx, &application.ListRequest{UserIDs: []uuid.UUID{owner}})
+		require.NoError(t, err)
+	})
+
+	t.Run("admin can list several users", func(t *testing.T) {
+		svc, mockStorage := newService()
+		mockStorage.EXPECT().List(gomock.Any(), &storage.ListRequest{UserIDs: []uuid.UUID{owner, stranger}, Sort: storage.SortByStartDate}).
+			Return(&storage.ListResponse{}, nil)
+
+		_, err := svc.List(adminCtx, &application.ListRequest{UserIDs: []uuid.UUID{owner, stranger}})
+		require.NoError(t, err)
+	})
+
+	t.Run("calls without a principal are unauthorized", func(t *testing.T) {
+		svc, mockStorage := newService()
+		mockStorage.EXPECT().GetInfo(gomock.Any(), subID).
+			Return(&storage.GetInfoResponse{ID: subID, UserID: owner}, nil)
+		ctx := context.Background()
+
+		_, err := svc.GetInfo(ctx, &application.GetInfoRequest{ID: subID})
+		assert.ErrorIs(t, err, application.ErrUnauthorized)
+		_, err = svc.Delete(ctx, &application.DeleteRequest{ID: subID})
+		assert.ErrorIs(t, err, application.ErrUnauthorized)
+		_, err = svc.List(ctx, &application.ListRequest{})
+		assert.ErrorIs(t, err, application.ErrUnauthorized)
+		_, err = svc.GetTotalSubscriptionsPrice(ctx, &application.TotalRequest{From: "01-2025", To: "12-2025"})
+		assert.ErrorIs(t, err, application.ErrUnauthorized)
+		_, err = svc.Create(ctx, &application.CreateRequest{
+			UserID: owner, ServiceName: "Netflix", Price: "10", StartDate: "09-2025",
+		})
+		assert.ErrorIs(t, err, application.ErrUnauthorized)
+	})
+}
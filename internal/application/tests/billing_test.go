@@ -0,0 +1,116 @@
+package tests
+
+import (
+	"context"
+	"github.com/azaliaz/subs-api/internal/application"
+	"github.com/azaliaz/subs-api/internal/storage"
+	"github.com/azaliaz/subs-api/internal/storage/mocks"
+	"github.com/azaliaz/subs-api/pkg/money"
+	"github.com/azaliaz/subs-api/pkg/optional"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"testing"
+)
+
+func TestCreateBillingPeriod(t *testing.T) {
+	tests := []struct {
+		name       string
+		period     string
+		months     *int
+		anchor     *string
+		wantMonths int
+	}{
+		{name: "monthly by default", wantMonths: 1},
+		{name: "quarterly", period: application.BillingQuarter, wantMonths: 3},
+		{name: "yearly with anchor", period: application.BillingYear, anchor: ptr("01-2026"), wantMonths: 12},
+		{name: "custom", period: application.BillingCustom, months: ptr(18), wantMonths: 18},
+		{name: "months without period", months: ptr(6), wantMonths: 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			id := uuid.New()
+			mockStorage := mocks.NewMockSubscriptionsStorage(ctrl)
+			mockStorage.EXPECT().Create(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, req *storage.CreateRequest) (*storage.CreateResponse, error) {
+					assert.Equal(t, tt.wantMonths, req.BillingPeriodMonths)
+					assert.Equal(t, tt.anchor, req.BillingAnchor)
+					return &storage.CreateResponse{ID: id}, nil
+				})
+
+			svc := application.NewService(slog.Default(), &application.Config{}, mockStorage)
+			got, err := svc.Create(adminContext(), &application.CreateRequest{
+				UserID:              uuid.New(),
+				ServiceName:         "Yandex Plus",
+				Price:               "2990",
+				StartDate:           "07-2025",
+				BillingPeriod:       tt.period,
+				BillingPeriodMonths: tt.months,
+				BillingAnchor:       tt.anchor,
+			})
+
+			require.NoError(t, err)
+			assert.Equal(t, id, got.ID)
+		})
+	}
+}
+
+func TestUpdateBillingPeriod(t *testing.T) {
+	tests := []struct {
+		name       string
+		req        *application.UpdateRequest
+		wantMonths optional.Value[int]
+		wantAnchor optional.Value[string]
+	}{
+		{
+			name: "unchanged",
+			req:  &application.UpdateRequest{Price: optional.Of(money.Decimal("300"))},
+		},
+		{
+			name:       "named period",
+			req:        &application.UpdateRequest{BillingPeriod: optional.Of(application.BillingYear)},
+			wantMonths: optional.Of(12),
+		},
+		{
+			name:       "months only",
+			req:        &application.UpdateRequest{BillingPeriodMonths: optional.Of(2)},
+			wantMonths: optional.Of(2),
+		},
+		{
+			name:       "anchor cleared",
+			req:        &application.UpdateRequest{BillingAnchor: optional.Null[string]()},
+			wantAnchor: optional.Null[string](),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			id := uuid.New()
+			stored := &storage.GetInfoResponse{ID: id, Price: rub(2990), StartDate: "07-2025", BillingPeriodMonths: 12}
+			mockStorage := mocks.NewMockSubscriptionsStorage(ctrl)
+			mockStorage.EXPECT().GetInfo(gomock.Any(), id).Return(stored, nil)
+			mockStorage.EXPECT().Update(gomock.Any(), id, gomock.Any()).
+				DoAndReturn(func(_ context.Context, _ uuid.UUID, req *storage.UpdateRequest) (*storage.UpdateResponse, error) {
+					assert.Equal(t, tt.wantMonths, req.BillingPeriodMonths)
+					assert.Equal(t, tt.wantAnchor, req.BillingAnchor)
+					return &storage.UpdateResponse{GetInfoResponse: *stored}, nil
+				})
+
+			svc := application.NewService(slog.Default(), &application.Config{}, mockStorage)
+			got, err := svc.Update(adminContext(), id, tt.req)
+
+			require.NoError(t, err)
+			assert.Equal(t, application.BillingYear, got.BillingPeriod)
+			assert.Equal(t, money.Amount{Minor: 24917, Exponent: 2}, got.MonthlyPrice)
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"fmt"
+	"github.com/azaliaz/subs-api/internal/application"
+	"github.com/azaliaz/subs-api/internal/storage"
+	"github.com/azaliaz/subs-api/internal/storage/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"testing"
+)
+
+func TestErrorKinds(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	id := uuid.New()
+	start := "09-2025"
+	end := "08-2025"
+
+	tests := []struct {
+		name string
+		call func(svc *application.Service, mockStorage *mocks.MockSubscriptionsStorage) error
+		want error
+	}{
+		{
+			name: "end before start",
+			call: func(svc *application.Service, _ *mocks.MockSubscriptionsStorage) error {
+				_, err := svc.Create(adminContext(), &application.CreateRequest{
+					UserID:      uuid.New(),
+					ServiceName: "Netflix",
+					Price:       "10",
+					StartDate:   start,
+					EndDate:     &end,
+				})
+				return err
+			},
+			want: application.ErrValidation,
+		},
+		{
+			name: "get missing subscription",
+			call: func(svc *application.Service, mockStorage *mocks.MockSubscriptionsStorage) error {
+				mockStorage.EXPECT().GetInfo(gomock.Any(), id).Return(nil, nil)
+				_, err := svc.GetInfo(adminContext(), &application.GetInfoRequest{ID: id})
+				return err
+			},
+			want: application.ErrNotFound,
+		},
+		{
+			name: "update missing subscription",
+			call: func(svc *application.Service, mockStorage *mocks.MockSubscriptionsStorage) error {
+				mockStorage.EXPECT().GetInfo(gomock.Any(), id).Return(nil, nil)
+				_, err := svc.Update(adminContext(), id, &application.UpdateRequest{})
+				return err
+			},
+			want: application.ErrNotFound,
+		},
+		{
+			name: "delete missing subscription",
+			call: func(svc *application.Service, mockStorage *mocks.MockSubscriptionsStorage) error {
+				mockStorage.EXPECT().Delete(gomock.Any(), gomock.Any()).
+					Return(fmt.Errorf("%w: subscription with id %s", storage.ErrNotFound, id))
+				_, err := svc.Delete(adminContext(), &application.DeleteRequest{ID: id})
+				return err
+			},
+			want: application.ErrNotFound,
+		},
+		{
+			name: "delete with stale version",
+			call: func(svc *application.Service, mockStorage *mocks.MockSubscriptionsStorage) error {
+				version := int64(1)
+				mockStorage.EXPECT().Delete(gomock.Any(), &storage.DeleteRequest{ID: id, Version: &version}).
+					Return(fmt.Errorf("%w: subscription with id %s", storage.ErrPreconditionFailed, id))
+				_, err := svc.Delete(adminContext(), &application.DeleteRequest{ID: id, Version: &version})
+				return err
+			},
+			want: application.ErrPreconditionFailed,
+		},
+		{
+			name: "storage conflict",
+			call: func(svc *application.Service, mockStorage *mocks.MockSubscriptionsStorage) error {
+				mockStorage.EXPECT().Create(gomock.Any(), gomock.Any()).
+					Return(nil, fmt.Errorf("%w: duplicate key", storage.ErrConflict))
+				_, err := svc.Create(adminContext(), &application.CreateRequest{
+					UserID:      uuid.New(),
+					ServiceName: "Netflix",
+					Price:       "10",
+					StartDate:   start,
+				})
+				return err
+			},
+			want: application.ErrConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := mocks.NewMockSubscriptionsStorage(ctrl)
+			svc := application.NewService(slog.Default(), &application.Config{Secret: "test"}, mockStorage)
+
+			assert.ErrorIs(t, tt.call(svc, mockStorage), tt.want)
+		})
+	}
+}
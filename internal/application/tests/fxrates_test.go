@@ -0,0 +1,208 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"github.com/azaliaz/subs-api/internal/application"
+	"github.com/azaliaz/subs-api/internal/storage"
+	"github.com/azaliaz/subs-api/internal/storage/mocks"
+	"github.com/azaliaz/subs-api/pkg/money"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"testing"
+)
+
+func TestDefaultCurrencyConfig(t *testing.T) {
+	for _, currency := range []string{"", "RUB", "USD"} {
+		svc := application.NewService(slog.Default(), &application.Config{DefaultCurrency: currency}, nil)
+		assert.NoError(t, svc.Init(), currency)
+	}
+
+	svc := application.NewService(slog.Default(), &application.Config{DefaultCurrency: "rub"}, nil)
+	assert.ErrorContains(t, svc.Init(), `invalid default currency "rub"`)
+}
+
+func TestCreateCurrency(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		currency string
+		want     string
+	}{
+		{name: "built-in default", want: "RUB"},
+		{name: "configured default", config: "EUR", want: "EUR"},
+		{name: "explicit", config: "EUR", currency: "USD", want: "USD"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockStorage := mocks.NewMockSubscriptionsStorage(ctrl)
+			mockStorage.EXPECT().Create(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, req *storage.CreateRequest) (*storage.CreateResponse, error) {
+					assert.Equal(t, tt.want, req.Currency)
+					return &storage.CreateResponse{ID: uuid.New()}, nil
+				})
+
+			svc := application.NewService(slog.Default(), &application.Config{DefaultCurrency: tt.config}, mockStorage)
+			_, err := svc.Create(adminContext(), &application.CreateRequest{
+				UserID:      uuid.New(),
+				ServiceName: "Spotify",
+				Price:       "10",
+				Currency:    tt.currency,
+				StartDate:   "07-2025",
+			})
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestCurrencyValidate(t *testing.T) {
+	err := (&application.CreateRequest{
+		UserID: uuid.New(), ServiceName: "Spotify", Price: "10", Currency: "usd", StartDate: "07-2025",
+	}).Validate()
+	assert.Equal(t, application.FieldErrors{"currency": "must be an ISO 4217 code such as RUB"}, fieldErrors(t, err))
+
+	err = (&application.TotalRequest{From: "01-2025", To: "02-2025", Currency: "EURO"}).Validate()
+	assert.Equal(t, application.FieldErrors{"currency": "must be an ISO 4217 code such as RUB"}, fieldErrors(t, err))
+}
+
+func TestTotalCurrency(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mocks.NewMockSubscriptionsStorage(ctrl)
+	svc := application.NewService(slog.Default(), &application.Config{DefaultCurrency: "EUR"}, mockStorage)
+
+	t.Run("converted", func(t *testing.T) {
+		mockStorage.EXPECT().GetTotalSubscriptionsPrice(gomock.Any(), &storage.TotalRequest{
+			From: "01-2025", To: "02-2025", Currency: "USD",
+		}).Return(&storage.TotalResponse{Currency: "USD", Total: money.Amount{Minor: 2550, Exponent: 2}}, nil)
+
+		resp, err := svc.GetTotalSubscriptionsPrice(adminContext(), &application.TotalRequest{
+			From: "01-2025", To: "02-2025", Currency: "USD",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, &application.TotalResponse{Currency: "USD", Total: money.Amount{Minor: 2550, Exponent: 2}}, resp)
+	})
+
+	t.Run("missing rate", func(t *testing.T) {
+		mockStorage.EXPECT().GetTotalSubscriptionsPrice(gomock.Any(), &storage.TotalRequest{
+			From: "01-2025", To: "02-2025", Currency: "EUR",
+		}).Return(nil, fmt.Errorf("%w: no RUB/EUR rate for 02-2025", storage.ErrMissingFXRate))
+
+		_, err := svc.GetTotalSubscriptionsPrice(adminContext(), &application.TotalRequest{
+			From: "01-2025", To: "02-2025",
+		})
+		assert.ErrorIs(t, err, application.ErrMissingFXRate)
+		assert.EqualError(t, err, "missing exchange rate: no RUB/EUR rate for 02-2025")
+	})
+}
+
+func TestFXRatesAuthorization(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	userCtx := application.WithPrincipal(context.Background(), &application.Principal{Subject: uuid.New(), Role: application.RoleUser})
+	adminCtx := application.WithPrincipal(context.Background(), &application.Principal{Subject: uuid.New(), Role: application.RoleAdmin})
+	rate := &application.FXRate{Base: "USD", Quote: "RUB", Month: "01-2025", Rate: "100"}
+
+	mockStorage := mocks.NewMockSubscriptionsStorage(ctrl)
+	svc := application.NewService(slog.Default(), &application.Config{}, mockStorage)
+
+	t.Run("users may read", func(t *testing.T) {
+		mockStorage.EXPECT().ListFXRates(gomock.Any(), &storage.FXRatesRequest{}).
+			Return([]storage.FXRate{storage.FXRate(*rate)}, nil)
+
+		resp, err := svc.ListFXRates(userCtx, &application.FXRatesRequest{})
+		require.NoError(t, err)
+		assert.Equal(t, []application.FXRate{*rate}, resp.Rates)
+	})
+
+	t.Run("users may not write", func(t *testing.T) {
+		_, err := svc.PutFXRate(userCtx, rate)
+		assert.ErrorIs(t, err, application.ErrForbidden)
+		_, err = svc.ImportFXRates(userCtx, &application.ImportFXRatesRequest{Rates: []application.FXRate{*rate}})
+		assert.ErrorIs(t, err, application.ErrForbidden)
+		_, err = svc.DeleteFXRate(userCtx, &application.FXRateKey{Base: "USD", Quote: "RUB", Month: "01-2025"})
+		assert.ErrorIs(t, err, application.ErrForbidden)
+	})
+
+	t.Run("admin", func(t *testing.T) {
+		mockStorage.EXPECT().SaveFXRates(gomock.Any(), []storage.FXRate{storage.FXRate(*rate)}).Return(nil)
+		got, err := svc.PutFXRate(adminCtx, rate)
+		require.NoError(t, err)
+		assert.Equal(t, rate, got)
+
+		key := &storage.FXRateKey{Base: "USD", Quote: "RUB", Month: "02-2025"}
+		mockStorage.EXPECT().DeleteFXRate(gomock.Any(), key).
+			Return(fmt.Errorf("%w: USD/RUB rate for 02-2025", storage.ErrNotFound))
+		_, err = svc.DeleteFXRate(adminCtx, &application.FXRateKey{Base: "USD", Quote: "RUB", Month: "02-2025"})
+		assert.ErrorIs(t, err, application.ErrNotFound)
+	})
+}
+
+func TestImportFXRatesValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		rates []application.FXRate
+		want  application.FieldErrors
+	}{
+		{
+			name: "valid",
+			rates: []application.FXRate{
+				{Base: "USD", Quote: "RUB", Month: "01-2025", Rate: "100"},
+				{Base: "USD", Quote: "RUB", Month: "02-2025", Rate: "95.5"},
+			},
+		},
+		{
+			name: "empty",
+			want: application.FieldErrors{"rates": "must not be empty"},
+		},
+		{
+			name: "invalid rows",
+			rates: []application.FXRate{
+				{Base: "usd", Quote: "RUB", Month: "01-2025", Rate: "100"},
+				{Base: "EUR", Quote: "EUR", Month: "2025-01", Rate: "0"},
+			},
+			want: application.FieldErrors{
+				"rates[0].base":  "must be an ISO 4217 code such as RUB",
+				"rates[1].quote": "must differ from rates[1].base",
+				"rates[1].month": "must be a month in MM-YYYY format",
+				"rates[1].rate":  "must be greater than 0",
+			},
+		},
+		{
+			name: "rate precision",
+			rates: []application.FXRate{
+				{Base: "JPY", Quote: "RUB", Month: "01-2025", Rate: "0.6123456789"},
+				{Base: "JPY", Quote: "USD", Month: "01-2025", Rate: "0.00666666667"},
+				{Base: "EUR", Quote: "RUB", Month: "01-2025"},
+			},
+			want: application.FieldErrors{
+				"rates[1].rate": "must be a decimal number with at most 10 decimal places",
+				"rates[2].rate": "is required",
+			},
+		},
+		{
+			name: "duplicate",
+			rates: []application.FXRate{
+				{Base: "USD", Quote: "RUB", Month: "01-2025", Rate: "100"},
+				{Base: "USD", Quote: "RUB", Month: "01-2025", Rate: "101"},
+			},
+			want: application.FieldErrors{"rates[1].month": "duplicates rates[0]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&application.ImportFXRatesRequest{Rates: tt.rates}).Validate()
+			assert.Equal(t, tt.want, fieldErrors(t, err))
+		})
+	}
+}
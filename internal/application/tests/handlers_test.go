@@ -7,11 +7,14 @@ import (
 	"github.com/azaliaz/subs-api/internal/application"
 	"github.com/azaliaz/subs-api/internal/storage"
 	"github.com/azaliaz/subs-api/internal/storage/mocks"
+	"github.com/azaliaz/subs-api/pkg/money"
+	"github.com/azaliaz/subs-api/pkg/optional"
 	"github.com/golang/mock/gomock"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 	"log/slog"
 	"testing"
+	"time"
 )
 
 func TestCreateSubscription(t *testing.T) {
@@ -28,7 +31,7 @@ func TestCreateSubscription(t *testing.T) {
 			req: &application.CreateRequest{
 				UserID:      uuid.New(),
 				ServiceName: "Netflix",
-				Price:       10,
+				Price:       "10",
 				StartDate:   "09-2025",
 				EndDate:     func() *string { s := "12-2025"; return &s }(),
 			},
@@ -44,7 +47,7 @@ func TestCreateSubscription(t *testing.T) {
 			name: "nil request",
 			req:  nil,
 			want: func(mockStorage *mocks.MockSubscriptionsStorage) (*application.CreateResponse, error) {
-				return nil, errors.New("request cannot be nil")
+				return nil, fmt.Errorf("%w: request cannot be nil", application.ErrValidation)
 			},
 		},
 		{
@@ -52,11 +55,11 @@ func TestCreateSubscription(t *testing.T) {
 			req: &application.CreateRequest{
 				UserID:      uuid.Nil,
 				ServiceName: "Netflix",
-				Price:       10,
+				Price:       "10",
 				StartDate:   "09-2025",
 			},
 			want: func(mockStorage *mocks.MockSubscriptionsStorage) (*application.CreateResponse, error) {
-				return nil, errors.New("user_id is required")
+				return nil, fmt.Errorf("%w: user_id: is required", application.ErrValidation)
 			},
 		},
 		{
@@ -64,11 +67,11 @@ func TestCreateSubscription(t *testing.T) {
 			req: &application.CreateRequest{
 				UserID:      uuid.New(),
 				ServiceName: "",
-				Price:       10,
+				Price:       "10",
 				StartDate:   "09-2025",
 			},
 			want: func(mockStorage *mocks.MockSubscriptionsStorage) (*application.CreateResponse, error) {
-				return nil, errors.New("service_name is required")
+				return nil, fmt.Errorf("%w: service_name: is required", application.ErrValidation)
 			},
 		},
 		{
@@ -76,11 +79,11 @@ func TestCreateSubscription(t *testing.T) {
 			req: &application.CreateRequest{
 				UserID:      uuid.New(),
 				ServiceName: "Netflix",
-				Price:       0,
+				Price:       "0",
 				StartDate:   "09-2025",
 			},
 			want: func(mockStorage *mocks.MockSubscriptionsStorage) (*application.CreateResponse, error) {
-				return nil, errors.New("price must be greater than 0")
+				return nil, fmt.Errorf("%w: price: must be greater than 0 and at most 1000000", application.ErrValidation)
 			},
 		},
 		{
@@ -88,11 +91,11 @@ func TestCreateSubscription(t *testing.T) {
 			req: &application.CreateRequest{
 				UserID:      uuid.New(),
 				ServiceName: "Netflix",
-				Price:       10,
+				Price:       "10",
 				StartDate:   "2025-09",
 			},
 			want: func(mockStorage *mocks.MockSubscriptionsStorage) (*application.CreateResponse, error) {
-				return nil, fmt.Errorf("invalid start_date format, expected MM-YYYY")
+				return nil, fmt.Errorf("%w: start_date: must be a month in MM-YYYY format", application.ErrValidation)
 			},
 		},
 		{
@@ -100,12 +103,12 @@ func TestCreateSubscription(t *testing.T) {
 			req: &application.CreateRequest{
 				UserID:      uuid.New(),
 				ServiceName: "Netflix",
-				Price:       10,
+				Price:       "10",
 				StartDate:   "09-2025",
 				EndDate:     func() *string { s := "2025-12"; return &s }(),
 			},
 			want: func(mockStorage *mocks.MockSubscriptionsStorage) (*application.CreateResponse, error) {
-				return nil, fmt.Errorf("invalid end_date format, expected MM-YYYY")
+				return nil, fmt.Errorf("%w: end_date: must be a month in MM-YYYY format", application.ErrValidation)
 			},
 		},
 	}
@@ -121,16 +124,16 @@ func TestCreateSubscription(t *testing.T) {
 				mockStorage,
 			)
 
-			got, err := svc.Create(context.Background(), tt.req)
+			got, err := svc.Create(adminContext(), tt.req)
 
 			if wantErr != nil {
 				assert.Error(t, err)
 
 				switch tt.name {
 				case "invalid StartDate":
-					assert.ErrorContains(t, err, "invalid start_date format, expected MM-YYYY")
+					assert.ErrorContains(t, err, "start_date: must be a month in MM-YYYY format")
 				case "invalid EndDate":
-					assert.ErrorContains(t, err, "invalid end_date format, expected MM-YYYY")
+					assert.ErrorContains(t, err, "end_date: must be a month in MM-YYYY format")
 				}
 
 				assert.Nil(t, got)
@@ -149,6 +152,8 @@ func TestGetInfo(t *testing.T) {
 
 	validID := uuid.New()
 	userID := uuid.New()
+	createdAt := time.Date(2025, 9, 1, 10, 0, 0, 0, time.UTC)
+	updatedAt := time.Date(2025, 9, 2, 10, 0, 0, 0, time.UTC)
 
 	tests := []struct {
 		name string
@@ -162,20 +167,28 @@ func TestGetInfo(t *testing.T) {
 				mockStorage.EXPECT().
 					GetInfo(gomock.Any(), validID).
 					Return(&storage.GetInfoResponse{
-						ID:          validID,
-						UserID:      userID,
-						ServiceName: "Netflix",
-						Price:       10,
-						StartDate:   "09-2025",
-						EndDate:     func() *string { s := "12-2025"; return &s }(),
+						ID:                  validID,
+						UserID:              userID,
+						ServiceName:         "Netflix",
+						Price:               rub(10),
+						StartDate:           "09-2025",
+						EndDate:             func() *string { s := "12-2025"; return &s }(),
+						BillingPeriodMonths: 12,
+						CreatedAt:           createdAt,
+						UpdatedAt:           updatedAt,
 					}, nil)
 				return &application.GetInfoResponse{
-					ID:          validID,
-					UserID:      userID,
-					ServiceName: "Netflix",
-					Price:       10,
-					StartDate:   "09-2025",
-					EndDate:     func() *string { s := "12-2025"; return &s }(),
+					ID:                  validID,
+					UserID:              userID,
+					ServiceName:         "Netflix",
+					Price:               rub(10),
+					StartDate:           "09-2025",
+					EndDate:             func() *string { s := "12-2025"; return &s }(),
+					BillingPeriod:       application.BillingYear,
+					BillingPeriodMonths: 12,
+					MonthlyPrice:        money.Amount{Minor: 83, Exponent: 2},
+					CreatedAt:           createdAt,
+					UpdatedAt:           updatedAt,
 				}, nil
 			},
 		},
@@ -183,14 +196,14 @@ func TestGetInfo(t *testing.T) {
 			name: "nil request",
 			req:  nil,
 			want: func(mockStorage *mocks.MockSubscriptionsStorage) (*application.GetInfoResponse, error) {
-				return nil, errors.New("request cannot be nil")
+				return nil, fmt.Errorf("%w: request cannot be nil", application.ErrValidation)
 			},
 		},
 		{
 			name: "invalid ID",
 			req:  &application.GetInfoRequest{ID: uuid.Nil},
 			want: func(mockStorage *mocks.MockSubscriptionsStorage) (*application.GetInfoResponse, error) {
-				return nil, errors.New("id is required")
+				return nil, fmt.Errorf("%w: id is required", application.ErrValidation)
 			},
 		},
 		{
@@ -200,7 +213,7 @@ func TestGetInfo(t *testing.T) {
 				mockStorage.EXPECT().
 					GetInfo(gomock.Any(), validID).
 					Return(nil, nil)
-				return nil, nil
+				return nil, fmt.Errorf("%w: subscription with id %s", application.ErrNotFound, validID)
 			},
 		},
 		{
@@ -226,7 +239,7 @@ func TestGetInfo(t *testing.T) {
 				mockStorage,
 			)
 
-			got, err := svc.GetInfo(context.Background(), tt.req)
+			got, err := svc.GetInfo(adminContext(), tt.req)
 
 			if wantErr != nil {
 				assert.Error(t, err)
@@ -259,7 +272,7 @@ func TestListSubscriptions(t *testing.T) {
 		{
 			name: "success",
 			req: &application.ListRequest{
-				UserID:      &userID,
+				UserIDs:     []uuid.UUID{userID},
 				ServiceName: &serviceName,
 				From:        &from,
 				To:          &to,
@@ -275,7 +288,7 @@ func TestListSubscriptions(t *testing.T) {
 								ID:          uuid.New(),
 								UserID:      userID,
 								ServiceName: serviceName,
-								Price:       10,
+								Price:       rub(10),
 								StartDate:   from,
 								EndDate:     &to,
 							},
@@ -287,7 +300,7 @@ func TestListSubscriptions(t *testing.T) {
 							ID:          uuid.Nil,
 							UserID:      userID,
 							ServiceName: serviceName,
-							Price:       10,
+							Price:       rub(10),
 							StartDate:   from,
 							EndDate:     &to,
 						},
@@ -299,7 +312,7 @@ func TestListSubscriptions(t *testing.T) {
 			name: "nil request",
 			req:  nil,
 			want: func(mockStorage *mocks.MockSubscriptionsStorage) (*application.ListResponse, error) {
-				return nil, errors.New("request cannot be nil")
+				return nil, fmt.Errorf("%w: request cannot be nil", application.ErrValidation)
 			},
 		},
 		{
@@ -309,13 +322,13 @@ func TestListSubscriptions(t *testing.T) {
 				To:   func() *string { s := "2025-12"; return &s }(),
 			},
 			want: func(mockStorage *mocks.MockSubscriptionsStorage) (*application.ListResponse, error) {
-				return nil, fmt.Errorf("invalid start_date format, expected MM-YYYY: parsing time \"2025-09\": month out of range")
+				return nil, fmt.Errorf("%w: from: must be a month in MM-YYYY format; to: must be a month in MM-YYYY format", application.ErrValidation)
 			},
 		},
 		{
 			name: "storage error",
 			req: &application.ListRequest{
-				UserID:      &userID,
+				UserIDs:     []uuid.UUID{userID},
 				ServiceName: &serviceName,
 				From:        &from,
 				To:          &to,
@@ -340,7 +353,7 @@ func TestListSubscriptions(t *testing.T) {
 				mockStorage,
 			)
 
-			got, err := svc.List(context.Background(), tt.req)
+			got, err := svc.List(adminContext(), tt.req)
 
 			if wantErr != nil {
 				assert.Error(t, err)
@@ -361,15 +374,119 @@ func TestListSubscriptions(t *testing.T) {
 	}
 }
 
+func TestListSubscriptionsPageToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	cursor := &storage.ListCursor{Value: "400", ID: uuid.New()}
+	limit := 1
+	mockStorage := mocks.NewMockSubscriptionsStorage(ctrl)
+	mockStorage.EXPECT().List(gomock.Any(), &storage.ListRequest{Sort: application.SortByPrice, Desc: true, Limit: &limit}).
+		Return(&storage.ListResponse{
+			Subscriptions: []storage.GetInfoResponse{{ID: cursor.ID, Price: rub(400)}},
+			Total:         2,
+			HasMore:       true,
+			Next:          cursor,
+		}, nil)
+
+	svc := application.NewService(slog.Default(), &application.Config{Secret: "test"}, mockStorage)
+
+	first, err := svc.List(adminContext(), &application.ListRequest{Sort: "price:desc", Limit: &limit})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, first.Total)
+	assert.True(t, first.HasMore)
+	assert.NotEmpty(t, first.NextPageToken)
+
+	mockStorage.EXPECT().List(gomock.Any(), &storage.ListRequest{
+		Sort:  application.SortByPrice,
+		Desc:  true,
+		Limit: &limit,
+		After: cursor,
+	}).Return(&storage.ListResponse{Subscriptions: []storage.GetInfoResponse{{ID: uuid.New(), Price: rub(300)}}, Total: 2}, nil)
+
+	second, err := svc.List(adminContext(), &application.ListRequest{
+		Sort:      "price:desc",
+		Limit:     &limit,
+		PageToken: first.NextPageToken,
+	})
+	assert.NoError(t, err)
+	assert.False(t, second.HasMore)
+	assert.Empty(t, second.NextPageToken)
+
+	_, err = svc.List(adminContext(), &application.ListRequest{Sort: "price", PageToken: first.NextPageToken})
+	assert.ErrorIs(t, err, application.ErrValidation)
+	assert.ErrorContains(t, err, "page_token: was issued for a different sort")
+}
+
+func TestListSubscriptionsFilters(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	first, second := uuid.New(), uuid.New()
+	mockStorage := mocks.NewMockSubscriptionsStorage(ctrl)
+	mockStorage.EXPECT().List(gomock.Any(), &storage.ListRequest{
+		UserIDs:          []uuid.UUID{first, second},
+		ServiceName:      ptr("Netflix"),
+		ServiceNameExact: true,
+		PriceMin:         ptr(money.Decimal("100")),
+		PriceMax:         ptr(money.Decimal("500")),
+		Status:           application.StatusActive,
+		AsOf:             ptr("10-2025"),
+		Sort:             application.SortByEndDate,
+	}).Return(&storage.ListResponse{}, nil)
+
+	svc := application.NewService(slog.Default(), &application.Config{Secret: "test"}, mockStorage)
+	_, err := svc.List(adminContext(), &application.ListRequest{
+		UserIDs:      []uuid.UUID{first, second},
+		ServiceName:  ptr("Netflix"),
+		ServiceMatch: application.ServiceMatchExact,
+		PriceMin:     ptr(money.Decimal("100")),
+		PriceMax:     ptr(money.Decimal("500")),
+		Status:       application.StatusActive,
+		AsOf:         ptr("10-2025"),
+		Sort:         "end_date:asc",
+	})
+	assert.NoError(t, err)
+}
+
 func TestUpdateSubscription(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	validID := uuid.New()
-	validPrice := 100
 	validStart := "09-2025"
 	validEnd := "12-2025"
-	serviceName := "Netflix"
+	current := &storage.GetInfoResponse{
+		ID:                  validID,
+		UserID:              uuid.New(),
+		ServiceName:         "Netflix",
+		Price:               rub(100),
+		StartDate:           validStart,
+		EndDate:             &validEnd,
+		BillingPeriodMonths: 1,
+		CreatedAt:           time.Date(2025, 9, 1, 10, 0, 0, 0, time.UTC),
+		UpdatedAt:           time.Date(2025, 9, 1, 10, 0, 0, 0, time.UTC),
+	}
+	patched := func(patch func(sub *storage.GetInfoResponse)) (*storage.UpdateResponse, *application.UpdateResponse) {
+		sub := *current
+		patch(&sub)
+		sub.UpdatedAt = time.Date(2025, 10, 1, 10, 0, 0, 0, time.UTC)
+		return &storage.UpdateResponse{GetInfoResponse: sub}, &application.UpdateResponse{
+			GetInfoResponse: application.GetInfoResponse{
+				ID:                  sub.ID,
+				UserID:              sub.UserID,
+				ServiceName:         sub.ServiceName,
+				Price:               sub.Price,
+				StartDate:           sub.StartDate,
+				EndDate:             sub.EndDate,
+				BillingPeriod:       application.BillingMonth,
+				BillingPeriodMonths: 1,
+				MonthlyPrice:        sub.Price,
+				CreatedAt:           sub.CreatedAt,
+				UpdatedAt:           sub.UpdatedAt,
+			},
+		}
+	}
 
 	tests := []struct {
 		name string
@@ -381,16 +498,46 @@ func TestUpdateSubscription(t *testing.T) {
 			name: "success",
 			id:   validID,
 			req: &application.UpdateRequest{
-				ServiceName: &serviceName,
-				Price:       &validPrice,
-				StartDate:   &validStart,
-				EndDate:     &validEnd,
+				ServiceName: optional.Of("Kinopoisk"),
+				Price:       optional.Of[money.Decimal]("200"),
+				StartDate:   optional.Of("10-2025"),
+				EndDate:     optional.Of("01-2026"),
 			},
 			want: func(mockStorage *mocks.MockSubscriptionsStorage) (*application.UpdateResponse, error) {
+				end := "01-2026"
+				stored, want := patched(func(sub *storage.GetInfoResponse) {
+					sub.ServiceName = "Kinopoisk"
+					sub.Price = rub(200)
+					sub.StartDate = "10-2025"
+					sub.EndDate = &end
+				})
+				mockStorage.EXPECT().GetInfo(gomock.Any(), validID).Return(current, nil)
 				mockStorage.EXPECT().
-					Update(gomock.Any(), validID, gomock.Any()).
-					Return(&storage.UpdateResponse{Updated: true}, nil)
-				return &application.UpdateResponse{Updated: true}, nil
+					Update(gomock.Any(), validID, &storage.UpdateRequest{
+						ServiceName: optional.Of("Kinopoisk"),
+						Price:       optional.Of(rub(200)),
+						StartDate:   optional.Of("10-2025"),
+						EndDate:     optional.Of("01-2026"),
+					}).
+					Return(stored, nil)
+				return want, nil
+			},
+		},
+		{
+			name: "clear end date",
+			id:   validID,
+			req: &application.UpdateRequest{
+				EndDate: optional.Null[string](),
+			},
+			want: func(mockStorage *mocks.MockSubscriptionsStorage) (*application.UpdateResponse, error) {
+				stored, want := patched(func(sub *storage.GetInfoResponse) {
+					sub.EndDate = nil
+				})
+				mockStorage.EXPECT().GetInfo(gomock.Any(), validID).Return(current, nil)
+				mockStorage.EXPECT().
+					Update(gomock.Any(), validID, &storage.UpdateRequest{EndDate: optional.Null[string]()}).
+					Return(stored, nil)
+				return want, nil
 			},
 		},
 		{
@@ -398,37 +545,72 @@ func TestUpdateSubscription(t *testing.T) {
 			id:   validID,
 			req:  nil,
 			want: func(mockStorage *mocks.MockSubscriptionsStorage) (*application.UpdateResponse, error) {
-				return nil, errors.New("request cannot be nil")
+				return nil, fmt.Errorf("%w: request cannot be nil", application.ErrValidation)
 			},
 		},
 		{
 			name: "invalid price <= 0",
 			id:   validID,
 			req: &application.UpdateRequest{
-				Price: func() *int { i := 0; return &i }(),
+				Price: optional.Of[money.Decimal]("0"),
 			},
 			want: func(mockStorage *mocks.MockSubscriptionsStorage) (*application.UpdateResponse, error) {
-				return nil, errors.New("price must be greater than 0")
+				return nil, fmt.Errorf("%w: price: must be greater than 0 and at most 1000000", application.ErrValidation)
+			},
+		},
+		{
+			name: "price set to null",
+			id:   validID,
+			req: &application.UpdateRequest{
+				Price: optional.Null[money.Decimal](),
+			},
+			want: func(mockStorage *mocks.MockSubscriptionsStorage) (*application.UpdateResponse, error) {
+				return nil, fmt.Errorf("%w: price: must not be null", application.ErrValidation)
 			},
 		},
 		{
 			name: "invalid StartDate format",
 			id:   validID,
 			req: &application.UpdateRequest{
-				StartDate: func() *string { s := "2025-09"; return &s }(),
+				StartDate: optional.Of("2025-09"),
+			},
+			want: func(mockStorage *mocks.MockSubscriptionsStorage) (*application.UpdateResponse, error) {
+				return nil, fmt.Errorf("%w: start_date: must be a month in MM-YYYY format", application.ErrValidation)
+			},
+		},
+		{
+			name: "start date after stored end date",
+			id:   validID,
+			req: &application.UpdateRequest{
+				StartDate: optional.Of("01-2026"),
 			},
 			want: func(mockStorage *mocks.MockSubscriptionsStorage) (*application.UpdateResponse, error) {
-				return nil, fmt.Errorf("invalid start_date format")
+				mockStorage.EXPECT().GetInfo(gomock.Any(), validID).Return(current, nil)
+				return nil, fmt.Errorf("%w: start_date: must not be after end_date", application.ErrValidation)
+			},
+		},
+		{
+			name: "stale version",
+			id:   validID,
+			req: &application.UpdateRequest{
+				Price:   optional.Of[money.Decimal]("200"),
+				Version: func() *int64 { v := int64(1); return &v }(),
+			},
+			want: func(mockStorage *mocks.MockSubscriptionsStorage) (*application.UpdateResponse, error) {
+				changed := *current
+				changed.Version = 2
+				mockStorage.EXPECT().GetInfo(gomock.Any(), validID).Return(&changed, nil)
+				return nil, application.ErrPreconditionFailed
 			},
 		},
 		{
 			name: "storage error",
 			id:   validID,
 			req: &application.UpdateRequest{
-				ServiceName: &serviceName,
-				Price:       &validPrice,
+				Price: optional.Of[money.Decimal]("200"),
 			},
 			want: func(mockStorage *mocks.MockSubscriptionsStorage) (*application.UpdateResponse, error) {
+				mockStorage.EXPECT().GetInfo(gomock.Any(), validID).Return(current, nil)
 				mockStorage.EXPECT().
 					Update(gomock.Any(), validID, gomock.Any()).
 					Return(nil, errors.New("db error"))
@@ -436,16 +618,14 @@ func TestUpdateSubscription(t *testing.T) {
 			},
 		},
 		{
-			name: "update not found (updated=false)",
+			name: "update not found",
 			id:   validID,
 			req: &application.UpdateRequest{
-				Price: &validPrice,
+				Price: optional.Of[money.Decimal]("200"),
 			},
 			want: func(mockStorage *mocks.MockSubscriptionsStorage) (*application.UpdateResponse, error) {
-				mockStorage.EXPECT().
-					Update(gomock.Any(), validID, gomock.Any()).
-					Return(&storage.UpdateResponse{Updated: false}, nil)
-				return &application.UpdateResponse{Updated: false}, nil
+				mockStorage.EXPECT().GetInfo(gomock.Any(), validID).Return(nil, nil)
+				return nil, fmt.Errorf("%w: subscription with id %s", application.ErrNotFound, validID)
 			},
 		},
 	}
@@ -461,7 +641,137 @@ func TestUpdateSubscription(t *testing.T) {
 				mockStorage,
 			)
 
-			got, err := svc.Update(context.Background(), tt.id, tt.req)
+			got, err := svc.Update(adminContext(), tt.id, tt.req)
+
+			if wantErr != nil {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), wantErr.Error())
+				assert.Nil(t, got)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, wantResp, got)
+			}
+		})
+	}
+}
+
+func TestReplaceSubscription(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	validID := uuid.New()
+	userID := uuid.New()
+
+	tests := []struct {
+		name    string
+		req     *application.CreateRequest
+		version *int64
+		want    func(mockStorage *mocks.MockSubscriptionsStorage) (*application.UpdateResponse, error)
+	}{
+		{
+			name: "success without end date",
+			req: &application.CreateRequest{
+				UserID:      userID,
+				ServiceName: "Netflix",
+				Price:       "100",
+				StartDate:   "09-2025",
+			},
+			want: func(mockStorage *mocks.MockSubscriptionsStorage) (*application.UpdateResponse, error) {
+				stored := storage.GetInfoResponse{
+					ID:                  validID,
+					UserID:              userID,
+					ServiceName:         "Netflix",
+					Price:               rub(100),
+					StartDate:           "09-2025",
+					BillingPeriodMonths: 1,
+					CreatedAt:           time.Date(2025, 9, 1, 10, 0, 0, 0, time.UTC),
+					UpdatedAt:           time.Date(2025, 10, 1, 10, 0, 0, 0, time.UTC),
+				}
+				mockStorage.EXPECT().
+					Update(gomock.Any(), validID, &storage.UpdateRequest{
+						UserID:              optional.Of(userID),
+						ServiceName:         optional.Of("Netflix"),
+						Price:               optional.Of(rub(100)),
+						Currency:            optional.Of("RUB"),
+						StartDate:           optional.Of("09-2025"),
+						EndDate:             optional.Null[string](),
+						BillingPeriodMonths: optional.Of(1),
+						BillingAnchor:       optional.Null[string](),
+					}).
+					Return(&storage.UpdateResponse{GetInfoResponse: stored}, nil)
+				return &application.UpdateResponse{
+					GetInfoResponse: application.GetInfoResponse{
+						ID:                  validID,
+						UserID:              userID,
+						ServiceName:         "Netflix",
+						Price:               rub(100),
+						StartDate:           "09-2025",
+						BillingPeriod:       application.BillingMonth,
+						BillingPeriodMonths: 1,
+						MonthlyPrice:        rub(100),
+						CreatedAt:           stored.CreatedAt,
+						UpdatedAt:           stored.UpdatedAt,
+					},
+				}, nil
+			},
+		},
+		{
+			name: "incomplete body",
+			req: &application.CreateRequest{
+				Price: "100",
+			},
+			want: func(mockStorage *mocks.MockSubscriptionsStorage) (*application.UpdateResponse, error) {
+				return nil, fmt.Errorf("%w: service_name: is required; start_date: is required; user_id: is required", application.ErrValidation)
+			},
+		},
+		{
+			name: "version mismatch",
+			req: &application.CreateRequest{
+				UserID:      userID,
+				ServiceName: "Netflix",
+				Price:       "100",
+				StartDate:   "09-2025",
+			},
+			version: func() *int64 { v := int64(3); return &v }(),
+			want: func(mockStorage *mocks.MockSubscriptionsStorage) (*application.UpdateResponse, error) {
+				version := int64(3)
+				mockStorage.EXPECT().
+					Update(gomock.Any(), validID, gomock.Any()).
+					DoAndReturn(func(_ context.Context, _ uuid.UUID, req *storage.UpdateRequest) (*storage.UpdateResponse, error) {
+						assert.Equal(t, &version, req.Version)
+						return nil, fmt.Errorf("%w: version changed", storage.ErrPreconditionFailed)
+					})
+				return nil, application.ErrPreconditionFailed
+			},
+		},
+		{
+			name: "not found",
+			req: &application.CreateRequest{
+				UserID:      userID,
+				ServiceName: "Netflix",
+				Price:       "100",
+				StartDate:   "09-2025",
+			},
+			want: func(mockStorage *mocks.MockSubscriptionsStorage) (*application.UpdateResponse, error) {
+				mockStorage.EXPECT().
+					Update(gomock.Any(), validID, gomock.Any()).
+					Return(nil, fmt.Errorf("%w: subscription with id %s", storage.ErrNotFound, validID))
+				return nil, fmt.Errorf("%w: subscription with id %s", application.ErrNotFound, validID)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := mocks.NewMockSubscriptionsStorage(ctrl)
+			wantResp, wantErr := tt.want(mockStorage)
+
+			svc := application.NewService(slog.Default(), &application.Config{Secret: "test"}, mockStorage)
+
+			got, err := svc.Replace(adminContext(), validID, &application.ReplaceRequest{
+				CreateRequest: *tt.req,
+				Version:       tt.version,
+			})
 
 			if wantErr != nil {
 				assert.Error(t, err)
@@ -500,14 +810,14 @@ func TestDeleteSubscription(t *testing.T) {
 			name: "nil request",
 			req:  nil,
 			want: func(mockStorage *mocks.MockSubscriptionsStorage) (*application.DeleteResponse, error) {
-				return nil, errors.New("request cannot be nil")
+				return nil, fmt.Errorf("%w: request cannot be nil", application.ErrValidation)
 			},
 		},
 		{
 			name: "invalid ID",
 			req:  &application.DeleteRequest{ID: uuid.Nil},
 			want: func(mockStorage *mocks.MockSubscriptionsStorage) (*application.DeleteResponse, error) {
-				return nil, errors.New("id is required")
+				return nil, fmt.Errorf("%w: id is required", application.ErrValidation)
 			},
 		},
 		{
@@ -532,7 +842,7 @@ func TestDeleteSubscription(t *testing.T) {
 				mockStorage,
 			)
 
-			got, err := svc.Delete(context.Background(), tt.req)
+			got, err := svc.Delete(adminContext(), tt.req)
 
 			if wantErr != nil {
 				assert.Error(t, err)
@@ -571,15 +881,60 @@ func TestGetTotalSubscriptionsPrice(t *testing.T) {
 			want: func(mockStorage *mocks.MockSubscriptionsStorage) (*application.TotalResponse, error) {
 				mockStorage.EXPECT().
 					GetTotalSubscriptionsPrice(gomock.Any(), gomock.Any()).
-					Return(50, nil)
-				return &application.TotalResponse{Total: 50}, nil
+					Return(&storage.TotalResponse{Total: rub(50)}, nil)
+				return &application.TotalResponse{Total: rub(50)}, nil
+			},
+		},
+		{
+			name: "grouped by month and service",
+			req: &application.TotalRequest{
+				UserID:  &userID,
+				From:    from,
+				To:      to,
+				GroupBy: []string{application.GroupByMonth, application.GroupByServiceName},
+			},
+			want: func(mockStorage *mocks.MockSubscriptionsStorage) (*application.TotalResponse, error) {
+				month := "09-2025"
+				mockStorage.EXPECT().
+					GetTotalSubscriptionsPrice(gomock.Any(), &storage.TotalRequest{
+						UserID:   &userID,
+						From:     from,
+						To:       to,
+						GroupBy:  []string{storage.GroupByMonth, storage.GroupByServiceName},
+						Currency: "RUB",
+					}).
+					Return(&storage.TotalResponse{
+						Total:           rub(30),
+						NormalizedTotal: money.Amount{Minor: 1250, Exponent: 2},
+						Buckets: []storage.TotalBucket{
+							{Month: &month, ServiceName: &serviceName, Total: rub(30), NormalizedTotal: money.Amount{Minor: 1250, Exponent: 2}, Count: 2},
+						},
+					}, nil)
+				return &application.TotalResponse{
+					Total:           rub(30),
+					NormalizedTotal: money.Amount{Minor: 1250, Exponent: 2},
+					Buckets: []application.TotalBucket{
+						{Month: &month, ServiceName: &serviceName, Total: rub(30), NormalizedTotal: money.Amount{Minor: 1250, Exponent: 2}, Count: 2},
+					},
+				}, nil
+			},
+		},
+		{
+			name: "invalid group_by",
+			req: &application.TotalRequest{
+				From:    from,
+				To:      to,
+				GroupBy: []string{"year"},
+			},
+			want: func(mockStorage *mocks.MockSubscriptionsStorage) (*application.TotalResponse, error) {
+				return nil, errors.New(`group_by: unsupported value "year"`)
 			},
 		},
 		{
 			name: "nil request",
 			req:  nil,
 			want: func(mockStorage *mocks.MockSubscriptionsStorage) (*application.TotalResponse, error) {
-				return nil, errors.New("request cannot be nil")
+				return nil, fmt.Errorf("%w: request cannot be nil", application.ErrValidation)
 			},
 		},
 		{
@@ -593,7 +948,7 @@ func TestGetTotalSubscriptionsPrice(t *testing.T) {
 			want: func(mockStorage *mocks.MockSubscriptionsStorage) (*application.TotalResponse, error) {
 				mockStorage.EXPECT().
 					GetTotalSubscriptionsPrice(gomock.Any(), gomock.Any()).
-					Return(0, fmt.Errorf("db error"))
+					Return(nil, fmt.Errorf("db error"))
 				return nil, fmt.Errorf("get total subscriptions price: %w", fmt.Errorf("db error"))
 			},
 		},
@@ -610,7 +965,7 @@ func TestGetTotalSubscriptionsPrice(t *testing.T) {
 				mockStorage,
 			)
 
-			got, err := svc.GetTotalSubscriptionsPrice(context.Background(), tt.req)
+			got, err := svc.GetTotalSubscriptionsPrice(adminContext(), tt.req)
 
 			if wantErr != nil {
 				assert.Error(t, err)
@@ -618,7 +973,7 @@ func TestGetTotalSubscriptionsPrice(t *testing.T) {
 				assert.Nil(t, got)
 			} else {
 				assert.NoError(t, err)
-				assert.Equal(t, wantResp.Total, got.Total)
+				assert.Equal(t, wantResp, got)
 			}
 		})
 	}
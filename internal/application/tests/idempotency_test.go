@@ -0,0 +1,171 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"github.com/azaliaz/subs-api/internal/application"
+	"github.com/azaliaz/subs-api/internal/storage"
+	"github.com/azaliaz/subs-api/internal/storage/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCreateIdempotency(t *testing.T) {
+	newRequest := func(key string) *application.CreateRequest {
+		return &application.CreateRequest{
+			UserID:         uuid.New(),
+			ServiceName:    "Netflix",
+			Price:          "10",
+			StartDate:      "09-2025",
+			IdempotencyKey: key,
+		}
+	}
+
+	t.Run("key is passed to storage", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		id := uuid.New()
+		mockStorage := mocks.NewMockSubscriptionsStorage(ctrl)
+		mockStorage.EXPECT().Create(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, req *storage.CreateRequest) (*storage.CreateResponse, error) {
+				if assert.NotNil(t, req.Idempotency) {
+					assert.Equal(t, "retry-1", req.Idempotency.Key)
+					assert.Len(t, req.Idempotency.RequestHash, 64)
+					assert.WithinDuration(t, time.Now().Add(time.Hour), req.Idempotency.ExpiresAt, time.Minute)
+				}
+				return &storage.CreateResponse{ID: id}, nil
+			})
+
+		svc := application.NewService(slog.Default(), &application.Config{IdempotencyTTL: time.Hour}, mockStorage)
+		got, err := svc.Create(adminContext(), newRequest("retry-1"))
+
+		assert.NoError(t, err)
+		assert.Equal(t, &application.CreateResponse{ID: id}, got)
+	})
+
+	t.Run("same payload has the same hash", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		var hashes []string
+		mockStorage := mocks.NewMockSubscriptionsStorage(ctrl)
+		mockStorage.EXPECT().Create(gomock.Any(), gomock.Any()).Times(3).
+			DoAndReturn(func(_ context.Context, req *storage.CreateRequest) (*storage.CreateResponse, error) {
+				hashes = append(hashes, req.Idempotency.RequestHash)
+				return &storage.CreateResponse{ID: uuid.New()}, nil
+			})
+
+		svc := application.NewService(slog.Default(), &application.Config{}, mockStorage)
+		req := newRequest("retry-1")
+		_, _ = svc.Create(adminContext(), req)
+		_, _ = svc.Create(adminContext(), req)
+		req.Price = "20"
+		_, _ = svc.Create(adminContext(), req)
+
+		assert.Equal(t, hashes[0], hashes[1])
+		assert.NotEqual(t, hashes[0], hashes[2])
+	})
+
+	t.Run("no key", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := mocks.NewMockSubscriptionsStorage(ctrl)
+		mockStorage.EXPECT().Create(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, req *storage.CreateRequest) (*storage.CreateResponse, error) {
+				assert.Nil(t, req.Idempotency)
+				return &storage.CreateResponse{ID: uuid.New()}, nil
+			})
+
+		svc := application.NewService(slog.Default(), &application.Config{}, mockStorage)
+		_, err := svc.Create(adminContext(), newRequest(""))
+		assert.NoError(t, err)
+	})
+
+	t.Run("replayed", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		id := uuid.New()
+		mockStorage := mocks.NewMockSubscriptionsStorage(ctrl)
+		mockStorage.EXPECT().Create(gomock.Any(), gomock.Any()).
+			Return(&storage.CreateResponse{ID: id, Replayed: true}, nil)
+
+		svc := application.NewService(slog.Default(), &application.Config{}, mockStorage)
+		got, err := svc.Create(adminContext(), newRequest("retry-1"))
+
+		assert.NoError(t, err)
+		assert.Equal(t, &application.CreateResponse{ID: id, Replayed: true}, got)
+	})
+
+	t.Run("different payload", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := mocks.NewMockSubscriptionsStorage(ctrl)
+		mockStorage.EXPECT().Create(gomock.Any(), gomock.Any()).
+			Return(nil, fmt.Errorf("%w: key was used with a different request", storage.ErrIdempotencyMismatch))
+
+		svc := application.NewService(slog.Default(), &application.Config{}, mockStorage)
+		got, err := svc.Create(adminContext(), newRequest("retry-1"))
+
+		assert.ErrorIs(t, err, application.ErrIdempotencyMismatch)
+		assert.Nil(t, got)
+	})
+
+	t.Run("key too long", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		svc := application.NewService(slog.Default(), &application.Config{}, mocks.NewMockSubscriptionsStorage(ctrl))
+		_, err := svc.Create(adminContext(), newRequest(strings.Repeat("k", application.MaxIdempotencyKeyLength+1)))
+
+		assert.ErrorIs(t, err, application.ErrValidation)
+		assert.ErrorContains(t, err, "Idempotency-Key")
+	})
+}
+
+func TestRunDeletesExpiredIdempotencyKeys(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	deleted := make(chan struct{}, 1)
+	mockStorage := mocks.NewMockSubscriptionsStorage(ctrl)
+	mockStorage.EXPECT().DeleteExpiredIdempotencyKeys(gomock.Any()).MinTimes(1).
+		DoAndReturn(func(context.Context) (int64, error) {
+			select {
+			case deleted <- struct{}{}:
+			default:
+			}
+			return 1, nil
+		})
+
+	svc := application.NewService(
+		slog.Default(),
+		&application.Config{IdempotencyCleanupInterval: 10 * time.Millisecond},
+		mockStorage,
+	)
+
+	done := make(chan error)
+	go func() { done <- svc.Run(context.Background()) }()
+
+	select {
+	case <-deleted:
+	case <-time.After(time.Second):
+		t.Fatal("expired keys were not deleted")
+	}
+
+	svc.Stop()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Run did not stop")
+	}
+}
@@ -0,0 +1,170 @@
+package tests
+
+import (
+	"context"
+	"github.com/azaliaz/subs-api/internal/application"
+	"github.com/azaliaz/subs-api/internal/storage"
+	"github.com/azaliaz/subs-api/internal/storage/mocks"
+	"github.com/azaliaz/subs-api/pkg/money"
+	"github.com/azaliaz/subs-api/pkg/optional"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"testing"
+)
+
+func TestPriceValidate(t *testing.T) {
+	tests := []struct {
+		price money.Decimal
+		want  application.FieldErrors
+	}{
+		{price: "299.90"},
+		{price: "0.0001"},
+		{price: "1000000"},
+		{price: "", want: application.FieldErrors{"price": "is required"}},
+		{price: "0.00", want: application.FieldErrors{"price": "must be greater than 0 and at most 1000000"}},
+		{price: "1000000.01", want: application.FieldErrors{"price": "must be greater than 0 and at most 1000000"}},
+		{price: "-5", want: application.FieldErrors{"price": "must be greater than 0 and at most 1000000"}},
+		{price: "0.00001", want: application.FieldErrors{"price": "must have at most 4 decimal places"}},
+		{price: "12,50", want: application.FieldErrors{"price": "must be a decimal number such as 299.90"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.price), func(t *testing.T) {
+			err := (&application.CreateRequest{
+				UserID: uuid.New(), ServiceName: "Netflix", Price: tt.price, StartDate: "09-2025",
+			}).Validate()
+			assert.Equal(t, tt.want, fieldErrors(t, err))
+		})
+	}
+}
+
+func TestListPriceRange(t *testing.T) {
+	tests := []struct {
+		name     string
+		min, max money.Decimal
+		want     application.FieldErrors
+	}{
+		{name: "free subscriptions", min: "0", max: "0"},
+		{name: "from zero", min: "0", max: "99.90"},
+		{name: "negative", min: "-1", max: "10", want: application.FieldErrors{"price_min": "must not be negative"}},
+		{name: "inverted", min: "10.5", max: "10.49", want: application.FieldErrors{"price_max": "must not be less than price_min"}},
+		{name: "not a number", min: "0", max: "1e3", want: application.FieldErrors{"price_max": "must be a decimal number such as 299.90"}},
+		{name: "too precise", min: "0.00001", max: "1", want: application.FieldErrors{"price_min": "must have at most 4 decimal places"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&application.ListRequest{PriceMin: &tt.min, PriceMax: &tt.max}).Validate()
+			assert.Equal(t, tt.want, fieldErrors(t, err))
+		})
+	}
+}
+
+func TestCreateMinorUnits(t *testing.T) {
+	tests := []struct {
+		name     string
+		price    money.Decimal
+		currency string
+		want     money.Amount
+		err      application.FieldErrors
+	}{
+		{name: "kopecks", price: "299.90", want: money.Amount{Minor: 29990, Exponent: 2}},
+		{name: "whole rubles", price: "300", want: money.Amount{Minor: 30000, Exponent: 2}},
+		{name: "yen", price: "960", currency: "JPY", want: money.Amount{Minor: 960, Exponent: 0}},
+		{name: "dinars", price: "4.125", currency: "KWD", want: money.Amount{Minor: 4125, Exponent: 3}},
+		{
+			name:     "too precise for the currency",
+			price:    "960.5",
+			currency: "JPY",
+			err:      application.FieldErrors{"price": "must have at most 0 decimal places for JPY"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockStorage := mocks.NewMockSubscriptionsStorage(ctrl)
+			if tt.err == nil {
+				mockStorage.EXPECT().Create(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, req *storage.CreateRequest) (*storage.CreateResponse, error) {
+						assert.Equal(t, tt.want, req.Price)
+						return &storage.CreateResponse{ID: uuid.New()}, nil
+					})
+			}
+
+			svc := application.NewService(slog.Default(), &application.Config{}, mockStorage)
+			_, err := svc.Create(adminContext(), &application.CreateRequest{
+				UserID:      uuid.New(),
+				ServiceName: "Netflix",
+				Price:       tt.price,
+				Currency:    tt.currency,
+				StartDate:   "09-2025",
+			})
+			assert.Equal(t, tt.err, fieldErrors(t, err))
+		})
+	}
+}
+
+func TestUpdateCurrency(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	id := uuid.New()
+	mockStorage := mocks.NewMockSubscriptionsStorage(ctrl)
+	svc := application.NewService(slog.Default(), &application.Config{}, mockStorage)
+	current := &storage.GetInfoResponse{ID: id, Price: money.Amount{Minor: 29990, Exponent: 2}, Currency: "RUB", StartDate: "09-2025", BillingPeriodMonths: 1}
+
+	t.Run("with a price", func(t *testing.T) {
+		mockStorage.EXPECT().GetInfo(gomock.Any(), id).Return(current, nil)
+		// The new price is appended in the new currency; earlier ones keep
+		// theirs.
+		mockStorage.EXPECT().Update(gomock.Any(), id, &storage.UpdateRequest{
+			Price:    optional.Of(money.Amount{Minor: 4500, Exponent: 0}),
+			Currency: optional.Of("JPY"),
+		}).Return(&storage.UpdateResponse{GetInfoResponse: *current}, nil)
+
+		_, err := svc.Update(adminContext(), id, &application.UpdateRequest{
+			Price:    optional.Of(money.Decimal("4500")),
+			Currency: optional.Of("JPY"),
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("without a price", func(t *testing.T) {
+		mockStorage.EXPECT().GetInfo(gomock.Any(), id).Return(current, nil)
+
+		_, err := svc.Update(adminContext(), id, &application.UpdateRequest{Currency: optional.Of("JPY")})
+		assert.Equal(t, application.FieldErrors{"currency": "requires price"}, fieldErrors(t, err))
+	})
+
+	t.Run("same currency", func(t *testing.T) {
+		mockStorage.EXPECT().GetInfo(gomock.Any(), id).Return(current, nil)
+		mockStorage.EXPECT().Update(gomock.Any(), id, &storage.UpdateRequest{}).
+			Return(&storage.UpdateResponse{GetInfoResponse: *current}, nil)
+
+		_, err := svc.Update(adminContext(), id, &application.UpdateRequest{Currency: optional.Of("RUB")})
+		require.NoError(t, err)
+	})
+}
+
+func TestMonthlyPriceIsExact(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	id := uuid.New()
+	mockStorage := mocks.NewMockSubscriptionsStorage(ctrl)
+	mockStorage.EXPECT().GetInfo(gomock.Any(), id).Return(&storage.GetInfoResponse{
+		ID: id, Price: money.Amount{Minor: 99990, Exponent: 2}, Currency: "RUB", BillingPeriodMonths: 12,
+	}, nil)
+
+	svc := application.NewService(slog.Default(), &application.Config{}, mockStorage)
+	resp, err := svc.GetInfo(adminContext(), &application.GetInfoRequest{ID: id})
+	require.NoError(t, err)
+	// 999.90 / 12 = 83.325, rounded half away from zero.
+	assert.Equal(t, money.Amount{Minor: 8333, Exponent: 2}, resp.MonthlyPrice)
+}
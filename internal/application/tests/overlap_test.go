@@ -0,0 +1,98 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"github.com/azaliaz/subs-api/internal/application"
+	"github.com/azaliaz/subs-api/internal/storage"
+	"github.com/azaliaz/subs-api/internal/storage/mocks"
+	"github.com/azaliaz/subs-api/pkg/optional"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"testing"
+)
+
+func TestOverlapPolicyConfig(t *testing.T) {
+	for _, policy := range []string{"", application.OverlapOff, application.OverlapWarn, application.OverlapReject} {
+		svc := application.NewService(slog.Default(), &application.Config{OverlapPolicy: policy}, nil)
+		assert.NoError(t, svc.Init(), policy)
+	}
+
+	svc := application.NewService(slog.Default(), &application.Config{OverlapPolicy: "strict"}, nil)
+	assert.ErrorContains(t, svc.Init(), `unknown overlap policy "strict"`)
+}
+
+func TestCreateOverlap(t *testing.T) {
+	request := func() *application.CreateRequest {
+		return &application.CreateRequest{
+			UserID:      uuid.New(),
+			ServiceName: "Yandex Plus",
+			Price:       "400",
+			StartDate:   "07-2025",
+		}
+	}
+
+	t.Run("warn", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		id, other := uuid.New(), uuid.New()
+		mockStorage := mocks.NewMockSubscriptionsStorage(ctrl)
+		mockStorage.EXPECT().Create(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, req *storage.CreateRequest) (*storage.CreateResponse, error) {
+				assert.Equal(t, storage.OverlapWarn, req.Overlap)
+				return &storage.CreateResponse{ID: id, Overlaps: []uuid.UUID{other}}, nil
+			})
+
+		svc := application.NewService(slog.Default(), &application.Config{OverlapPolicy: application.OverlapWarn}, mockStorage)
+		got, err := svc.Create(adminContext(), request())
+
+		assert.NoError(t, err)
+		assert.Equal(t, &application.CreateResponse{ID: id, Overlaps: []uuid.UUID{other}}, got)
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockStorage := mocks.NewMockSubscriptionsStorage(ctrl)
+		mockStorage.EXPECT().Create(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, req *storage.CreateRequest) (*storage.CreateResponse, error) {
+				assert.Equal(t, storage.OverlapReject, req.Overlap)
+				return nil, fmt.Errorf("%w: subscription overlaps %s", storage.ErrConflict, uuid.New())
+			})
+
+		svc := application.NewService(slog.Default(), &application.Config{OverlapPolicy: application.OverlapReject}, mockStorage)
+		got, err := svc.Create(adminContext(), request())
+
+		assert.ErrorIs(t, err, application.ErrConflict)
+		assert.Nil(t, got)
+	})
+}
+
+func TestUpdateOverlap(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	id, other := uuid.New(), uuid.New()
+	mockStorage := mocks.NewMockSubscriptionsStorage(ctrl)
+	mockStorage.EXPECT().GetInfo(gomock.Any(), id).
+		Return(&storage.GetInfoResponse{ID: id, StartDate: "01-2025", Version: 1}, nil)
+	mockStorage.EXPECT().Update(gomock.Any(), id, gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ uuid.UUID, req *storage.UpdateRequest) (*storage.UpdateResponse, error) {
+			assert.Equal(t, storage.OverlapWarn, req.Overlap)
+			return &storage.UpdateResponse{
+				GetInfoResponse: storage.GetInfoResponse{ID: id, StartDate: "03-2025", Version: 2},
+				Overlaps:        []uuid.UUID{other},
+			}, nil
+		})
+
+	svc := application.NewService(slog.Default(), &application.Config{OverlapPolicy: application.OverlapWarn}, mockStorage)
+	got, err := svc.Update(adminContext(), id, &application.UpdateRequest{StartDate: optional.Of("03-2025")})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{other}, got.Overlaps)
+	assert.Equal(t, "03-2025", got.StartDate)
+}
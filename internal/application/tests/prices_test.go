@@ -0,0 +1,161 @@
+package tests
+
+import (
+	"context"
+	"github.com/azaliaz/subs-api/internal/application"
+	"github.com/azaliaz/subs-api/internal/storage"
+	"github.com/azaliaz/subs-api/internal/storage/mocks"
+	"github.com/azaliaz/subs-api/pkg/money"
+	"github.com/azaliaz/subs-api/pkg/month"
+	"github.com/azaliaz/subs-api/pkg/optional"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestGetPrices(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	id, owner := uuid.New(), uuid.New()
+	mockStorage := mocks.NewMockSubscriptionsStorage(ctrl)
+	svc := application.NewService(slog.Default(), &application.Config{}, mockStorage)
+	sub := &storage.GetInfoResponse{ID: id, UserID: owner, Price: rub(300), Currency: "RUB", StartDate: "01-2025", BillingPeriodMonths: 3}
+
+	t.Run("timeline", func(t *testing.T) {
+		mockStorage.EXPECT().GetInfo(gomock.Any(), id).Return(sub, nil)
+		mockStorage.EXPECT().ListPrices(gomock.Any(), id).Return([]storage.PriceChange{
+			{EffectiveFrom: "01-2025", Price: rub(300)},
+			{EffectiveFrom: "04-2025", Price: rub(360)},
+			{EffectiveFrom: "01-2026", Price: money.Amount{Minor: 39990, Exponent: 2}},
+		}, nil)
+
+		resp, err := svc.GetPrices(adminContext(), &application.PricesRequest{ID: id})
+		require.NoError(t, err)
+		assert.Equal(t, &application.PricesResponse{
+			ID:       id,
+			Currency: "RUB",
+			Prices: []application.PriceChange{
+				{EffectiveFrom: "01-2025", EffectiveTo: ptr("03-2025"), Price: rub(300), MonthlyPrice: rub(100)},
+				{EffectiveFrom: "04-2025", EffectiveTo: ptr("12-2025"), Price: rub(360), MonthlyPrice: rub(120)},
+				{EffectiveFrom: "01-2026", Price: money.Amount{Minor: 39990, Exponent: 2}, MonthlyPrice: money.Amount{Minor: 13330, Exponent: 2}},
+			},
+		}, resp)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		mockStorage.EXPECT().GetInfo(gomock.Any(), id).Return(nil, nil)
+
+		_, err := svc.GetPrices(adminContext(), &application.PricesRequest{ID: id})
+		assert.ErrorIs(t, err, application.ErrNotFound)
+	})
+
+	t.Run("another user", func(t *testing.T) {
+		mockStorage.EXPECT().GetInfo(gomock.Any(), id).Return(sub, nil)
+
+		ctx := application.WithPrincipal(context.Background(), &application.Principal{Subject: uuid.New(), Role: application.RoleUser})
+		_, err := svc.GetPrices(ctx, &application.PricesRequest{ID: id})
+		assert.ErrorIs(t, err, application.ErrNotFound)
+	})
+}
+
+func TestUpdatePriceEffectiveFrom(t *testing.T) {
+	now := time.Now()
+	thisMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	nextYear := month.Format(thisMonth.AddDate(1, 0, 0))
+
+	tests := []struct {
+		name    string
+		request *application.UpdateRequest
+		want    *storage.UpdateRequest
+		err     application.FieldErrors
+	}{
+		{
+			name: "scheduled",
+			request: &application.UpdateRequest{
+				Price:              optional.Of(money.Decimal("349")),
+				PriceEffectiveFrom: optional.Of(nextYear),
+			},
+			want: &storage.UpdateRequest{
+				Price:              optional.Of(rub(349)),
+				PriceEffectiveFrom: optional.Of(nextYear),
+			},
+		},
+		{
+			name:    "without a price",
+			request: &application.UpdateRequest{PriceEffectiveFrom: optional.Of(nextYear)},
+			err:     application.FieldErrors{"price_effective_from": "requires price"},
+		},
+		{
+			name: "not a month",
+			request: &application.UpdateRequest{
+				Price:              optional.Of(money.Decimal("349")),
+				PriceEffectiveFrom: optional.Of("2027-01"),
+			},
+			err: application.FieldErrors{"price_effective_from": "must be a month in MM-YYYY format"},
+		},
+		{
+			name: "before the start",
+			request: &application.UpdateRequest{
+				Price:              optional.Of(money.Decimal("349")),
+				PriceEffectiveFrom: optional.Of("12-2024"),
+			},
+			err: application.FieldErrors{"price_effective_from": "must not be before start_date"},
+		},
+		{
+			name: "current month",
+			request: &application.UpdateRequest{
+				Price:              optional.Of(money.Decimal("349")),
+				PriceEffectiveFrom: optional.Of(month.Format(thisMonth)),
+			},
+			want: &storage.UpdateRequest{
+				Price:              optional.Of(rub(349)),
+				PriceEffectiveFrom: optional.Of(month.Format(thisMonth)),
+			},
+		},
+		{
+			name: "past month",
+			request: &application.UpdateRequest{
+				Price:              optional.Of(money.Decimal("349")),
+				PriceEffectiveFrom: optional.Of(month.Format(thisMonth.AddDate(0, -1, 0))),
+			},
+			// Storage judges it by the clock of the database.
+			want: &storage.UpdateRequest{
+				Price:              optional.Of(rub(349)),
+				PriceEffectiveFrom: optional.Of(month.Format(thisMonth.AddDate(0, -1, 0))),
+			},
+		},
+		{
+			name: "after the end",
+			request: &application.UpdateRequest{
+				Price:              optional.Of(money.Decimal("349")),
+				PriceEffectiveFrom: optional.Of(nextYear),
+				EndDate:            optional.Of("06-2025"),
+			},
+			err: application.FieldErrors{"price_effective_from": "must not be after end_date"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			id := uuid.New()
+			mockStorage := mocks.NewMockSubscriptionsStorage(ctrl)
+			current := &storage.GetInfoResponse{ID: id, Price: rub(299), Currency: "RUB", StartDate: "01-2025", BillingPeriodMonths: 1}
+			mockStorage.EXPECT().GetInfo(gomock.Any(), id).Return(current, nil).AnyTimes()
+			if tt.want != nil {
+				mockStorage.EXPECT().Update(gomock.Any(), id, tt.want).Return(&storage.UpdateResponse{GetInfoResponse: *current}, nil)
+			}
+
+			svc := application.NewService(slog.Default(), &application.Config{}, mockStorage)
+			_, err := svc.Update(adminContext(), id, tt.request)
+			assert.Equal(t, tt.err, fieldErrors(t, err))
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"github.com/azaliaz/subs-api/internal/application"
+	"github.com/azaliaz/subs-api/internal/storage"
+	"github.com/azaliaz/subs-api/internal/storage/mocks"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestRestore(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	id, owner := uuid.New(), uuid.New()
+	mockStorage := mocks.NewMockSubscriptionsStorage(ctrl)
+	svc := application.NewService(slog.Default(), &application.Config{OverlapPolicy: application.OverlapWarn}, mockStorage)
+	restored := &storage.UpdateResponse{GetInfoResponse: storage.GetInfoResponse{ID: id, UserID: owner, Price: rub(300), Currency: "RUB", Version: 3}}
+
+	t.Run("owner", func(t *testing.T) {
+		mockStorage.EXPECT().Restore(gomock.Any(), &storage.RestoreRequest{ID: id, UserID: &owner, Overlap: storage.OverlapWarn}).
+			Return(restored, nil)
+
+		ctx := application.WithPrincipal(context.Background(), &application.Principal{Subject: owner, Role: application.RoleUser})
+		resp, err := svc.Restore(ctx, &application.RestoreRequest{ID: id})
+		require.NoError(t, err)
+		assert.Equal(t, id, resp.ID)
+		assert.Equal(t, int64(3), resp.Version)
+		assert.Nil(t, resp.DeletedAt)
+	})
+
+	t.Run("admin", func(t *testing.T) {
+		mockStorage.EXPECT().Restore(gomock.Any(), &storage.RestoreRequest{ID: id, Overlap: storage.OverlapWarn}).
+			Return(restored, nil)
+
+		ctx := application.WithPrincipal(context.Background(), &application.Principal{Subject: uuid.New(), Role: application.RoleAdmin})
+		_, err := svc.Restore(ctx, &application.RestoreRequest{ID: id})
+		require.NoError(t, err)
+	})
+
+	t.Run("not deleted", func(t *testing.T) {
+		mockStorage.EXPECT().Restore(gomock.Any(), gomock.Any()).
+			Return(nil, fmt.Errorf("%w: subscription with id %s is not deleted", storage.ErrConflict, id))
+
+		_, err := svc.Restore(adminContext(), &application.RestoreRequest{ID: id})
+		assert.ErrorIs(t, err, application.ErrConflict)
+	})
+
+	t.Run("nil id", func(t *testing.T) {
+		_, err := svc.Restore(adminContext(), &application.RestoreRequest{})
+		assert.ErrorIs(t, err, application.ErrValidation)
+	})
+}
+
+func TestListIncludeDeleted(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockStorage := mocks.NewMockSubscriptionsStorage(ctrl)
+	svc := application.NewService(slog.Default(), &application.Config{}, mockStorage)
+	request := &application.ListRequest{IncludeDeleted: true}
+
+	t.Run("user", func(t *testing.T) {
+		ctx := application.WithPrincipal(context.Background(), &application.Principal{Subject: uuid.New(), Role: application.RoleUser})
+		_, err := svc.List(ctx, request)
+		assert.ErrorIs(t, err, application.ErrForbidden)
+	})
+
+	t.Run("admin", func(t *testing.T) {
+		deletedAt := time.Now()
+		mockStorage.EXPECT().List(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, req *storage.ListRequest) (*storage.ListResponse, error) {
+				assert.True(t, req.IncludeDeleted)
+				return &storage.ListResponse{
+					Subscriptions: []storage.GetInfoResponse{{ID: uuid.New(), Price: rub(100), DeletedAt: &deletedAt}},
+					Total:         1,
+				}, nil
+			})
+
+		ctx := application.WithPrincipal(context.Background(), &application.Principal{Subject: uuid.New(), Role: application.RoleAdmin})
+		resp, err := svc.List(ctx, request)
+		require.NoError(t, err)
+		require.Len(t, resp.Subscriptions, 1)
+		assert.Equal(t, &deletedAt, resp.Subscriptions[0].DeletedAt)
+	})
+}
+
+func TestRunPurgesDeleted(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	purged := make(chan time.Time, 1)
+	mockStorage := mocks.NewMockSubscriptionsStorage(ctrl)
+	mockStorage.EXPECT().PurgeDeleted(gomock.Any(), gomock.Any()).MinTimes(1).
+		DoAndReturn(func(_ context.Context, before time.Time) (int64, error) {
+			select {
+			case purged <- before:
+			default:
+			}
+			return 1, nil
+		})
+
+	svc := application.NewService(slog.Default(), &application.Config{
+		DeletedRetention: 24 * time.Hour,
+		PurgeInterval:    time.Millisecond,
+	}, mockStorage)
+	done := make(chan error)
+	go func() { done <- svc.Run(context.Background()) }()
+
+	select {
+	case before := <-purged:
+		assert.WithinDuration(t, time.Now().Add(-24*time.Hour), before, time.Minute)
+	case <-time.After(time.Second):
+		t.Fatal("deleted subscriptions were not purged")
+	}
+	svc.Stop()
+	require.NoError(t, <-done)
+}
+
+func TestRunWithoutRetention(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Neither job is enabled, so Run returns at once without touching
+	// storage.
+	svc := application.NewService(slog.Default(), &application.Config{PurgeInterval: time.Millisecond}, mocks.NewMockSubscriptionsStorage(ctrl))
+	assert.NoError(t, svc.Run(context.Background()))
+}
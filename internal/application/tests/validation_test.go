@@ -0,0 +1,272 @@
+package tests
+
+import (
+	"context"
+	"encoding/base64"
+	"github.com/azaliaz/subs-api/internal/application"
+	"github.com/azaliaz/subs-api/pkg/money"
+	"github.com/azaliaz/subs-api/pkg/optional"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// rub is a whole number of rubles.
+func rub(major int64) money.Amount {
+	return money.Amount{Minor: major * 100, Exponent: 2}
+}
+
+// adminContext carries an admin principal, so calls are authorized without
+// narrowing filters to a single user.
+func adminContext() context.Context {
+	return application.WithPrincipal(context.Background(), &application.Principal{Subject: uuid.New(), Role: application.RoleAdmin})
+}
+
+func fieldErrors(t *testing.T, err error) application.FieldErrors {
+	t.Helper()
+
+	if err == nil {
+		return nil
+	}
+	require.ErrorIs(t, err, application.ErrValidation)
+	var verr *application.ValidationError
+	require.ErrorAs(t, err, &verr)
+	return verr.Fields
+}
+
+func TestCreateRequestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		req  application.CreateRequest
+		want application.FieldErrors
+	}{
+		{
+			name: "valid",
+			req: application.CreateRequest{
+				UserID:      uuid.New(),
+				ServiceName: "Yandex Plus",
+				Price:       "400",
+				StartDate:   "07-2025",
+				EndDate:     ptr("07-2025"),
+			},
+		},
+		{
+			name: "every field invalid",
+			req: application.CreateRequest{
+				ServiceName: "Netflix\n",
+				Price:       "1000000.01",
+				StartDate:   "2025-07",
+				EndDate:     ptr("13-2025"),
+			},
+			want: application.FieldErrors{
+				"user_id":      "is required",
+				"service_name": "must not start or end with whitespace",
+				"price":        "must be greater than 0 and at most 1000000",
+				"start_date":   "must be a month in MM-YYYY format",
+				"end_date":     "must be a month in MM-YYYY format",
+			},
+		},
+		{
+			name: "end before start",
+			req: application.CreateRequest{
+				UserID:      uuid.New(),
+				ServiceName: "Netflix",
+				Price:       "10",
+				StartDate:   "09-2025",
+				EndDate:     ptr("08-2025"),
+			},
+			want: application.FieldErrors{"end_date": "must not be before start_date"},
+		},
+		{
+			name: "service name charset and length",
+			req: application.CreateRequest{
+				UserID:      uuid.New(),
+				ServiceName: strings.Repeat("a", application.MaxServiceNameLength+1),
+				Price:       "10",
+				StartDate:   "09-2025",
+			},
+			want: application.FieldErrors{"service_name": "must be at most 100 characters"},
+		},
+		{
+			name: "service name with markup",
+			req: application.CreateRequest{
+				UserID:      uuid.New(),
+				ServiceName: "<script>",
+				Price:       "10",
+				StartDate:   "09-2025",
+			},
+			want: application.FieldErrors{"service_name": "may contain only letters, digits, spaces and . , - _ + & ' ( ) !"},
+		},
+		{
+			name: "yearly with anchor",
+			req: application.CreateRequest{
+				UserID:        uuid.New(),
+				ServiceName:   "Yandex Plus",
+				Price:         "2990",
+				StartDate:     "07-2025",
+				BillingPeriod: application.BillingYear,
+				BillingAnchor: ptr("03-2025"),
+			},
+		},
+		{
+			name: "custom billing period",
+			req: application.CreateRequest{
+				UserID:              uuid.New(),
+				ServiceName:         "Yandex Plus",
+				Price:               "1500",
+				StartDate:           "07-2025",
+				BillingPeriod:       application.BillingCustom,
+				BillingPeriodMonths: ptr(6),
+			},
+		},
+		{
+			name: "invalid billing period",
+			req: application.CreateRequest{
+				UserID:              uuid.New(),
+				ServiceName:         "Netflix",
+				Price:               "10",
+				StartDate:           "09-2025",
+				BillingPeriod:       "week",
+				BillingPeriodMonths: ptr(application.MaxBillingPeriodMonths + 1),
+				BillingAnchor:       ptr("2025-09"),
+			},
+			want: application.FieldErrors{
+				"billing_period":        "must be one of: month, quarter, year, custom",
+				"billing_period_months": "must be between 1 and 120",
+				"billing_anchor":        "must be a month in MM-YYYY format",
+			},
+		},
+		{
+			name: "billing period months contradict period",
+			req: application.CreateRequest{
+				UserID:              uuid.New(),
+				ServiceName:         "Netflix",
+				Price:               "10",
+				StartDate:           "09-2025",
+				BillingPeriod:       application.BillingQuarter,
+				BillingPeriodMonths: ptr(12),
+			},
+			want: application.FieldErrors{"billing_period_months": "does not match billing_period"},
+		},
+		{
+			name: "custom billing period without months",
+			req: application.CreateRequest{
+				UserID:        uuid.New(),
+				ServiceName:   "Netflix",
+				Price:         "10",
+				StartDate:     "09-2025",
+				BillingPeriod: application.BillingCustom,
+			},
+			want: application.FieldErrors{"billing_period_months": "is required for a custom billing_period"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, fieldErrors(t, tt.req.Validate()))
+		})
+	}
+}
+
+func TestUpdateRequestValidate(t *testing.T) {
+	assert.NoError(t, (&application.UpdateRequest{}).Validate())
+
+	assert.NoError(t, (&application.UpdateRequest{EndDate: optional.Null[string]()}).Validate())
+
+	err := (&application.UpdateRequest{
+		ServiceName: optional.Of(" "),
+		Price:       optional.Of[money.Decimal]("0"),
+		StartDate:   optional.Of("10-2025"),
+		EndDate:     optional.Of("09-2025"),
+	}).Validate()
+	assert.Equal(t, application.FieldErrors{
+		"service_name": "must not be empty",
+		"price":        "must be greater than 0 and at most 1000000",
+		"end_date":     "must not be before start_date",
+	}, fieldErrors(t, err))
+
+	err = (&application.UpdateRequest{
+		ServiceName: optional.Null[string](),
+		Price:       optional.Null[money.Decimal](),
+		StartDate:   optional.Null[string](),
+	}).Validate()
+	assert.Equal(t, application.FieldErrors{
+		"service_name": "must not be null",
+		"price":        "must not be null",
+		"start_date":   "must not be null",
+	}, fieldErrors(t, err))
+
+	assert.NoError(t, (&application.UpdateRequest{BillingAnchor: optional.Null[string]()}).Validate())
+
+	err = (&application.UpdateRequest{
+		BillingPeriod:       optional.Null[string](),
+		BillingPeriodMonths: optional.Null[int](),
+	}).Validate()
+	assert.Equal(t, application.FieldErrors{
+		"billing_period":        "must not be null",
+		"billing_period_months": "must not be null",
+	}, fieldErrors(t, err))
+}
+
+func TestListRequestValidate(t *testing.T) {
+	err := (&application.ListRequest{
+		UserIDs: []uuid.UUID{uuid.New(), uuid.Nil},
+		From:    ptr("12-2025"),
+		To:      ptr("01-2025"),
+		Limit:   ptr(0),
+		Offset:  ptr(-1),
+	}).Validate()
+	assert.Equal(t, application.FieldErrors{
+		"user_id": "must not be the nil UUID",
+		"to":      "must not be before from",
+		"limit":   "must be greater than 0",
+		"offset":  "must not be negative",
+	}, fieldErrors(t, err))
+
+	err = (&application.ListRequest{PageToken: "not a token"}).Validate()
+	assert.Equal(t, application.FieldErrors{"page_token": "is invalid"}, fieldErrors(t, err))
+
+	token := base64.RawURLEncoding.EncodeToString([]byte(`{"o":"start_date:asc","v":"2025-09-01","i":"` + uuid.NewString() + `"}`))
+	assert.NoError(t, (&application.ListRequest{PageToken: token}).Validate())
+	err = (&application.ListRequest{PageToken: token, Offset: ptr(0)}).Validate()
+	assert.Equal(t, application.FieldErrors{"page_token": "cannot be combined with offset"}, fieldErrors(t, err))
+
+	err = (&application.ListRequest{
+		ServiceMatch: "prefix",
+		PriceMin:     ptr(money.Decimal("500")),
+		PriceMax:     ptr(money.Decimal("100")),
+		Status:       "paused",
+		Sort:         "price:up",
+	}).Validate()
+	assert.Equal(t, application.FieldErrors{
+		"service_match": "must be one of: substring, exact",
+		"price_max":     "must not be less than price_min",
+		"status":        "must be one of: active, ended, future",
+		"sort":          "must be one of: price, start_date, end_date, service_name, created_at, optionally followed by :asc or :desc",
+	}, fieldErrors(t, err))
+
+	err = (&application.ListRequest{AsOf: ptr("10-2025")}).Validate()
+	assert.Equal(t, application.FieldErrors{"as_of": "requires status"}, fieldErrors(t, err))
+
+	for _, sort := range []string{"", "price", "end_date:desc", "created_at:asc"} {
+		assert.NoError(t, (&application.ListRequest{Sort: sort}).Validate(), sort)
+	}
+}
+
+func TestTotalRequestValidate(t *testing.T) {
+	err := (&application.TotalRequest{
+		To:      "2025",
+		GroupBy: []string{application.GroupByMonth, application.GroupByMonth},
+	}).Validate()
+	assert.Equal(t, application.FieldErrors{
+		"from":     "is required",
+		"to":       "must be a month in MM-YYYY format",
+		"group_by": `duplicate value "month"`,
+	}, fieldErrors(t, err))
+}
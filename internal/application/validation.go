@@ -0,0 +1,451 @@
+package application
+
+import (
+	"errors"
+	"fmt"
+	"github.com/azaliaz/subs-api/pkg/money"
+	"github.com/azaliaz/subs-api/pkg/month"
+	"github.com/azaliaz/subs-api/pkg/optional"
+	"github.com/google/uuid"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// MaxPrice is in major units of any currency.
+	MaxPrice             = 1_000_000
+	MaxServiceNameLength = 100
+
+	MaxIdempotencyKeyLength = 255
+)
+
+// ValidationError reports every rejected field of a request at once.
+type ValidationError struct {
+	Fields FieldErrors
+}
+
+func (e *ValidationError) Error() string {
+	fields := make([]string, 0, len(e.Fields))
+	for field := range e.Fields {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	parts := make([]string, 0, len(fields))
+	for _, field := range fields {
+		parts = append(parts, field+": "+e.Fields[field])
+	}
+	return fmt.Sprintf("%s: %s", ErrValidation, strings.Join(parts, "; "))
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrValidation
+}
+
+// FieldErrors maps a request field, named as in JSON, to the reason its value
+// was rejected.
+type FieldErrors map[string]string
+
+// Add records message for field unless an earlier rule already rejected it.
+func (f FieldErrors) Add(field, message string) {
+	if _, ok := f[field]; !ok {
+		f[field] = message
+	}
+}
+
+// Merge adds the fields of a ValidationError returned by Validate.
+func (f FieldErrors) Merge(err error) {
+	var verr *ValidationError
+	if errors.As(err, &verr) {
+		for field, message := range verr.Fields {
+			f.Add(field, message)
+		}
+	}
+}
+
+// Err returns a ValidationError if any field was rejected.
+func (f FieldErrors) Err() error {
+	if len(f) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: f}
+}
+
+func (f FieldErrors) userID(field string, id *uuid.UUID) {
+	if id != nil && *id == uuid.Nil {
+		f.Add(field, "must not be the nil UUID")
+	}
+}
+
+func (f FieldErrors) maxLength(field string, value *string, max int) {
+	if value != nil && utf8.RuneCountInString(*value) > max {
+		f.Add(field, fmt.Sprintf("must be at most %d characters", max))
+	}
+}
+
+func (f FieldErrors) serviceName(field string, name *string) {
+	if name == nil {
+		return
+	}
+	f.maxLength(field, name, MaxServiceNameLength)
+	switch {
+	case strings.TrimSpace(*name) == "":
+		f.Add(field, "must not be empty")
+	case strings.TrimSpace(*name) != *name:
+		f.Add(field, "must not start or end with whitespace")
+	case strings.IndexFunc(*name, invalidNameRune) >= 0:
+		f.Add(field, "may contain only letters, digits, spaces and . , - _ + & ' ( ) !")
+	}
+}
+
+func invalidNameRune(r rune) bool {
+	if unicode.IsLetter(r) || unicode.IsDigit(r) || r == ' ' {
+		return false
+	}
+	return !strings.ContainsRune(".,-_+&'()!", r)
+}
+
+// price checks a positive decimal price. The returned amount is exact at
+// money.MaxExponent and nil when the value is absent or invalid.
+func (f FieldErrors) price(field string, price *money.Decimal) *money.Amount {
+	if price == nil {
+		return nil
+	}
+	amount, err := price.Amount(money.MaxExponent)
+	switch {
+	case errors.Is(err, money.ErrSyntax):
+		f.Add(field, "must be a decimal number such as 299.90")
+	case errors.Is(err, money.ErrPrecision):
+		f.Add(field, fmt.Sprintf("must have at most %d decimal places", money.MaxExponent))
+	case err != nil || amount.Minor <= 0 || amount.Cmp(money.Amount{Minor: MaxPrice}) > 0:
+		f.Add(field, fmt.Sprintf("must be greater than 0 and at most %d", MaxPrice))
+	default:
+		return &amount
+	}
+	return nil
+}
+
+// priceBound checks one end of a price range, which unlike a price may be 0.
+// The returned amount is exact at money.MaxExponent and nil when the value is
+// absent or invalid.
+func (f FieldErrors) priceBound(field string, bound *money.Decimal) *money.Amount {
+	if bound == nil {
+		return nil
+	}
+	amount, err := bound.Amount(money.MaxExponent)
+	switch {
+	case errors.Is(err, money.ErrSyntax):
+		f.Add(field, "must be a decimal number such as 299.90")
+	case errors.Is(err, money.ErrPrecision):
+		f.Add(field, fmt.Sprintf("must have at most %d decimal places", money.MaxExponent))
+	case err != nil:
+		f.Add(field, "is too large")
+	case amount.Minor < 0:
+		f.Add(field, "must not be negative")
+	default:
+		return &amount
+	}
+	return nil
+}
+
+func (f FieldErrors) priceRange(minField string, min *money.Decimal, maxField string, max *money.Decimal) {
+	low := f.priceBound(minField, min)
+	high := f.priceBound(maxField, max)
+	if low != nil && high != nil && high.Cmp(*low) < 0 {
+		f.Add(maxField, "must not be less than "+minField)
+	}
+}
+
+// amount converts a valid price into minor units of currency.
+func (f FieldErrors) amount(field string, price money.Decimal, currency string) money.Amount {
+	exponent := money.Exponent(currency)
+	amount, err := price.Amount(exponent)
+	if err != nil {
+		f.Add(field, fmt.Sprintf("must have at most %d decimal places for %s", exponent, currency))
+	}
+	return amount
+}
+
+// month parses an optional MM-YYYY value. The returned time is nil when the
+// value is absent or invalid.
+func (f FieldErrors) month(field string, value *string) *time.Time {
+	if value == nil {
+		return nil
+	}
+	t, err := month.Parse(*value)
+	if err != nil {
+		f.Add(field, "must be a month in MM-YYYY format")
+		return nil
+	}
+	return &t
+}
+
+func (f FieldErrors) idempotencyKey(field string, key string) {
+	switch {
+	case len(key) > MaxIdempotencyKeyLength:
+		f.Add(field, fmt.Sprintf("must be at most %d characters", MaxIdempotencyKeyLength))
+	case strings.IndexFunc(key, func(r rune) bool { return r < '!' || r > '~' }) >= 0:
+		f.Add(field, "may contain only visible ASCII characters")
+	}
+}
+
+// validCurrency reports whether currency looks like an ISO 4217 code.
+func validCurrency(currency string) bool {
+	if len(currency) != 3 {
+		return false
+	}
+	for _, r := range currency {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+func (f FieldErrors) currency(field string, currency *string) {
+	if currency != nil && !validCurrency(*currency) {
+		f.Add(field, "must be an ISO 4217 code such as RUB")
+	}
+}
+
+func (f FieldErrors) required(field string, value string) {
+	if value == "" {
+		f.Add(field, "is required")
+	}
+}
+
+func (f FieldErrors) notNull(field string, null bool) {
+	if null {
+		f.Add(field, "must not be null")
+	}
+}
+
+func (f FieldErrors) monthRange(startField string, start *time.Time, endField string, end *time.Time) {
+	if start != nil && end != nil && end.Before(*start) {
+		f.Add(endField, "must not be before "+startField)
+	}
+}
+
+// billingPeriod checks a billing period and its length in months. A named
+// period fixes the length, a custom one needs it.
+func (f FieldErrors) billingPeriod(periodField string, period *string, monthsField string, months *int) {
+	if period != nil {
+		switch *period {
+		case BillingMonth, BillingQuarter, BillingYear:
+			if months != nil && *months != billingPeriods[*period] {
+				f.Add(monthsField, "does not match "+periodField)
+			}
+		case BillingCustom:
+			if months == nil {
+				f.Add(monthsField, "is required for a custom "+periodField)
+			}
+		default:
+			f.Add(periodField, "must be one of: month, quarter, year, custom")
+		}
+	}
+	if months != nil && (*months < 1 || *months > MaxBillingPeriodMonths) {
+		f.Add(monthsField, fmt.Sprintf("must be between 1 and %d", MaxBillingPeriodMonths))
+	}
+}
+
+func (f FieldErrors) groupBy(field string, groupBy []string) {
+	seen := make(map[string]struct{}, len(groupBy))
+	for _, group := range groupBy {
+		switch group {
+		case GroupByMonth, GroupByServiceName, GroupByUserID:
+		default:
+			f.Add(field, fmt.Sprintf("unsupported value %q, expected one of: month, service_name, user_id", group))
+			return
+		}
+		if _, ok := seen[group]; ok {
+			f.Add(field, fmt.Sprintf("duplicate value %q", group))
+			return
+		}
+		seen[group] = struct{}{}
+	}
+}
+
+func (r *CreateRequest) Validate() error {
+	f := FieldErrors{}
+	if r.UserID == uuid.Nil {
+		f.Add("user_id", "is required")
+	}
+	f.required("service_name", r.ServiceName)
+	f.serviceName("service_name", &r.ServiceName)
+	f.required("price", string(r.Price))
+	if r.Price != "" {
+		f.price("price", &r.Price)
+	}
+	if r.Currency != "" {
+		f.currency("currency", &r.Currency)
+	}
+	f.required("start_date", r.StartDate)
+	start := f.month("start_date", &r.StartDate)
+	end := f.month("end_date", r.EndDate)
+	f.monthRange("start_date", start, "end_date", end)
+	var period *string
+	if r.BillingPeriod != "" {
+		period = &r.BillingPeriod
+	}
+	f.billingPeriod("billing_period", period, "billing_period_months", r.BillingPeriodMonths)
+	f.month("billing_anchor", r.BillingAnchor)
+	f.idempotencyKey("Idempotency-Key", r.IdempotencyKey)
+	return f.Err()
+}
+
+func (r *UpdateRequest) Validate() error {
+	f := FieldErrors{}
+	f.notNull("service_name", r.ServiceName.Null)
+	f.serviceName("service_name", r.ServiceName.Ptr())
+	f.notNull("price", r.Price.Null)
+	f.price("price", r.Price.Ptr())
+	f.notNull("price_effective_from", r.PriceEffectiveFrom.Null)
+	f.month("price_effective_from", r.PriceEffectiveFrom.Ptr())
+	if r.PriceEffectiveFrom.Set && !r.Price.Set {
+		f.Add("price_effective_from", "requires price")
+	}
+	f.notNull("currency", r.Currency.Null)
+	f.currency("currency", r.Currency.Ptr())
+	f.notNull("start_date", r.StartDate.Null)
+	start := f.month("start_date", r.StartDate.Ptr())
+	end := f.month("end_date", r.EndDate.Ptr())
+	f.monthRange("start_date", start, "end_date", end)
+	f.notNull("billing_period", r.BillingPeriod.Null)
+	f.notNull("billing_period_months", r.BillingPeriodMonths.Null)
+	f.billingPeriod("billing_period", r.BillingPeriod.Ptr(), "billing_period_months", r.BillingPeriodMonths.Ptr())
+	f.month("billing_anchor", r.BillingAnchor.Ptr())
+	return f.Err()
+}
+
+// amount is the price in minor units of currency.
+func (r *CreateRequest) amount(currency string) (money.Amount, error) {
+	f := FieldErrors{}
+	amount := f.amount("price", r.Price, currency)
+	return amount, f.Err()
+}
+
+// amount is the new price in minor units of the patched currency, unset
+// when the patch has none. A new currency applies only to a new price, so
+// that earlier prices keep the currency they were charged in.
+func (r *UpdateRequest) amount(currency string) (optional.Value[money.Amount], error) {
+	f := FieldErrors{}
+	if r.Currency.Set && r.Currency.Value != currency && !r.Price.Set {
+		f.Add("currency", "requires price")
+	}
+	if r.Currency.Set {
+		currency = r.Currency.Value
+	}
+	if !r.Price.Set {
+		return optional.Value[money.Amount]{}, f.Err()
+	}
+	return optional.Of(f.amount("price", r.Price.Value, currency)), f.Err()
+}
+
+// billingPeriodMonths is the new length of the billing period, unset when
+// the patch changes neither billing_period nor billing_period_months.
+func (r *UpdateRequest) billingPeriodMonths() optional.Value[int] {
+	if !r.BillingPeriod.Set && !r.BillingPeriodMonths.Set {
+		return optional.Value[int]{}
+	}
+	return optional.Of(billingPeriodMonths(r.BillingPeriod.Value, r.BillingPeriodMonths.Ptr()))
+}
+
+// validateMerged checks the dates of the patched subscription, combining the
+// stored ones with those the patch changes. Whether a price change starts in
+// a month that is already billed is left to storage, whose clock also picks
+// the month when none is given.
+func (r *UpdateRequest) validateMerged(startDate string, endDate *string) error {
+	if r.StartDate.Set {
+		startDate = r.StartDate.Value
+	}
+	if r.EndDate.Set {
+		endDate = r.EndDate.Ptr()
+	}
+
+	f := FieldErrors{}
+	start := f.month("start_date", &startDate)
+	end := f.month("end_date", endDate)
+	if start != nil && end != nil && end.Before(*start) {
+		if r.EndDate.Set {
+			f.Add("end_date", "must not be before start_date")
+		} else {
+			f.Add("start_date", "must not be after end_date")
+		}
+	}
+	priceFrom := f.month("price_effective_from", r.PriceEffectiveFrom.Ptr())
+	if priceFrom != nil && start != nil && priceFrom.Before(*start) {
+		f.Add("price_effective_from", "must not be before start_date")
+	}
+	if priceFrom != nil && end != nil && priceFrom.After(*end) {
+		f.Add("price_effective_from", "must not be after end_date")
+	}
+	return f.Err()
+}
+
+func (r *ListRequest) Validate() error {
+	f := FieldErrors{}
+	for i := range r.UserIDs {
+		f.userID("user_id", &r.UserIDs[i])
+	}
+	f.maxLength("service_name", r.ServiceName, MaxServiceNameLength)
+	switch r.ServiceMatch {
+	case "", ServiceMatchSubstring, ServiceMatchExact:
+	default:
+		f.Add("service_match", "must be one of: substring, exact")
+	}
+	from := f.month("from", r.From)
+	to := f.month("to", r.To)
+	f.monthRange("from", from, "to", to)
+	f.priceRange("price_min", r.PriceMin, "price_max", r.PriceMax)
+	switch r.Status {
+	case "", StatusActive, StatusEnded, StatusFuture:
+	default:
+		f.Add("status", "must be one of: active, ended, future")
+	}
+	f.month("as_of", r.AsOf)
+	if r.AsOf != nil && r.Status == "" {
+		f.Add("as_of", "requires status")
+	}
+	_, _, sortOK := parseSort(r.Sort)
+	if !sortOK {
+		f.Add("sort", "must be one of: price, start_date, end_date, service_name, created_at, optionally followed by :asc or :desc")
+	}
+	if r.Limit != nil && *r.Limit <= 0 {
+		f.Add("limit", "must be greater than 0")
+	}
+	if r.Offset != nil && *r.Offset < 0 {
+		f.Add("offset", "must not be negative")
+	}
+	if r.PageToken != "" {
+		sort, _, err := decodePageToken(r.PageToken)
+		switch {
+		case err != nil:
+			f.Add("page_token", "is invalid")
+		case sortOK && sort != canonicalSort(r.Sort):
+			f.Add("page_token", "was issued for a different sort")
+		}
+		if r.Offset != nil {
+			f.Add("page_token", "cannot be combined with offset")
+		}
+	}
+	return f.Err()
+}
+
+func (r *TotalRequest) Validate() error {
+	f := FieldErrors{}
+	f.userID("user_id", r.UserID)
+	f.maxLength("service_name", r.ServiceName, MaxServiceNameLength)
+	f.required("from", r.From)
+	f.required("to", r.To)
+	from := f.month("from", &r.From)
+	to := f.month("to", &r.To)
+	f.monthRange("from", from, "to", to)
+	f.groupBy("group_by", r.GroupBy)
+	if r.Currency != "" {
+		f.currency("currency", &r.Currency)
+	}
+	return f.Err()
+}
@@ -0,0 +1,44 @@
+package rest
+
+import (
+	"fmt"
+	"github.com/azaliaz/subs-api/internal/application"
+	"github.com/gofiber/fiber/v2"
+	"strings"
+)
+
+const bearerPrefix = "Bearer "
+
+func (api *Service) Authenticate(c *fiber.Ctx) error {
+	log := api.requestLogger(c)
+
+	header := c.Get(fiber.HeaderAuthorization)
+	if len(header) <= len(bearerPrefix) || !strings.EqualFold(header[:len(bearerPrefix)], bearerPrefix) {
+		log.Warn("missing bearer token", "path", c.Path())
+		c.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+		return fmt.Errorf("%w: bearer token is required", application.ErrUnauthorized)
+	}
+
+	principal, err := api.app.Authenticate(c.UserContext(), strings.TrimSpace(header[len(bearerPrefix):]))
+	if err != nil {
+		log.Warn("failed to authenticate", "path", c.Path(), "error", err)
+		c.Set(fiber.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+		return err
+	}
+
+	c.SetUserContext(application.WithPrincipal(c.UserContext(), principal))
+	return c.Next()
+}
+
+// RequireAdmin lets through only requests that Authenticate has attributed
+// to an admin.
+func (api *Service) RequireAdmin(c *fiber.Ctx) error {
+	log := api.requestLogger(c)
+
+	principal, ok := application.PrincipalFromContext(c.UserContext())
+	if !ok || !principal.IsAdmin() {
+		log.Warn("admin role required", "path", c.Path())
+		return fmt.Errorf("%w: admin role required", application.ErrForbidden)
+	}
+	return c.Next()
+}
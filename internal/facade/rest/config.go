@@ -11,5 +11,8 @@ type Config struct {
 	FiberCaseSensitive         bool   `env:"FIBER_CASE_SENSITIVE" yaml:"fiber-case-sensitive"`
 	FiberDisableStartupMessage bool   `env:"FIBER_DISABLE_STARTUP_MESSAGE" yaml:"fiber-disable-startup-message"`
 	FiberDisableKeepalive      bool   `env:"FIBER_DISABLE_KEEPALIVE" yaml:"fiber-disable-keepalive"`
+	FiberShutdownTimeout       int64  `env:"FIBER_SHUTDOWN_TIMEOUT" envDefault:"10" yaml:"fiber-shutdown-timeout"`
 	IsAdditionalErrorsEnabled  bool   `env:"IS_ADDITIONAL_ERRORS_ENABLED" yaml:"is-additional-errors-enabled"`
+	StrictJSON                 bool   `env:"STRICT_JSON" envDefault:"true" yaml:"strict-json"`
+	MetricsPort                uint64 `env:"METRICS_PORT" yaml:"metrics-port"`
 }
@@ -0,0 +1,300 @@
+package rest
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/azaliaz/subs-api/internal/application"
+	"github.com/azaliaz/subs-api/pkg/money"
+	"github.com/gofiber/fiber/v2"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const bodyField = "body"
+
+const (
+	HeaderAcceptPatch = "Accept-Patch"
+	MIMEMergePatch    = "application/merge-patch+json"
+)
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// wrapper is implemented by types such as optional.Value that decode the JSON
+// value into another type.
+type wrapper interface {
+	Type() reflect.Type
+}
+
+var wrapperType = reflect.TypeOf((*wrapper)(nil)).Elem()
+
+// decimalType is written either as a JSON number or as a string.
+var decimalType = reflect.TypeOf(money.Decimal(""))
+
+// decodeBody parses the JSON request body into out. In strict mode unknown
+// fields, duplicate keys, values of the wrong type and trailing data are
+// rejected, each reported under its JSON path.
+func (api *Service) decodeBody(c *fiber.Ctx, out interface{}) error {
+	if api.config == nil || !api.config.StrictJSON {
+		if err := c.BodyParser(out); err != nil {
+			return invalidRequest("invalid request body: %v", err)
+		}
+		return nil
+	}
+
+	body := c.Body()
+	invalid := application.FieldErrors{}
+	if len(bytes.TrimSpace(body)) == 0 {
+		invalid.Add(bodyField, "is required")
+		return invalid.Err()
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	if err := checkJSON(dec, reflect.TypeOf(out), "", invalid); err != nil {
+		invalid.Add(bodyField, malformedJSON(err))
+		return invalid.Err()
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		invalid.Add(bodyField, "must contain a single JSON value")
+	}
+	if err := invalid.Err(); err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		invalid.Add(bodyField, "could not be decoded")
+		return invalid.Err()
+	}
+	return nil
+}
+
+// isMergePatch reports whether a PATCH body of contentType is a JSON Merge
+// Patch. Plain JSON is accepted as well, as it has the same shape.
+func isMergePatch(contentType string) bool {
+	mime, _, _ := strings.Cut(contentType, ";")
+	mime = strings.ToLower(strings.TrimSpace(mime))
+	return mime == MIMEMergePatch || mime == fiber.MIMEApplicationJSON
+}
+
+// checkJSON reads one JSON value from dec and compares it with t, the Go type
+// it is going to be decoded into. Problems with the content are recorded in
+// invalid; the returned error means the JSON itself is malformed.
+func checkJSON(dec *json.Decoder, t reflect.Type, path string, invalid application.FieldErrors) error {
+	t = valueType(t)
+
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	switch tok := tok.(type) {
+	case nil:
+		if path == "" && t != nil {
+			invalid.Add(bodyField, "must be "+jsonTypeName(t))
+		}
+
+	case json.Delim:
+		if tok == '[' {
+			if t != nil && (isText(t) || t.Kind() != reflect.Slice && t.Kind() != reflect.Array && t.Kind() != reflect.Interface) {
+				invalid.Add(fieldPath(path), "must be "+jsonTypeName(t))
+				t = nil
+			}
+			for i := 0; dec.More(); i++ {
+				var elem reflect.Type
+				if t != nil && t.Kind() != reflect.Interface {
+					elem = t.Elem()
+				}
+				if err := checkJSON(dec, elem, fmt.Sprintf("%s[%d]", path, i), invalid); err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+			return err
+		}
+
+		if t != nil && (isText(t) || t.Kind() != reflect.Struct && t.Kind() != reflect.Map && t.Kind() != reflect.Interface) {
+			invalid.Add(fieldPath(path), "must be "+jsonTypeName(t))
+			t = nil
+		}
+		seen := make(map[string]struct{})
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			key := keyTok.(string)
+			keyPath := joinPath(path, key)
+			if _, ok := seen[key]; ok {
+				invalid.Add(keyPath, "is duplicated")
+			}
+			seen[key] = struct{}{}
+
+			var field reflect.Type
+			if t != nil {
+				switch t.Kind() {
+				case reflect.Struct:
+					var ok bool
+					if field, ok = structField(t, key); !ok {
+						invalid.Add(keyPath, "is not a known field")
+					}
+				case reflect.Map:
+					field = t.Elem()
+				}
+			}
+			if err := checkJSON(dec, field, keyPath, invalid); err != nil {
+				return err
+			}
+		}
+		_, err = dec.Token()
+		return err
+
+	case string:
+		if t == nil || t.Kind() == reflect.Interface {
+			return nil
+		}
+		if t == decimalType {
+			checkDecimal(tok, path, invalid)
+			return nil
+		}
+		if isText(t) {
+			target := reflect.New(t).Interface().(encoding.TextUnmarshaler)
+			if err := target.UnmarshalText([]byte(tok)); err != nil {
+				invalid.Add(fieldPath(path), "has an invalid value")
+			}
+			return nil
+		}
+		if t.Kind() != reflect.String {
+			invalid.Add(fieldPath(path), "must be "+jsonTypeName(t))
+		}
+
+	case json.Number:
+		if t == nil || t.Kind() == reflect.Interface {
+			return nil
+		}
+		if t == decimalType {
+			checkDecimal(tok.String(), path, invalid)
+			return nil
+		}
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if _, err := strconv.ParseInt(tok.String(), 10, t.Bits()); err != nil {
+				invalid.Add(fieldPath(path), "must be "+jsonTypeName(t))
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if _, err := strconv.ParseUint(tok.String(), 10, t.Bits()); err != nil {
+				invalid.Add(fieldPath(path), "must be "+jsonTypeName(t))
+			}
+		case reflect.Float32, reflect.Float64:
+		default:
+			invalid.Add(fieldPath(path), "must be "+jsonTypeName(t))
+		}
+
+	case bool:
+		if t != nil && t.Kind() != reflect.Bool && t.Kind() != reflect.Interface {
+			invalid.Add(fieldPath(path), "must be "+jsonTypeName(t))
+		}
+	}
+
+	return nil
+}
+
+func checkDecimal(value, path string, invalid application.FieldErrors) {
+	if _, err := money.Decimal(value).Amount(money.MaxExponent); errors.Is(err, money.ErrSyntax) {
+		invalid.Add(fieldPath(path), "must be a decimal number such as 299.90")
+	}
+}
+
+// valueType strips pointers and wrappers from t, leaving the type the JSON
+// value itself is decoded into.
+func valueType(t reflect.Type) reflect.Type {
+	for t != nil {
+		switch {
+		case t.Kind() == reflect.Pointer:
+			t = t.Elem()
+		case t.Implements(wrapperType):
+			t = reflect.Zero(t).Interface().(wrapper).Type()
+		default:
+			return t
+		}
+	}
+	return nil
+}
+
+// structField returns the type of the field that key decodes into. Unlike
+// encoding/json, the key has to match the json tag exactly.
+func structField(t reflect.Type, key string) (reflect.Type, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		if name == key {
+			return field.Type, true
+		}
+	}
+	return nil, false
+}
+
+// isText reports whether values of t are decoded from JSON strings through
+// encoding.TextUnmarshaler, as uuid.UUID is.
+func isText(t reflect.Type) bool {
+	return reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+func jsonTypeName(t reflect.Type) string {
+	if t == decimalType {
+		return "a number or a decimal string"
+	}
+	if isText(t) {
+		return "a string"
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	default:
+		return "an object"
+	}
+}
+
+func malformedJSON(err error) string {
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		return fmt.Sprintf("is not valid JSON at offset %d", syntaxErr.Offset)
+	}
+	return "is not valid JSON"
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func fieldPath(path string) string {
+	if path == "" {
+		return bodyField
+	}
+	return path
+}
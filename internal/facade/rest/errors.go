@@ -0,0 +1,84 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"github.com/azaliaz/subs-api/internal/application"
+	"github.com/gofiber/fiber/v2"
+	"net/http"
+)
+
+const problemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 error response. Errors lists rejected request fields
+// for validation failures.
+type Problem struct {
+	Type     string            `json:"type"`
+	Title    string            `json:"title"`
+	Status   int               `json:"status"`
+	Detail   string            `json:"detail,omitempty"`
+	Instance string            `json:"instance,omitempty"`
+	Errors   map[string]string `json:"errors,omitempty"`
+}
+
+// invalidRequest reports a malformed request that was rejected before it
+// reached the application layer.
+func invalidRequest(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", application.ErrValidation, fmt.Sprintf(format, args...))
+}
+
+// errorStatus maps an error returned by a handler to the HTTP status it is
+// answered with.
+func errorStatus(err error) int {
+	var fiberErr *fiber.Error
+	switch {
+	case errors.As(err, &fiberErr):
+		return fiberErr.Code
+	case errors.Is(err, application.ErrValidation):
+		return fiber.StatusBadRequest
+	case errors.Is(err, application.ErrUnauthorized):
+		return fiber.StatusUnauthorized
+	case errors.Is(err, application.ErrForbidden):
+		return fiber.StatusForbidden
+	case errors.Is(err, application.ErrNotFound):
+		return fiber.StatusNotFound
+	case errors.Is(err, application.ErrConflict):
+		return fiber.StatusConflict
+	case errors.Is(err, application.ErrPreconditionFailed):
+		return fiber.StatusPreconditionFailed
+	case errors.Is(err, application.ErrIdempotencyMismatch),
+		errors.Is(err, application.ErrMissingFXRate):
+		return fiber.StatusUnprocessableEntity
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
+
+// ErrorHandler renders every error returned by handlers and middleware as a
+// problem+json body. The error text is only exposed when additional errors
+// are enabled in the config; rejected fields are always listed.
+func (api *Service) ErrorHandler(c *fiber.Ctx, err error) error {
+	status := errorStatus(err)
+	if status >= fiber.StatusInternalServerError {
+		api.requestLogger(c).Error("request failed", "status", status, "error", err)
+	}
+
+	problem := Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Instance: c.Path(),
+	}
+	// A missing exchange rate names the pair and month so that an admin can
+	// add it; the detail is shown regardless of the config.
+	if errors.Is(err, application.ErrMissingFXRate) ||
+		api.config != nil && api.config.IsAdditionalErrorsEnabled {
+		problem.Detail = err.Error()
+	}
+	var validationErr *application.ValidationError
+	if errors.As(err, &validationErr) {
+		problem.Errors = validationErr.Fields
+	}
+
+	return c.Status(status).JSON(problem, problemContentType)
+}
@@ -0,0 +1,55 @@
+package rest
+
+import (
+	"fmt"
+	"github.com/azaliaz/subs-api/internal/application"
+	"github.com/gofiber/fiber/v2"
+	"strconv"
+	"strings"
+)
+
+// etag is the strong entity tag of a subscription at the given version.
+func etag(version int64) string {
+	return strconv.Quote(strconv.FormatInt(version, 10))
+}
+
+// ifMatch returns the version required by the If-Match header, or nil when
+// the header is absent or "*". A tag that cannot belong to any subscription,
+// such as a weak one, fails the precondition.
+func ifMatch(c *fiber.Ctx) (*int64, error) {
+	header := strings.TrimSpace(c.Get(fiber.HeaderIfMatch))
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+	if strings.Contains(header, ",") {
+		return nil, invalidRequest("If-Match must contain a single entity tag")
+	}
+
+	tag, err := strconv.Unquote(header)
+	if err != nil {
+		return nil, fmt.Errorf("%w: If-Match %s does not match", application.ErrPreconditionFailed, header)
+	}
+	version, err := strconv.ParseInt(tag, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: If-Match %s does not match", application.ErrPreconditionFailed, header)
+	}
+	return &version, nil
+}
+
+// notModified reports whether the If-None-Match header lists tag, using the
+// weak comparison RFC 9110 prescribes for it.
+func notModified(c *fiber.Ctx, tag string) bool {
+	header := strings.TrimSpace(c.Get(fiber.HeaderIfNoneMatch))
+	if header == "" {
+		return false
+	}
+	if header == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(candidate), "W/") == tag {
+			return true
+		}
+	}
+	return false
+}
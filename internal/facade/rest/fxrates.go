@@ -0,0 +1,161 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"github.com/azaliaz/subs-api/internal/application"
+	"github.com/azaliaz/subs-api/pkg/money"
+	"github.com/gofiber/fiber/v2"
+	"io"
+	"strings"
+)
+
+const MIMETextCSV = "text/csv"
+
+// fxRatesCSVHeader is the required first line of an imported CSV file.
+var fxRatesCSVHeader = []string{"base", "quote", "month", "rate"}
+
+type putFXRateRequest struct {
+	Rate *money.Decimal `json:"rate"`
+}
+
+func (api *Service) GetFXRates(c *fiber.Ctx) error {
+	log := api.requestLogger(c)
+
+	var req application.FXRatesRequest
+	if base := c.Query("base"); base != "" {
+		req.Base = &base
+	}
+	if quote := c.Query("quote"); quote != "" {
+		req.Quote = &quote
+	}
+
+	resp, err := api.app.ListFXRates(c.UserContext(), &req)
+	if err != nil {
+		log.Info("failed to list fx rates", "error", err)
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+func (api *Service) PutFXRate(c *fiber.Ctx) error {
+	log := api.requestLogger(c)
+
+	var body putFXRateRequest
+	if err := api.decodeBody(c, &body); err != nil {
+		log.Info("failed to parse body", "error", err)
+		return err
+	}
+	if body.Rate == nil {
+		invalid := application.FieldErrors{}
+		invalid.Add("rate", "is required")
+		return invalid.Err()
+	}
+
+	key := fxRateKey(c)
+	resp, err := api.app.PutFXRate(c.UserContext(), &application.FXRate{
+		Base:  key.Base,
+		Quote: key.Quote,
+		Month: key.Month,
+		Rate:  *body.Rate,
+	})
+	if err != nil {
+		log.Info("failed to put fx rate", "error", err)
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+func (api *Service) DeleteFXRate(c *fiber.Ctx) error {
+	log := api.requestLogger(c)
+
+	resp, err := api.app.DeleteFXRate(c.UserContext(), fxRateKey(c))
+	if err != nil {
+		log.Info("failed to delete fx rate", "error", err)
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+// ImportFXRates saves a batch of rates sent either as CSV with the header
+// base,quote,month,rate or as JSON in the shape of ImportFXRatesRequest.
+func (api *Service) ImportFXRates(c *fiber.Ctx) error {
+	log := api.requestLogger(c)
+
+	var req application.ImportFXRatesRequest
+	mime, _, _ := strings.Cut(c.Get(fiber.HeaderContentType), ";")
+	switch strings.ToLower(strings.TrimSpace(mime)) {
+	case MIMETextCSV:
+		rates, err := parseFXRatesCSV(c.Body())
+		if err != nil {
+			log.Info("failed to parse fx rates csv", "error", err)
+			return err
+		}
+		req.Rates = rates
+	case fiber.MIMEApplicationJSON:
+		if err := api.decodeBody(c, &req); err != nil {
+			log.Info("failed to parse body", "error", err)
+			return err
+		}
+	default:
+		log.Info("unsupported import content type", "content_type", c.Get(fiber.HeaderContentType))
+		return fiber.ErrUnsupportedMediaType
+	}
+
+	resp, err := api.app.ImportFXRates(c.UserContext(), &req)
+	if err != nil {
+		log.Info("failed to import fx rates", "error", err)
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+func fxRateKey(c *fiber.Ctx) *application.FXRateKey {
+	return &application.FXRateKey{
+		Base:  c.Params("base"),
+		Quote: c.Params("quote"),
+		Month: c.Params("month"),
+	}
+}
+
+// parseFXRatesCSV reads the rows of an imported file. Malformed lines are
+// reported by their line number; the values themselves are validated by the
+// application layer.
+func parseFXRatesCSV(body []byte) ([]application.FXRate, error) {
+	r := csv.NewReader(bytes.NewReader(body))
+	r.FieldsPerRecord = len(fxRatesCSVHeader)
+	r.TrimLeadingSpace = true
+
+	header, err := r.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, invalidRequest("csv body is empty")
+	}
+	if err != nil {
+		return nil, invalidRequest("invalid csv: %v", err)
+	}
+	if strings.Join(header, ",") != strings.Join(fxRatesCSVHeader, ",") {
+		return nil, invalidRequest("line 1: header must be %s", strings.Join(fxRatesCSVHeader, ","))
+	}
+
+	var rates []application.FXRate
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return rates, nil
+		}
+		if err != nil {
+			return nil, invalidRequest("invalid csv: %v", err)
+		}
+		line, _ := r.FieldPos(0)
+		if _, err := money.Parse(record[3], money.MaxExponent); errors.Is(err, money.ErrSyntax) {
+			return nil, invalidRequest("line %d: rate must be a number", line)
+		}
+		rates = append(rates, application.FXRate{
+			Base:  record[0],
+			Quote: record[1],
+			Month: record[2],
+			Rate:  money.Decimal(record[3]),
+		})
+	}
+}
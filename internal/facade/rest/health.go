@@ -0,0 +1,52 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"github.com/azaliaz/subs-api/pkg/service"
+	"github.com/gofiber/fiber/v2"
+	"time"
+)
+
+const readinessTimeout = 2 * time.Second
+
+// WithHealthChecker sets the checker /readyz asks before reporting the
+// replica as ready, usually the service manager that owns this API.
+func (api *Service) WithHealthChecker(health service.HealthChecker) *Service {
+	api.health = health
+	return api
+}
+
+func (api *Service) Health(_ context.Context) error {
+	if !api.listening.Load() {
+		return errors.New("rest server is not listening")
+	}
+	return nil
+}
+
+func (api *Service) Liveness(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "ok"})
+}
+
+func (api *Service) Readiness(c *fiber.Ctx) error {
+	log := api.requestLogger(c)
+
+	if api.health == nil {
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "ok"})
+	}
+
+	ctx, cancel := context.WithTimeout(c.UserContext(), readinessTimeout)
+	defer cancel()
+
+	if err := api.health.Health(ctx); err != nil {
+		log.Warn("readiness check failed", "error", err)
+		// /readyz is not authenticated, so the cause is only shown when
+		// additional errors are enabled.
+		body := fiber.Map{"status": "unavailable"}
+		if api.config != nil && api.config.IsAdditionalErrorsEnabled {
+			body["error"] = err.Error()
+		}
+		return c.Status(fiber.StatusServiceUnavailable).JSON(body)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "ok"})
+}
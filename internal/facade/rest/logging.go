@@ -0,0 +1,79 @@
+package rest
+
+import (
+	"github.com/azaliaz/subs-api/pkg/logging"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+	"time"
+)
+
+const (
+	RequestIDHeader = "X-Request-ID"
+
+	maxRequestIDLength = 128
+
+	// handledErrorKey keeps the error RequestID has already answered, for the
+	// middleware that runs before it.
+	handledErrorKey = "handled_error"
+)
+
+// RequestID reuses the caller's X-Request-ID, or generates one, echoes it in
+// the response and puts a logger tagged with it into the request context. It
+// writes one access log line per request once the handler has finished; an
+// error is answered by the error handler first, so that the line has the
+// size of the body that is actually sent.
+func (api *Service) RequestID(c *fiber.Ctx) error {
+	id := c.Get(RequestIDHeader)
+	if !validRequestID(id) {
+		id = uuid.NewString()
+	}
+	c.Set(RequestIDHeader, id)
+
+	log := api.log.With("request_id", id)
+	if sc := trace.SpanContextFromContext(c.UserContext()); sc.IsValid() {
+		log = log.With("trace_id", sc.TraceID().String())
+	}
+	c.SetUserContext(logging.WithLogger(c.UserContext(), log))
+
+	start := time.Now()
+	err := c.Next()
+
+	route, status := routeAndStatus(c, err)
+	if err != nil {
+		c.Locals(handledErrorKey, err)
+		if err := c.App().ErrorHandler(c, err); err != nil {
+			return err
+		}
+	}
+	log.Info("request completed",
+		"method", c.Method(),
+		"route", route,
+		"status", status,
+		"latency", time.Since(start),
+		"bytes", len(c.Response().Body()),
+	)
+
+	return nil
+}
+
+// handledError returns the error of the request that RequestID has already
+// answered, if any.
+func handledError(c *fiber.Ctx) error {
+	err, _ := c.Locals(handledErrorKey).(error)
+	return err
+}
+
+// validRequestID accepts short ids made of printable ASCII so that a client
+// cannot inject arbitrary content into the logs.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,57 @@
+package rest
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"strconv"
+	"time"
+)
+
+const unmatchedRoute = "unmatched"
+
+var httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "subs_api",
+	Name:      "http_request_duration_seconds",
+	Help:      "Duration of HTTP requests by route.",
+	Buckets:   prometheus.DefBuckets,
+}, []string{"method", "route", "status"})
+
+// ObserveRequests records the latency of every request under its route
+// pattern, so that /api/info/:id is a single series for all ids.
+func (api *Service) ObserveRequests(c *fiber.Ctx) error {
+	start := time.Now()
+	err := c.Next()
+
+	route, status := routeAndStatus(c, err)
+	httpRequestDuration.
+		WithLabelValues(c.Method(), route, strconv.Itoa(status)).
+		Observe(time.Since(start).Seconds())
+
+	return err
+}
+
+// routeAndStatus returns the matched route pattern and the status code the
+// request will be answered with once err has gone through the error handler.
+func routeAndStatus(c *fiber.Ctx, err error) (string, int) {
+	status := c.Response().StatusCode()
+	route := c.Route().Path
+	if err != nil {
+		status = errorStatus(err)
+		// The router reports a request that matched no route with
+		// fiber.ErrNotFound and leaves the middleware route in c.Route().
+		var fiberErr *fiber.Error
+		if errors.As(err, &fiberErr) && fiberErr.Code == fiber.StatusNotFound {
+			route = unmatchedRoute
+		}
+	}
+
+	return route, status
+}
+
+func metricsHandler() fiber.Handler {
+	return adaptor.HTTPHandler(promhttp.Handler())
+}
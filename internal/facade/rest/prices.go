@@ -0,0 +1,47 @@
+package rest
+
+import (
+	"github.com/azaliaz/subs-api/internal/application"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// GetPrices returns the price history of a subscription, including the
+// changes scheduled for future months, with the integer prices of v1.
+func (api *Service) GetPrices(c *fiber.Ctx) error {
+	resp, err := api.prices(c)
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(newPricesResponseV1(resp))
+}
+
+// GetPricesV2 returns the price history with exact decimal prices.
+func (api *Service) GetPricesV2(c *fiber.Ctx) error {
+	resp, err := api.prices(c)
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+func (api *Service) prices(c *fiber.Ctx) (*application.PricesResponse, error) {
+	log := api.requestLogger(c)
+
+	idParam := c.Params("id")
+	if idParam == "" {
+		log.Warn("ID is required")
+		return nil, invalidRequest("id parameter is required")
+	}
+	subsID, err := uuid.Parse(idParam)
+	if err != nil {
+		log.Warn("ID is invalid", "id", idParam, "error", err)
+		return nil, invalidRequest("invalid id format")
+	}
+	resp, err := api.app.GetPrices(c.UserContext(), &application.PricesRequest{ID: subsID})
+	if err != nil {
+		log.Info("failed to get prices", "error", err)
+		return nil, err
+	}
+	return resp, nil
+}
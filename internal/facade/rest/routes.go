@@ -1,268 +1,405 @@
 package rest
 
 import (
+	"fmt"
 	"github.com/azaliaz/subs-api/internal/application"
+	"github.com/azaliaz/subs-api/pkg/money"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
+	"net/url"
 	"strconv"
-	"time"
+	"strings"
+)
+
+const (
+	HeaderIdempotencyKey     = "Idempotency-Key"
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
 )
 
 func (api *Service) Create(c *fiber.Ctx) error {
-	var req application.CreateRequest
-	if err := c.BodyParser(&req); err != nil {
-		api.log.Info("failed to parse body", "error", err)
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error":   "invalid request body",
-			"details": err.Error(),
-		})
-	}
+	return api.create(c, api.decodeCreateV1)
+}
 
-	if req.UserID == uuid.Nil {
-		api.log.Warn("User ID is required")
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "user_id is required"})
-	}
-	if req.ServiceName == "" {
-		api.log.Warn("Service name is required")
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "service_name is required"})
-	}
-	if req.Price <= 0 {
-		api.log.Warn("Price is required")
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "price must be greater than 0"})
-	}
-	if _, err := time.Parse("01-2006", req.StartDate); err != nil {
-		api.log.Warn("invalid start_date format", "start_date", req.StartDate, "error", err)
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid start_date format"})
-	}
+// create decodes the body with decode, which differs between the API
+// versions, and creates the subscription.
+func (api *Service) create(c *fiber.Ctx, decode func(*fiber.Ctx, *application.CreateRequest) error) error {
+	log := api.requestLogger(c)
 
-	if req.EndDate != nil && *req.EndDate != "" {
-		if _, err := time.Parse("01-2006", *req.EndDate); err != nil {
-			api.log.Warn("invalid end_date format", "end_date", *req.EndDate, "error", err)
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid end_date format"})
-		}
+	var req application.CreateRequest
+	if err := decode(c, &req); err != nil {
+		log.Info("failed to parse body", "error", err)
+		return err
 	}
+	req.IdempotencyKey = c.Get(HeaderIdempotencyKey)
 
-	resp, err := api.app.Create(c.Context(), &req)
+	resp, err := api.app.Create(c.UserContext(), &req)
 	if err != nil {
-		api.log.Info("failed to create", "error", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		log.Info("failed to create", "error", err)
+		return err
+	}
+	if resp.Replayed {
+		c.Set(HeaderIdempotentReplayed, "true")
 	}
 	return c.Status(fiber.StatusCreated).JSON(resp)
 }
 
 func (api *Service) GetInfo(c *fiber.Ctx) error {
+	resp, err := api.getInfo(c)
+	if err != nil {
+		return err
+	}
+	if notModified(c, etag(resp.Version)) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+	return c.Status(fiber.StatusOK).JSON(newSubscriptionV1(resp))
+}
+
+// getInfo loads the subscription named by the id parameter and sets its
+// ETag.
+func (api *Service) getInfo(c *fiber.Ctx) (*application.GetInfoResponse, error) {
+	log := api.requestLogger(c)
+
 	idParam := c.Params("id")
 	if idParam == "" {
-		api.log.Warn("ID is required")
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "id parameter is required",
-		})
+		log.Warn("ID is required")
+		return nil, invalidRequest("id parameter is required")
 	}
 	subsID, err := uuid.Parse(idParam)
 	if err != nil {
-		api.log.Warn("ID is invalid", "id", idParam, "error", err)
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid id format",
-		})
+		log.Warn("ID is invalid", "id", idParam, "error", err)
+		return nil, invalidRequest("invalid id format")
 	}
-	resp, err := api.app.GetInfo(c.Context(), &application.GetInfoRequest{ID: subsID})
+	resp, err := api.app.GetInfo(c.UserContext(), &application.GetInfoRequest{ID: subsID})
 	if err != nil {
-		api.log.Info("failed to get info", "error", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		log.Info("failed to get info", "error", err)
+		return nil, err
 	}
-	if resp == nil {
-		api.log.Info("subscription not found", "id", subsID)
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "subscription not found",
-		})
-	}
-	return c.Status(fiber.StatusOK).JSON(resp)
+	c.Set(fiber.HeaderETag, etag(resp.Version))
+	return resp, nil
 }
 
 func (api *Service) GetList(c *fiber.Ctx) error {
-	var req application.ListRequest
-	if userID := c.Query("user_id"); userID != "" {
-		uid, err := uuid.Parse(userID)
-		if err != nil {
-			api.log.Warn("invalid user id format", "user_id", userID, "error", err)
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid user_id"})
-		}
-		req.UserID = &uid
+	resp, err := api.list(c)
+	if err != nil {
+		return err
 	}
+	return c.Status(fiber.StatusOK).JSON(newListResponseV1(resp))
+}
+
+// list runs the list request described by the query and sets the Link
+// header; the API versions differ only in the shape of the body.
+func (api *Service) list(c *fiber.Ctx) (*application.ListResponse, error) {
+	log := api.requestLogger(c)
+
+	var req application.ListRequest
+	invalid := application.FieldErrors{}
+	req.UserIDs = queryUUIDs(c, "user_id", invalid)
 	if service := c.Query("service_name"); service != "" {
 		req.ServiceName = &service
 	}
+	req.ServiceMatch = c.Query("service_match")
 	if from := c.Query("from"); from != "" {
-		if _, err := time.Parse("01-2006", from); err != nil {
-			api.log.Warn("invalid from format", "from", from, "error", err)
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid From date format"})
-		}
 		req.From = &from
 	}
 	if to := c.Query("to"); to != "" {
-		if _, err := time.Parse("01-2006", to); err != nil {
-			api.log.Warn("invalid to format", "to", to, "error", err)
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid To date format"})
-		}
 		req.To = &to
 	}
-	if limit := c.Query("limit"); limit != "" {
-		l, err := strconv.Atoi(limit)
-		if err != nil || l <= 0 {
-			api.log.Warn("invalid limit format", "limit", limit, "error", err)
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid limit"})
-		}
-		req.Limit = &l
+	req.PriceMin = queryDecimal(c, "price_min")
+	req.PriceMax = queryDecimal(c, "price_max")
+	req.Status = c.Query("status")
+	if asOf := c.Query("as_of"); asOf != "" {
+		req.AsOf = &asOf
+	}
+	req.Sort = c.Query("sort")
+	req.Limit = queryInt(c, "limit", invalid)
+	req.Offset = queryInt(c, "offset", invalid)
+	req.IncludeDeleted = queryBool(c, "include_deleted", invalid)
+	req.PageToken = c.Query("page_token")
+	if len(invalid) > 0 {
+		invalid.Merge(req.Validate())
+		log.Warn("invalid list query", "error", invalid.Err())
+		return nil, invalid.Err()
 	}
-	if offset := c.Query("offset"); offset != "" {
-		o, err := strconv.Atoi(offset)
-		if err != nil || o < 0 {
-			api.log.Warn("invalid offset format", "offset", offset, "error", err)
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid offset"})
-		}
-		req.Offset = &o
+
+	resp, err := api.app.List(c.UserContext(), &req)
+	if err != nil {
+		log.Info("failed to list", "error", err)
+		return nil, err
+	}
+	if resp.NextPageToken != "" {
+		c.Set(fiber.HeaderLink, nextPageLink(c, resp.NextPageToken))
 	}
+	return resp, nil
+}
+
+// nextPageLink is a Link header value pointing at the page that follows,
+// with the same filters and limit.
+func nextPageLink(c *fiber.Ctx, token string) string {
+	query, _ := url.ParseQuery(string(c.Request().URI().QueryString()))
+	query.Del("offset")
+	query.Set("page_token", token)
+	return fmt.Sprintf(`<%s?%s>; rel="next"`, c.Path(), query.Encode())
+}
 
-	resp, err := api.app.List(c.Context(), &req)
+// Replace handles PUT: the body is a complete subscription, as for Create.
+func (api *Service) Replace(c *fiber.Ctx) error {
+	resp, err := api.replace(c, api.decodeCreateV1)
 	if err != nil {
-		api.log.Info("failed to list", "error", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		return err
 	}
-	return c.Status(fiber.StatusOK).JSON(resp)
+	return c.Status(fiber.StatusOK).JSON(newUpdateResponseV1(resp))
 }
 
-func (api *Service) Update(c *fiber.Ctx) error {
+func (api *Service) replace(c *fiber.Ctx, decode func(*fiber.Ctx, *application.CreateRequest) error) (*application.UpdateResponse, error) {
+	log := api.requestLogger(c)
+
 	idParam := c.Params("id")
 	if idParam == "" {
-		api.log.Warn("ID parameter is required")
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "id parameter is required",
-		})
+		log.Warn("ID parameter is required")
+		return nil, invalidRequest("id parameter is required")
 	}
 	id, err := uuid.Parse(idParam)
 	if err != nil {
-		api.log.Warn("invalid id format", "id", idParam, "error", err)
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid id format"})
+		log.Warn("invalid id format", "id", idParam, "error", err)
+		return nil, invalidRequest("invalid id format")
 	}
-	var req application.UpdateRequest
-	if err := c.BodyParser(&req); err != nil {
-		api.log.Info("failed to parse body", "error", err)
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid request body",
-		})
-	}
-	if req.StartDate != nil {
-		if _, err := time.Parse("01-2006", *req.StartDate); err != nil {
-			api.log.Warn("invalid start_date format", "start_date", *req.StartDate, "error", err)
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "invalid start date format",
-			})
-		}
+	version, err := ifMatch(c)
+	if err != nil {
+		log.Info("invalid If-Match header", "error", err)
+		return nil, err
 	}
-	if req.EndDate != nil {
-		if _, err := time.Parse("01-2006", *req.EndDate); err != nil {
-			api.log.Warn("invalid end_date format", "end_date", *req.EndDate, "error", err)
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "invalid end date format",
-			})
-		}
+	var req application.ReplaceRequest
+	if err := decode(c, &req.CreateRequest); err != nil {
+		log.Info("failed to parse body", "error", err)
+		return nil, err
 	}
-	resp, err := api.app.Update(c.Context(), id, &req)
+	req.Version = version
+	resp, err := api.app.Replace(c.UserContext(), id, &req)
 	if err != nil {
-		api.log.Info("failed to update", "error", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		log.Info("failed to replace", "error", err)
+		return nil, err
 	}
-	return c.Status(fiber.StatusOK).JSON(resp)
+	c.Set(fiber.HeaderETag, etag(resp.Version))
+	return resp, nil
+}
+
+// Patch handles PATCH with a JSON Merge Patch body (RFC 7396).
+func (api *Service) Patch(c *fiber.Ctx) error {
+	resp, err := api.patch(c, api.decodeUpdateV1)
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(newUpdateResponseV1(resp))
+}
+
+func (api *Service) patch(c *fiber.Ctx, decode func(*fiber.Ctx, *application.UpdateRequest) error) (*application.UpdateResponse, error) {
+	log := api.requestLogger(c)
+
+	idParam := c.Params("id")
+	if idParam == "" {
+		log.Warn("ID parameter is required")
+		return nil, invalidRequest("id parameter is required")
+	}
+	id, err := uuid.Parse(idParam)
+	if err != nil {
+		log.Warn("invalid id format", "id", idParam, "error", err)
+		return nil, invalidRequest("invalid id format")
+	}
+	if !isMergePatch(c.Get(fiber.HeaderContentType)) {
+		log.Info("unsupported patch content type", "content_type", c.Get(fiber.HeaderContentType))
+		c.Set(HeaderAcceptPatch, MIMEMergePatch)
+		return nil, fiber.ErrUnsupportedMediaType
+	}
+	version, err := ifMatch(c)
+	if err != nil {
+		log.Info("invalid If-Match header", "error", err)
+		return nil, err
+	}
+	var req application.UpdateRequest
+	if err := decode(c, &req); err != nil {
+		log.Info("failed to parse body", "error", err)
+		return nil, err
+	}
+	req.Version = version
+	resp, err := api.app.Update(c.UserContext(), id, &req)
+	if err != nil {
+		log.Info("failed to update", "error", err)
+		return nil, err
+	}
+	c.Set(fiber.HeaderETag, etag(resp.Version))
+	return resp, nil
 }
 
 func (api *Service) Delete(c *fiber.Ctx) error {
+	log := api.requestLogger(c)
+
 	idParam := c.Params("id")
 	if idParam == "" {
-		api.log.Warn("ID parameter is required")
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "id parameter is required",
-		})
+		log.Warn("ID parameter is required")
+		return invalidRequest("id parameter is required")
 	}
 	subsID, err := uuid.Parse(idParam)
 	if err != nil {
-		api.log.Warn("ID is invalid", "id", idParam, "error", err)
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid id format",
-		})
+		log.Warn("ID is invalid", "id", idParam, "error", err)
+		return invalidRequest("invalid id format")
 	}
-	resp, err := api.app.Delete(c.Context(), &application.DeleteRequest{ID: subsID})
+	version, err := ifMatch(c)
 	if err != nil {
-		api.log.Info("failed to delete", "error", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		log.Info("invalid If-Match header", "error", err)
+		return err
 	}
-	if resp == nil {
-		api.log.Warn("subscription not found", "id", subsID)
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-			"error": "subscription not found",
-		})
+	resp, err := api.app.Delete(c.UserContext(), &application.DeleteRequest{ID: subsID, Version: version})
+	if err != nil {
+		log.Info("failed to delete", "error", err)
+		return err
 	}
 	return c.Status(fiber.StatusOK).JSON(resp)
 }
 
-func (api *Service) GetTotalSubscriptionsPrice(c *fiber.Ctx) error {
-	var req application.TotalRequest
+// Restore undoes a delete and returns the subscription as it is again.
+func (api *Service) Restore(c *fiber.Ctx) error {
+	resp, err := api.restore(c)
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(newUpdateResponseV1(resp))
+}
 
-	if userID := c.Query("user_id"); userID != "" {
-		uid, err := uuid.Parse(userID)
-		if err != nil {
-			api.log.Warn("invalid user id format", "user_id", userID, "error", err)
-			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-				"error": "invalid user_id",
-			})
-		}
-		req.UserID = &uid
+func (api *Service) restore(c *fiber.Ctx) (*application.UpdateResponse, error) {
+	log := api.requestLogger(c)
+
+	idParam := c.Params("id")
+	if idParam == "" {
+		log.Warn("ID parameter is required")
+		return nil, invalidRequest("id parameter is required")
 	}
+	id, err := uuid.Parse(idParam)
+	if err != nil {
+		log.Warn("ID is invalid", "id", idParam, "error", err)
+		return nil, invalidRequest("invalid id format")
+	}
+	resp, err := api.app.Restore(c.UserContext(), &application.RestoreRequest{ID: id})
+	if err != nil {
+		log.Info("failed to restore", "error", err)
+		return nil, err
+	}
+	c.Set(fiber.HeaderETag, etag(resp.Version))
+	return resp, nil
+}
 
+func (api *Service) GetTotalSubscriptionsPrice(c *fiber.Ctx) error {
+	resp, err := api.total(c)
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(newTotalResponseV1(resp))
+}
+
+func (api *Service) total(c *fiber.Ctx) (*application.TotalResponse, error) {
+	log := api.requestLogger(c)
+
+	var req application.TotalRequest
+	invalid := application.FieldErrors{}
+	req.UserID = queryUUID(c, "user_id", invalid)
 	if service := c.Query("service_name"); service != "" {
 		req.ServiceName = &service
 	}
-
 	req.From = c.Query("from")
-	if req.From == "" {
-		api.log.Warn("from parameter is required")
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "from is required",
-		})
+	req.To = c.Query("to")
+	req.Currency = c.Query("currency")
+	for _, raw := range c.Context().QueryArgs().PeekMulti("group_by") {
+		for _, group := range strings.Split(string(raw), ",") {
+			if group = strings.TrimSpace(group); group != "" {
+				req.GroupBy = append(req.GroupBy, group)
+			}
+		}
 	}
-	if _, err := time.Parse("01-2006", req.From); err != nil {
-		api.log.Warn("invalid from format", "from", req.From, "error", err)
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid From date format (expected MM-YYYY)",
-		})
+	if len(invalid) > 0 {
+		invalid.Merge(req.Validate())
+		log.Warn("invalid total query", "error", invalid.Err())
+		return nil, invalid.Err()
 	}
 
-	req.To = c.Query("to")
-	if req.To == "" {
-		api.log.Warn("to parameter is required")
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "to is required",
-		})
+	resp, err := api.app.GetTotalSubscriptionsPrice(c.UserContext(), &req)
+	if err != nil {
+		log.Info("failed to get total subscriptions price", "error", err)
+		return nil, err
+	}
+	return resp, nil
+}
+
+// queryUUID parses an optional UUID query parameter, recording a malformed
+// value in invalid.
+func queryUUID(c *fiber.Ctx, key string, invalid application.FieldErrors) *uuid.UUID {
+	value := c.Query(key)
+	if value == "" {
+		return nil
+	}
+	id, err := uuid.Parse(value)
+	if err != nil {
+		invalid.Add(key, "must be a UUID")
+		return nil
 	}
-	if _, err := time.Parse("01-2006", req.To); err != nil {
-		api.log.Warn("invalid to format", "to", req.To, "error", err)
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "invalid To date format (expected MM-YYYY)",
-		})
+	return &id
+}
+
+// queryUUIDs parses a query parameter that may be repeated or hold a
+// comma-separated list of UUIDs.
+func queryUUIDs(c *fiber.Ctx, key string, invalid application.FieldErrors) []uuid.UUID {
+	var ids []uuid.UUID
+	for _, value := range c.Context().QueryArgs().PeekMulti(key) {
+		for _, part := range strings.Split(string(value), ",") {
+			if part == "" {
+				continue
+			}
+			id, err := uuid.Parse(part)
+			if err != nil {
+				invalid.Add(key, "must be a UUID")
+				return nil
+			}
+			ids = append(ids, id)
+		}
 	}
+	return ids
+}
 
-	resp, err := api.app.GetTotalSubscriptionsPrice(c.Context(), &req)
+// queryDecimal returns an optional decimal query parameter; the application
+// layer validates it.
+func queryDecimal(c *fiber.Ctx, key string) *money.Decimal {
+	value := c.Query(key)
+	if value == "" {
+		return nil
+	}
+	d := money.Decimal(value)
+	return &d
+}
+
+// queryBool parses an optional boolean query parameter, false when absent,
+// recording a malformed value in invalid.
+func queryBool(c *fiber.Ctx, key string, invalid application.FieldErrors) bool {
+	value := c.Query(key)
+	if value == "" {
+		return false
+	}
+	b, err := strconv.ParseBool(value)
 	if err != nil {
-		api.log.Info("failed to get total subscriptions price", "error", err)
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": err.Error(),
-		})
+		invalid.Add(key, "must be true or false")
+		return false
 	}
+	return b
+}
 
-	return c.Status(fiber.StatusOK).JSON(resp)
+// queryInt parses an optional integer query parameter, recording a malformed
+// value in invalid.
+func queryInt(c *fiber.Ctx, key string, invalid application.FieldErrors) *int {
+	value := c.Query(key)
+	if value == "" {
+		return nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		invalid.Add(key, "must be an integer")
+		return nil
+	}
+	return &n
 }
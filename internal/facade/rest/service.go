@@ -4,16 +4,22 @@ import (
 	"context"
 	"fmt"
 	"github.com/azaliaz/subs-api/internal/application"
+	"github.com/azaliaz/subs-api/pkg/logging"
+	"github.com/azaliaz/subs-api/pkg/service"
 	"github.com/gofiber/fiber/v2"
 	"log/slog"
+	"sync/atomic"
 	"time"
 )
 
 type Service struct {
-	log    *slog.Logger
-	config *Config
-	fiber  *fiber.App
-	app    application.SubscriptionsService
+	log       *slog.Logger
+	config    *Config
+	fiber     *fiber.App
+	admin     *fiber.App
+	app       application.SubscriptionsService
+	health    service.HealthChecker
+	listening atomic.Bool
 }
 
 func NewAPI(
@@ -28,8 +34,70 @@ func NewAPI(
 	}
 }
 
+// requestLogger returns the logger that RequestID attached to the request,
+// falling back to the API logger.
+func (api *Service) requestLogger(c *fiber.Ctx) *slog.Logger {
+	return logging.FromContext(c.UserContext(), api.log)
+}
+
 func (api *Service) Init() error {
-	api.fiber = fiber.New(fiber.Config{
+	api.fiber = fiber.New(api.fiberConfig())
+
+	api.fiber.Hooks().OnListen(func(fiber.ListenData) error {
+		api.listening.Store(true)
+		return nil
+	})
+
+	api.fiber.Use(api.Trace)
+	api.fiber.Use(api.RequestID)
+	api.fiber.Use(api.ObserveRequests)
+
+	api.fiber.Get("/healthz", api.Liveness)
+	api.fiber.Get("/readyz", api.Readiness)
+
+	// Metrics are served on a separate admin port when one is configured, so
+	// that they are not exposed together with the public API. On the main
+	// port they require an admin token.
+	if api.config.MetricsPort != 0 && api.config.MetricsPort != api.config.Port {
+		api.admin = fiber.New(api.fiberConfig())
+		api.admin.Get("/metrics", metricsHandler())
+	} else {
+		api.fiber.Get("/metrics", api.Authenticate, api.RequireAdmin, metricsHandler())
+	}
+
+	api.fiber.Use("/api", api.Authenticate)
+
+	api.fiber.Post("/api/create", api.Create)
+	api.fiber.Get("/api/info/:id", api.GetInfo)
+	api.fiber.Get("/api/info/:id/prices", api.GetPrices)
+	api.fiber.Get("/api/list", api.GetList)
+	api.fiber.Put("/api/update/:id", api.Replace)
+	api.fiber.Put("/api/subscriptions/:id", api.Replace)
+	api.fiber.Patch("/api/subscriptions/:id", api.Patch)
+	api.fiber.Delete("/api/delete/:id", api.Delete)
+	api.fiber.Post("/api/subscriptions/:id/restore", api.Restore)
+	api.fiber.Get("/api/total", api.GetTotalSubscriptionsPrice)
+
+	api.fiber.Get("/api/fx-rates", api.GetFXRates)
+	api.fiber.Post("/api/fx-rates/import", api.ImportFXRates)
+	api.fiber.Put("/api/fx-rates/:base/:quote/:month", api.PutFXRate)
+	api.fiber.Delete("/api/fx-rates/:base/:quote/:month", api.DeleteFXRate)
+
+	api.fiber.Get("/api/v2/subscriptions", api.GetListV2)
+	api.fiber.Post("/api/v2/subscriptions", api.CreateV2)
+	api.fiber.Get("/api/v2/subscriptions/:id", api.GetInfoV2)
+	api.fiber.Get("/api/v2/subscriptions/:id/prices", api.GetPricesV2)
+	api.fiber.Put("/api/v2/subscriptions/:id", api.ReplaceV2)
+	api.fiber.Patch("/api/v2/subscriptions/:id", api.PatchV2)
+	api.fiber.Delete("/api/v2/subscriptions/:id", api.Delete)
+	api.fiber.Post("/api/v2/subscriptions/:id/restore", api.RestoreV2)
+	api.fiber.Get("/api/v2/total", api.GetTotalV2)
+
+	return nil
+}
+
+func (api *Service) fiberConfig() fiber.Config {
+	return fiber.Config{
 		ReadTimeout:           time.Duration(api.config.FiberReadTimeout) * time.Second,
 		WriteTimeout:          time.Duration(api.config.FiberWriteTimeout) * time.Second,
 		IdleTimeout:           time.Duration(api.config.FiberIdleTimeout) * time.Second,
@@ -39,32 +107,60 @@ func (api *Service) Init() error {
 		CaseSensitive:         api.config.FiberCaseSensitive,
 		DisableStartupMessage: api.config.FiberDisableStartupMessage,
 		DisableKeepalive:      api.config.FiberDisableKeepalive,
-	})
+		ErrorHandler:          api.ErrorHandler,
+	}
+}
 
-	api.fiber.Post("/api/create", api.Create)
-	api.fiber.Get("/api/info/:id", api.GetInfo)
-	api.fiber.Get("/api/list", api.GetList)
-	api.fiber.Put("/api/update/:id", api.Update)
-	api.fiber.Delete("/api/delete/:id", api.Delete)
-	api.fiber.Get("/api/total", api.GetTotalSubscriptionsPrice)
+func (api *Service) Run(_ context.Context) error {
+	servers := map[string]*fiber.App{fmt.Sprintf(":%d", api.config.Port): api.fiber}
+	if api.admin != nil {
+		servers[fmt.Sprintf(":%d", api.config.MetricsPort)] = api.admin
+	}
 
-	addr := fmt.Sprintf(":%d", api.config.Port)
-	err := api.fiber.Listen(addr)
-	if err != nil {
-		return err
+	errCh := make(chan error, len(servers))
+	for addr, server := range servers {
+		go func(addr string, server *fiber.App) {
+			api.log.Info("start rest server", "addr", addr)
+			if err := server.Listen(addr); err != nil {
+				api.log.Error("start rest server", "addr", addr, "error", err)
+				errCh <- fmt.Errorf("listen on %s: %w", addr, err)
+				return
+			}
+			errCh <- nil
+		}(addr, server)
+	}
+
+	for range servers {
+		if err := <-errCh; err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-func (api *Service) Run(ctx context.Context) {
-	addr := fmt.Sprintf(":%d", api.config.Port)
-	api.log.Info("start rest server", "addr", addr)
-	if err := api.fiber.Listen(addr); err != nil {
-		api.log.Error("start rest server", "addr", addr, "portal", err)
+func (api *Service) Stop() {
+	if api.fiber == nil {
 		return
 	}
-}
 
-func (api *Service) Stop() {
+	timeout := time.Duration(api.config.FiberShutdownTimeout) * time.Second
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	api.listening.Store(false)
+	api.log.Info("stopping rest server", "timeout", timeout)
+	if err := api.fiber.ShutdownWithContext(ctx); err != nil {
+		api.log.Error("failed to gracefully stop rest server", "error", err)
+	}
+	if api.admin != nil {
+		if err := api.admin.ShutdownWithContext(ctx); err != nil {
+			api.log.Error("failed to gracefully stop admin server", "error", err)
+		}
+	}
+	api.log.Info("rest server has been stopped")
 }
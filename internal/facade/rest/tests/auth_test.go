@@ -0,0 +1,153 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"github.com/azaliaz/subs-api/internal/application"
+	"github.com/azaliaz/subs-api/internal/application/mocks"
+	"github.com/azaliaz/subs-api/internal/facade/rest"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthenticate_MissingToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockApp := mocks.NewMockSubscriptionsService(ctrl)
+	api := rest.NewAPI(slog.Default(), nil, mockApp)
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+	app.Use("/api", api.Authenticate)
+	app.Add("GET", "/api/list", api.GetList)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/list", nil)
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, "Bearer", resp.Header.Get(fiber.HeaderWWWAuthenticate))
+}
+
+func TestAuthenticate_InvalidToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockApp := mocks.NewMockSubscriptionsService(ctrl)
+	mockApp.EXPECT().Authenticate(gomock.Any(), "bad-token").
+		Return(nil, application.ErrUnauthorized)
+
+	api := rest.NewAPI(slog.Default(), nil, mockApp)
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+	app.Use("/api", api.Authenticate)
+	app.Add("GET", "/api/list", api.GetList)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/list", nil)
+	req.Header.Set("Authorization", "Bearer bad-token")
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, fiber.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestAuthenticate_PrincipalIsPassedToApplication(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	principal := &application.Principal{Subject: uuid.New(), Role: application.RoleUser}
+
+	mockApp := mocks.NewMockSubscriptionsService(ctrl)
+	mockApp.EXPECT().Authenticate(gomock.Any(), "good-token").Return(principal, nil)
+	mockApp.EXPECT().List(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, _ *application.ListRequest) (*application.ListResponse, error) {
+			got, ok := application.PrincipalFromContext(ctx)
+			assert.True(t, ok)
+			assert.Equal(t, principal, got)
+			return &application.ListResponse{}, nil
+		})
+
+	api := rest.NewAPI(slog.Default(), nil, mockApp)
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+	app.Use("/api", api.Authenticate)
+	app.Add("GET", "/api/list", api.GetList)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/list", nil)
+	req.Header.Set("Authorization", "Bearer good-token")
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+}
+
+func TestForbiddenIsMappedTo403(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	subID := uuid.New()
+	mockApp := mocks.NewMockSubscriptionsService(ctrl)
+	mockApp.EXPECT().GetInfo(gomock.Any(), &application.GetInfoRequest{ID: subID}).
+		Return(nil, fmt.Errorf("get info: %w", application.ErrForbidden))
+
+	api := rest.NewAPI(slog.Default(), nil, mockApp)
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+	app.Add("GET", "/api/info/:id", api.GetInfo)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/info/"+subID.String(), nil)
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, fiber.StatusForbidden, resp.StatusCode)
+}
+
+func TestRequireAdmin(t *testing.T) {
+	tests := []struct {
+		name      string
+		token     string
+		principal *application.Principal
+		status    int
+	}{
+		{
+			name:   "no token",
+			status: fiber.StatusUnauthorized,
+		},
+		{
+			name:      "user",
+			token:     "user-token",
+			principal: &application.Principal{Subject: uuid.New(), Role: application.RoleUser},
+			status:    fiber.StatusForbidden,
+		},
+		{
+			name:      "admin",
+			token:     "admin-token",
+			principal: &application.Principal{Subject: uuid.New(), Role: application.RoleAdmin},
+			status:    fiber.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockApp := mocks.NewMockSubscriptionsService(ctrl)
+			if tt.principal != nil {
+				mockApp.EXPECT().Authenticate(gomock.Any(), tt.token).Return(tt.principal, nil)
+			}
+
+			api := rest.NewAPI(slog.Default(), nil, mockApp)
+			app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+			app.Get("/metrics", api.Authenticate, api.RequireAdmin, func(c *fiber.Ctx) error {
+				return c.SendString("subs_api_subscriptions_created_total 1")
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.token != "" {
+				req.Header.Set(fiber.HeaderAuthorization, "Bearer "+tt.token)
+			}
+			resp, _ := app.Test(req)
+
+			assert.Equal(t, tt.status, resp.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,227 @@
+package tests
+
+import (
+	"encoding/json"
+	"github.com/azaliaz/subs-api/internal/application"
+	"github.com/azaliaz/subs-api/internal/application/mocks"
+	"github.com/azaliaz/subs-api/internal/facade/rest"
+	"github.com/azaliaz/subs-api/pkg/money"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStrictJSON_Create(t *testing.T) {
+	userID := uuid.New()
+	valid := `{"user_id":"` + userID.String() + `","service_name":"Netflix","price":10,"start_date":"09-2025"}`
+
+	tests := []struct {
+		name   string
+		body   string
+		errors map[string]string
+	}{
+		{
+			name: "valid",
+			body: valid,
+		},
+		{
+			name:   "unknown field",
+			body:   `{"user_id":"` + userID.String() + `","service_name":"Netflix","price":10,"start_date":"09-2025","end_data":"12-2025"}`,
+			errors: map[string]string{"end_data": "is not a known field"},
+		},
+		{
+			name:   "field name in wrong case",
+			body:   `{"User_ID":"` + userID.String() + `","service_name":"Netflix","price":10,"start_date":"09-2025"}`,
+			errors: map[string]string{"User_ID": "is not a known field"},
+		},
+		{
+			name:   "duplicate key",
+			body:   `{"user_id":"` + userID.String() + `","service_name":"Netflix","price":10,"price":20,"start_date":"09-2025"}`,
+			errors: map[string]string{"price": "is duplicated"},
+		},
+		{
+			name: "wrong types",
+			body: `{"user_id":"not-a-uuid","service_name":42,"price":"10","start_date":"09-2025","end_date":["12-2025"]}`,
+			errors: map[string]string{
+				"user_id":      "has an invalid value",
+				"service_name": "must be a string",
+				"price":        "must be an integer",
+				"end_date":     "must be a string",
+			},
+		},
+		{
+			name:   "fractional price",
+			body:   `{"user_id":"` + userID.String() + `","service_name":"Netflix","price":9.99,"start_date":"09-2025"}`,
+			errors: map[string]string{"price": "must be an integer"},
+		},
+		{
+			name:   "trailing data",
+			body:   valid + `{"price":1}`,
+			errors: map[string]string{"body": "must contain a single JSON value"},
+		},
+		{
+			name:   "not an object",
+			body:   `[1, 2]`,
+			errors: map[string]string{"body": "must be an object"},
+		},
+		{
+			name:   "malformed",
+			body:   `{"price":}`,
+			errors: map[string]string{"body": "is not valid JSON at offset 10"},
+		},
+		{
+			name:   "empty",
+			body:   ``,
+			errors: map[string]string{"body": "is required"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockApp := mocks.NewMockSubscriptionsService(ctrl)
+			if tt.errors == nil {
+				mockApp.EXPECT().Create(gomock.Any(), &application.CreateRequest{
+					UserID:      userID,
+					ServiceName: "Netflix",
+					Price:       "10",
+					StartDate:   "09-2025",
+				}).Return(&application.CreateResponse{ID: uuid.New()}, nil)
+			}
+
+			api := rest.NewAPI(slog.Default(), &rest.Config{StrictJSON: true}, mockApp)
+			app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+			app.Post("/api/create", api.Create)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/create", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, _ := app.Test(req)
+
+			if tt.errors == nil {
+				assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+				return
+			}
+			assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+			var problem rest.Problem
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+			assert.Equal(t, tt.errors, problem.Errors)
+		})
+	}
+}
+
+func TestStrictJSON_Patch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockApp := mocks.NewMockSubscriptionsService(ctrl)
+	api := rest.NewAPI(slog.Default(), &rest.Config{StrictJSON: true}, mockApp)
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+	app.Patch("/api/subscriptions/:id", api.Patch)
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/subscriptions/"+uuid.NewString(), strings.NewReader(`{"end_data":"12-2025","price":"10"}`))
+	req.Header.Set("Content-Type", rest.MIMEMergePatch)
+	resp, _ := app.Test(req)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+	var problem rest.Problem
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+	assert.Equal(t, map[string]string{
+		"end_data": "is not a known field",
+		"price":    "must be an integer",
+	}, problem.Errors)
+}
+
+func TestStrictJSON_CreateV2(t *testing.T) {
+	userID := uuid.New()
+
+	tests := []struct {
+		name   string
+		body   string
+		price  money.Decimal
+		errors map[string]string
+	}{
+		{
+			name:  "price as a number",
+			body:  `{"user_id":"` + userID.String() + `","service_name":"Netflix","price":9.99,"start_date":"09-2025"}`,
+			price: "9.99",
+		},
+		{
+			name:  "price as a decimal string",
+			body:  `{"user_id":"` + userID.String() + `","service_name":"Netflix","price":"10","start_date":"09-2025"}`,
+			price: "10",
+		},
+		{
+			name:   "price of a wrong type",
+			body:   `{"user_id":"` + userID.String() + `","service_name":"Netflix","price":true,"start_date":"09-2025"}`,
+			errors: map[string]string{"price": "must be a number or a decimal string"},
+		},
+		{
+			name:   "price in exponent notation",
+			body:   `{"user_id":"` + userID.String() + `","service_name":"Netflix","price":1e1,"start_date":"09-2025"}`,
+			errors: map[string]string{"price": "must be a decimal number such as 299.90"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockApp := mocks.NewMockSubscriptionsService(ctrl)
+			if tt.errors == nil {
+				mockApp.EXPECT().Create(gomock.Any(), &application.CreateRequest{
+					UserID:      userID,
+					ServiceName: "Netflix",
+					Price:       tt.price,
+					StartDate:   "09-2025",
+				}).Return(&application.CreateResponse{ID: uuid.New()}, nil)
+			}
+
+			api := rest.NewAPI(slog.Default(), &rest.Config{StrictJSON: true}, mockApp)
+			app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+			app.Post("/api/v2/subscriptions", api.CreateV2)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v2/subscriptions", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, _ := app.Test(req)
+
+			if tt.errors == nil {
+				assert.Equal(t, fiber.StatusCreated, resp.StatusCode)
+				return
+			}
+			assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+			var problem rest.Problem
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+			assert.Equal(t, tt.errors, problem.Errors)
+		})
+	}
+}
+
+func TestStrictJSON_Disabled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockApp := mocks.NewMockSubscriptionsService(ctrl)
+	mockApp.EXPECT().Update(gomock.Any(), gomock.Any(), &application.UpdateRequest{}).
+		Return(&application.UpdateResponse{}, nil)
+
+	api := rest.NewAPI(slog.Default(), &rest.Config{StrictJSON: false}, mockApp)
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+	app.Patch("/api/subscriptions/:id", api.Patch)
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/subscriptions/"+uuid.NewString(), strings.NewReader(`{"end_data":"12-2025"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ := app.Test(req)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+}
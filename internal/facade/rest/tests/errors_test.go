@@ -0,0 +1,79 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/azaliaz/subs-api/internal/application"
+	"github.com/azaliaz/subs-api/internal/application/mocks"
+	"github.com/azaliaz/subs-api/internal/facade/rest"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestErrorHandler(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{name: "validation", err: fmt.Errorf("%w: end_date cannot be before start_date", application.ErrValidation), status: fiber.StatusBadRequest},
+		{name: "unauthorized", err: application.ErrUnauthorized, status: fiber.StatusUnauthorized},
+		{name: "forbidden", err: application.ErrForbidden, status: fiber.StatusForbidden},
+		{name: "not found", err: fmt.Errorf("delete request: %w", application.ErrNotFound), status: fiber.StatusNotFound},
+		{name: "conflict", err: fmt.Errorf("%w: duplicate key", application.ErrConflict), status: fiber.StatusConflict},
+		{name: "internal", err: errors.New("db error"), status: fiber.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		for _, additional := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/additional=%t", tt.name, additional), func(t *testing.T) {
+				ctrl := gomock.NewController(t)
+				defer ctrl.Finish()
+
+				subID := uuid.New()
+				mockApp := mocks.NewMockSubscriptionsService(ctrl)
+				mockApp.EXPECT().GetInfo(gomock.Any(), gomock.Any()).Return(nil, tt.err)
+
+				api := rest.NewAPI(slog.Default(), &rest.Config{IsAdditionalErrorsEnabled: additional}, mockApp)
+				app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+				app.Get("/api/info/:id", api.GetInfo)
+
+				resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/api/info/"+subID.String(), nil))
+				assert.Equal(t, tt.status, resp.StatusCode)
+				assert.Equal(t, "application/problem+json", resp.Header.Get(fiber.HeaderContentType))
+
+				var problem rest.Problem
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+				assert.Equal(t, "about:blank", problem.Type)
+				assert.Equal(t, http.StatusText(tt.status), problem.Title)
+				assert.Equal(t, tt.status, problem.Status)
+				assert.Equal(t, "/api/info/"+subID.String(), problem.Instance)
+				if additional {
+					assert.Equal(t, tt.err.Error(), problem.Detail)
+				} else {
+					assert.Empty(t, problem.Detail)
+				}
+			})
+		}
+	}
+}
+
+func TestErrorHandler_UnmatchedRoute(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	api := rest.NewAPI(slog.Default(), nil, mocks.NewMockSubscriptionsService(ctrl))
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+
+	resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/unknown", nil))
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, "application/problem+json", resp.Header.Get(fiber.HeaderContentType))
+}
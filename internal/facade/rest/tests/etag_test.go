@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"github.com/azaliaz/subs-api/internal/application"
+	"github.com/azaliaz/subs-api/internal/application/mocks"
+	"github.com/azaliaz/subs-api/internal/facade/rest"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGetInfo_ETag(t *testing.T) {
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		status      int
+	}{
+		{name: "no precondition", status: fiber.StatusOK},
+		{name: "current version", ifNoneMatch: `"7"`, status: fiber.StatusNotModified},
+		{name: "weak current version", ifNoneMatch: `"3", W/"7"`, status: fiber.StatusNotModified},
+		{name: "stale version", ifNoneMatch: `"6"`, status: fiber.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			subID := uuid.New()
+			mockApp := mocks.NewMockSubscriptionsService(ctrl)
+			mockApp.EXPECT().GetInfo(gomock.Any(), &application.GetInfoRequest{ID: subID}).
+				Return(&application.GetInfoResponse{ID: subID, Version: 7}, nil)
+
+			api := rest.NewAPI(slog.Default(), nil, mockApp)
+			app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+			app.Get("/api/info/:id", api.GetInfo)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/info/"+subID.String(), nil)
+			if tt.ifNoneMatch != "" {
+				req.Header.Set(fiber.HeaderIfNoneMatch, tt.ifNoneMatch)
+			}
+			resp, _ := app.Test(req)
+
+			assert.Equal(t, tt.status, resp.StatusCode)
+			assert.Equal(t, `"7"`, resp.Header.Get(fiber.HeaderETag))
+		})
+	}
+}
+
+func TestPatch_IfMatch(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	subID := uuid.New()
+	version := int64(7)
+	mockApp := mocks.NewMockSubscriptionsService(ctrl)
+	mockApp.EXPECT().Update(gomock.Any(), subID, gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ uuid.UUID, req *application.UpdateRequest) (*application.UpdateResponse, error) {
+			assert.Equal(t, &version, req.Version)
+			return &application.UpdateResponse{GetInfoResponse: application.GetInfoResponse{ID: subID, Version: 8}}, nil
+		})
+
+	api := rest.NewAPI(slog.Default(), nil, mockApp)
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+	app.Patch("/api/subscriptions/:id", api.Patch)
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/subscriptions/"+subID.String(), strings.NewReader(`{"price":500}`))
+	req.Header.Set("Content-Type", rest.MIMEMergePatch)
+	req.Header.Set(fiber.HeaderIfMatch, `"7"`)
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Equal(t, `"8"`, resp.Header.Get(fiber.HeaderETag))
+}
+
+func TestDelete_IfMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		ifMatch string
+		mock    func(mockApp *mocks.MockSubscriptionsService, subID uuid.UUID)
+		status  int
+	}{
+		{
+			name:    "stale version",
+			ifMatch: `"3"`,
+			mock: func(mockApp *mocks.MockSubscriptionsService, subID uuid.UUID) {
+				version := int64(3)
+				mockApp.EXPECT().Delete(gomock.Any(), &application.DeleteRequest{ID: subID, Version: &version}).
+					Return(nil, fmt.Errorf("%w: version changed", application.ErrPreconditionFailed))
+			},
+			status: fiber.StatusPreconditionFailed,
+		},
+		{
+			name:    "any version",
+			ifMatch: "*",
+			mock: func(mockApp *mocks.MockSubscriptionsService, subID uuid.UUID) {
+				mockApp.EXPECT().Delete(gomock.Any(), &application.DeleteRequest{ID: subID}).
+					Return(&application.DeleteResponse{Deleted: true}, nil)
+			},
+			status: fiber.StatusOK,
+		},
+		{
+			name:    "weak tag never matches",
+			ifMatch: `W/"3"`,
+			mock:    func(*mocks.MockSubscriptionsService, uuid.UUID) {},
+			status:  fiber.StatusPreconditionFailed,
+		},
+		{
+			name:    "several tags",
+			ifMatch: `"3", "4"`,
+			mock:    func(*mocks.MockSubscriptionsService, uuid.UUID) {},
+			status:  fiber.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			subID := uuid.New()
+			mockApp := mocks.NewMockSubscriptionsService(ctrl)
+			tt.mock(mockApp, subID)
+
+			api := rest.NewAPI(slog.Default(), nil, mockApp)
+			app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+			app.Delete("/api/delete/:id", api.Delete)
+
+			req := httptest.NewRequest(http.MethodDelete, "/api/delete/"+subID.String(), nil)
+			req.Header.Set(fiber.HeaderIfMatch, tt.ifMatch)
+			resp, _ := app.Test(req)
+
+			assert.Equal(t, tt.status, resp.StatusCode)
+		})
+	}
+}
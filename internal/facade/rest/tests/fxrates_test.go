@@ -0,0 +1,162 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/azaliaz/subs-api/internal/application"
+	"github.com/azaliaz/subs-api/internal/application/mocks"
+	"github.com/azaliaz/subs-api/internal/facade/rest"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestImportFXRates(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        *application.ImportFXRatesRequest
+		status      int
+		detail      string
+	}{
+		{
+			name:        "csv",
+			contentType: "text/csv; charset=utf-8",
+			body:        "base,quote,month,rate\nUSD,RUB,01-2025,101.5\nEUR, RUB, 01-2025, 110\n",
+			want: &application.ImportFXRatesRequest{Rates: []application.FXRate{
+				{Base: "USD", Quote: "RUB", Month: "01-2025", Rate: "101.5"},
+				{Base: "EUR", Quote: "RUB", Month: "01-2025", Rate: "110"},
+			}},
+			status: fiber.StatusOK,
+		},
+		{
+			name:        "json",
+			contentType: fiber.MIMEApplicationJSON,
+			body:        `{"rates":[{"base":"USD","quote":"RUB","month":"01-2025","rate":101.5},{"base":"RUB","quote":"USD","month":"01-2025","rate":"0.0098522167"}]}`,
+			want: &application.ImportFXRatesRequest{Rates: []application.FXRate{
+				{Base: "USD", Quote: "RUB", Month: "01-2025", Rate: "101.5"},
+				{Base: "RUB", Quote: "USD", Month: "01-2025", Rate: "0.0098522167"},
+			}},
+			status: fiber.StatusOK,
+		},
+		{
+			name:        "wrong header",
+			contentType: "text/csv",
+			body:        "from,to,month,rate\nUSD,RUB,01-2025,101.5\n",
+			status:      fiber.StatusBadRequest,
+			detail:      "validation failed: line 1: header must be base,quote,month,rate",
+		},
+		{
+			name:        "rate is not a number",
+			contentType: "text/csv",
+			body:        "base,quote,month,rate\nUSD,RUB,01-2025,101.5\nEUR,RUB,01-2025,n/a\n",
+			status:      fiber.StatusBadRequest,
+			detail:      "validation failed: line 3: rate must be a number",
+		},
+		{
+			name:        "missing column",
+			contentType: "text/csv",
+			body:        "base,quote,month,rate\nUSD,RUB,01-2025\n",
+			status:      fiber.StatusBadRequest,
+		},
+		{
+			name:        "unsupported content type",
+			contentType: fiber.MIMETextPlain,
+			body:        "USD RUB 01-2025 101.5",
+			status:      fiber.StatusUnsupportedMediaType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockApp := mocks.NewMockSubscriptionsService(ctrl)
+			if tt.want != nil {
+				mockApp.EXPECT().ImportFXRates(gomock.Any(), tt.want).
+					Return(&application.ImportFXRatesResponse{Imported: len(tt.want.Rates)}, nil)
+			}
+
+			api := rest.NewAPI(slog.Default(), &rest.Config{IsAdditionalErrorsEnabled: true}, mockApp)
+			app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+			app.Post("/api/fx-rates/import", api.ImportFXRates)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/fx-rates/import", strings.NewReader(tt.body))
+			req.Header.Set(fiber.HeaderContentType, tt.contentType)
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.status, resp.StatusCode)
+			body, _ := io.ReadAll(resp.Body)
+			if tt.want != nil {
+				assert.JSONEq(t, fmt.Sprintf(`{"imported":%d}`, len(tt.want.Rates)), string(body))
+			}
+			if tt.detail != "" {
+				var problem rest.Problem
+				require.NoError(t, json.Unmarshal(body, &problem))
+				assert.Equal(t, tt.detail, problem.Detail)
+			}
+		})
+	}
+}
+
+func TestPutFXRate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	rate := &application.FXRate{Base: "USD", Quote: "RUB", Month: "03-2025", Rate: "92.25"}
+	mockApp := mocks.NewMockSubscriptionsService(ctrl)
+	mockApp.EXPECT().PutFXRate(gomock.Any(), rate).Return(rate, nil)
+
+	api := rest.NewAPI(slog.Default(), nil, mockApp)
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+	app.Put("/api/fx-rates/:base/:quote/:month", api.PutFXRate)
+
+	req := httptest.NewRequest(http.MethodPut, "/api/fx-rates/USD/RUB/03-2025", strings.NewReader(`{"rate":92.25}`))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	body, _ := io.ReadAll(resp.Body)
+	assert.JSONEq(t, `{"base":"USD","quote":"RUB","month":"03-2025","rate":92.25}`, string(body))
+
+	req = httptest.NewRequest(http.MethodPut, "/api/fx-rates/USD/RUB/03-2025", strings.NewReader(`{}`))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	resp, err = app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	var problem rest.Problem
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+	assert.Equal(t, map[string]string{"rate": "is required"}, problem.Errors)
+}
+
+func TestGetTotal_MissingFXRate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockApp := mocks.NewMockSubscriptionsService(ctrl)
+	mockApp.EXPECT().GetTotalSubscriptionsPrice(gomock.Any(), &application.TotalRequest{
+		From: "01-2025", To: "03-2025", Currency: "USD",
+	}).Return(nil, fmt.Errorf("%w: no RUB/USD rate for 02-2025", application.ErrMissingFXRate))
+
+	api := rest.NewAPI(slog.Default(), nil, mockApp)
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+	app.Get("/api/total", api.GetTotalSubscriptionsPrice)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/total?from=01-2025&to=03-2025&currency=USD", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusUnprocessableEntity, resp.StatusCode)
+
+	var problem rest.Problem
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+	assert.Equal(t, "missing exchange rate: no RUB/USD rate for 02-2025", problem.Detail)
+}
@@ -0,0 +1,90 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"github.com/azaliaz/subs-api/internal/application/mocks"
+	"github.com/azaliaz/subs-api/internal/facade/rest"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type healthFunc func(ctx context.Context) error
+
+func (f healthFunc) Health(ctx context.Context) error {
+	return f(ctx)
+}
+
+func TestLiveness(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	api := rest.NewAPI(slog.Default(), nil, mocks.NewMockSubscriptionsService(ctrl))
+	api.WithHealthChecker(healthFunc(func(context.Context) error {
+		return errors.New("database is down")
+	}))
+	app := fiber.New()
+	app.Get("/healthz", api.Liveness)
+
+	resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+}
+
+func TestReadiness_Ready(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	api := rest.NewAPI(slog.Default(), nil, mocks.NewMockSubscriptionsService(ctrl))
+	api.WithHealthChecker(healthFunc(func(context.Context) error { return nil }))
+	app := fiber.New()
+	app.Get("/readyz", api.Readiness)
+
+	resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+}
+
+func TestReadiness_NotReady(t *testing.T) {
+	tests := []struct {
+		name   string
+		config *rest.Config
+		body   string
+	}{
+		{
+			name: "default",
+			body: `{"status":"unavailable"}`,
+		},
+		{
+			name:   "additional errors",
+			config: &rest.Config{IsAdditionalErrorsEnabled: true},
+			body:   `{"error":"schema version 0 does not match expected 1","status":"unavailable"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			api := rest.NewAPI(slog.Default(), tt.config, mocks.NewMockSubscriptionsService(ctrl))
+			api.WithHealthChecker(healthFunc(func(context.Context) error {
+				return errors.New("schema version 0 does not match expected 1")
+			}))
+			app := fiber.New()
+			app.Get("/readyz", api.Readiness)
+
+			resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			assert.Equal(t, fiber.StatusServiceUnavailable, resp.StatusCode)
+			body, _ := io.ReadAll(resp.Body)
+			assert.JSONEq(t, tt.body, string(body))
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/azaliaz/subs-api/internal/application"
+	"github.com/azaliaz/subs-api/internal/application/mocks"
+	"github.com/azaliaz/subs-api/internal/facade/rest"
+	"github.com/azaliaz/subs-api/pkg/logging"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func decodeLogLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+
+	var lines []map[string]interface{}
+	for _, raw := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if raw == "" {
+			continue
+		}
+		var line map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(raw), &line))
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name      string
+		requestID string
+		keep      bool
+	}{
+		{name: "propagated", requestID: "req-123", keep: true},
+		{name: "generated", requestID: ""},
+		{name: "rejected", requestID: "bad id\nwith newline"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+			mockApp := mocks.NewMockSubscriptionsService(ctrl)
+			mockApp.EXPECT().GetInfo(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(ctx context.Context, _ *application.GetInfoRequest) (*application.GetInfoResponse, error) {
+					logging.FromContext(ctx, nil).Info("from handler")
+					return &application.GetInfoResponse{}, nil
+				})
+
+			api := rest.NewAPI(logger, nil, mockApp)
+			app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+			app.Use(api.RequestID)
+			app.Get("/api/info/:id", api.GetInfo)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/info/"+uuid.NewString(), nil)
+			if tt.requestID != "" {
+				req.Header.Set(rest.RequestIDHeader, tt.requestID)
+			}
+			resp, _ := app.Test(req)
+			assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+			requestID := resp.Header.Get(rest.RequestIDHeader)
+			if tt.keep {
+				assert.Equal(t, tt.requestID, requestID)
+			} else {
+				_, err := uuid.Parse(requestID)
+				assert.NoError(t, err)
+			}
+
+			lines := decodeLogLines(t, &buf)
+			require.Len(t, lines, 2)
+			assert.Equal(t, "from handler", lines[0]["msg"])
+			assert.Equal(t, requestID, lines[0]["request_id"])
+
+			access := lines[1]
+			assert.Equal(t, "request completed", access["msg"])
+			assert.Equal(t, requestID, access["request_id"])
+			assert.Equal(t, http.MethodGet, access["method"])
+			assert.Equal(t, "/api/info/:id", access["route"])
+			assert.Equal(t, float64(fiber.StatusOK), access["status"])
+			assert.Contains(t, access, "latency")
+			assert.Contains(t, access, "bytes")
+		})
+	}
+}
+
+func TestRequestID_ErrorBodySize(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	api := rest.NewAPI(logger, nil, mocks.NewMockSubscriptionsService(ctrl))
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+	app.Use(api.RequestID)
+	app.Get("/api/info/:id", api.GetInfo)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/info/not-a-uuid", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NotEmpty(t, body)
+
+	lines := decodeLogLines(t, &buf)
+	access := lines[len(lines)-1]
+	assert.Equal(t, "request completed", access["msg"])
+	assert.Equal(t, float64(fiber.StatusBadRequest), access["status"])
+	assert.Equal(t, float64(len(body)), access["bytes"])
+}
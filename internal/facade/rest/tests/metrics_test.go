@@ -0,0 +1,67 @@
+package tests
+
+import (
+	"github.com/azaliaz/subs-api/internal/application"
+	"github.com/azaliaz/subs-api/internal/application/mocks"
+	"github.com/azaliaz/subs-api/internal/facade/rest"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func requestCount(t *testing.T, method, route, status string) uint64 {
+	t.Helper()
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+
+	for _, family := range families {
+		if family.GetName() != "subs_api_http_request_duration_seconds" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["method"] == method && labels["route"] == route && labels["status"] == status {
+				return metric.GetHistogram().GetSampleCount()
+			}
+		}
+	}
+	return 0
+}
+
+func TestObserveRequests(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockApp := mocks.NewMockSubscriptionsService(ctrl)
+	mockApp.EXPECT().GetInfo(gomock.Any(), gomock.Any()).
+		Return(&application.GetInfoResponse{}, nil).Times(2)
+
+	api := rest.NewAPI(slog.Default(), nil, mockApp)
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+	app.Use(api.ObserveRequests)
+	app.Get("/api/info/:id", api.GetInfo)
+
+	before := requestCount(t, http.MethodGet, "/api/info/:id", "200")
+	beforeUnmatched := requestCount(t, http.MethodGet, "unmatched", "404")
+
+	for i := 0; i < 2; i++ {
+		resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/api/info/"+uuid.NewString(), nil))
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	}
+	resp, _ := app.Test(httptest.NewRequest(http.MethodGet, "/unknown", nil))
+	assert.Equal(t, fiber.StatusNotFound, resp.StatusCode)
+
+	assert.Equal(t, before+2, requestCount(t, http.MethodGet, "/api/info/:id", "200"))
+	assert.Equal(t, beforeUnmatched+1, requestCount(t, http.MethodGet, "unmatched", "404"))
+}
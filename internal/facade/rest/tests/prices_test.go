@@ -0,0 +1,132 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"github.com/azaliaz/subs-api/internal/application"
+	"github.com/azaliaz/subs-api/internal/application/mocks"
+	"github.com/azaliaz/subs-api/internal/facade/rest"
+	"github.com/azaliaz/subs-api/pkg/money"
+	"github.com/azaliaz/subs-api/pkg/optional"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGetPrices(t *testing.T) {
+	subID := uuid.New()
+	to := "03-2025"
+	timeline := func(mockApp *mocks.MockSubscriptionsService) {
+		mockApp.EXPECT().GetPrices(gomock.Any(), &application.PricesRequest{ID: subID}).Return(&application.PricesResponse{
+			ID:       subID,
+			Currency: "RUB",
+			Prices: []application.PriceChange{
+				{EffectiveFrom: "01-2025", EffectiveTo: &to, Price: money.Amount{Minor: 29990, Exponent: 2}, MonthlyPrice: money.Amount{Minor: 29990, Exponent: 2}, Currency: "RUB"},
+				{EffectiveFrom: "04-2025", Price: money.Amount{Minor: 34940, Exponent: 2}, MonthlyPrice: money.Amount{Minor: 11647, Exponent: 2}, Currency: "RUB"},
+			},
+		}, nil)
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		id     string
+		mock   func(mockApp *mocks.MockSubscriptionsService)
+		status int
+		body   string
+	}{
+		{
+			name:   "v1 timeline has integer prices",
+			path:   "/api/info/%s/prices",
+			id:     subID.String(),
+			mock:   timeline,
+			status: fiber.StatusOK,
+			body: `{"id":"` + subID.String() + `","currency":"RUB","prices":[` +
+				`{"effective_from":"01-2025","effective_to":"03-2025","price":300,"monthly_price":300,"currency":"RUB"},` +
+				`{"effective_from":"04-2025","effective_to":null,"price":349,"monthly_price":116,"currency":"RUB"}]}`,
+		},
+		{
+			name:   "v2 timeline has exact prices",
+			path:   "/api/v2/subscriptions/%s/prices",
+			id:     subID.String(),
+			mock:   timeline,
+			status: fiber.StatusOK,
+			body: `{"id":"` + subID.String() + `","currency":"RUB","prices":[` +
+				`{"effective_from":"01-2025","effective_to":"03-2025","price":"299.90","monthly_price":"299.90","currency":"RUB"},` +
+				`{"effective_from":"04-2025","effective_to":null,"price":"349.40","monthly_price":"116.47","currency":"RUB"}]}`,
+		},
+		{
+			name: "not found",
+			path: "/api/info/%s/prices",
+			id:   subID.String(),
+			mock: func(mockApp *mocks.MockSubscriptionsService) {
+				mockApp.EXPECT().GetPrices(gomock.Any(), gomock.Any()).
+					Return(nil, fmt.Errorf("%w: subscription with id %s", application.ErrNotFound, subID))
+			},
+			status: fiber.StatusNotFound,
+		},
+		{
+			name:   "invalid id",
+			path:   "/api/v2/subscriptions/%s/prices",
+			id:     "not-a-uuid",
+			mock:   func(*mocks.MockSubscriptionsService) {},
+			status: fiber.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockApp := mocks.NewMockSubscriptionsService(ctrl)
+			tt.mock(mockApp)
+
+			api := rest.NewAPI(slog.Default(), nil, mockApp)
+			app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+			app.Get("/api/info/:id/prices", api.GetPrices)
+			app.Get("/api/v2/subscriptions/:id/prices", api.GetPricesV2)
+
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, fmt.Sprintf(tt.path, tt.id), nil))
+			require.NoError(t, err)
+			assert.Equal(t, tt.status, resp.StatusCode)
+			if tt.body != "" {
+				body, _ := io.ReadAll(resp.Body)
+				assert.JSONEq(t, tt.body, string(body))
+			}
+		})
+	}
+}
+
+func TestPatch_PriceEffectiveFrom(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	subID := uuid.New()
+	mockApp := mocks.NewMockSubscriptionsService(ctrl)
+	mockApp.EXPECT().Update(gomock.Any(), subID, gomock.Any()).
+		DoAndReturn(func(_ context.Context, _ uuid.UUID, req *application.UpdateRequest) (*application.UpdateResponse, error) {
+			assert.Equal(t, optional.Of(money.Decimal("349.90")), req.Price)
+			assert.Equal(t, optional.Of("01-2026"), req.PriceEffectiveFrom)
+			return &application.UpdateResponse{GetInfoResponse: application.GetInfoResponse{ID: subID, Version: 2}}, nil
+		})
+
+	api := rest.NewAPI(slog.Default(), nil, mockApp)
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+	app.Patch("/api/v2/subscriptions/:id", api.PatchV2)
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/v2/subscriptions/"+subID.String(),
+		strings.NewReader(`{"price":349.90,"price_effective_from":"01-2026"}`))
+	req.Header.Set("Content-Type", rest.MIMEMergePatch)
+	resp, err := app.Test(req)
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+}
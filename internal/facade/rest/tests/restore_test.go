@@ -0,0 +1,154 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"github.com/azaliaz/subs-api/internal/application"
+	"github.com/azaliaz/subs-api/internal/application/mocks"
+	"github.com/azaliaz/subs-api/internal/facade/rest"
+	"github.com/azaliaz/subs-api/pkg/money"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRestore(t *testing.T) {
+	subID := uuid.New()
+	restored := &application.UpdateResponse{GetInfoResponse: application.GetInfoResponse{
+		ID: subID, ServiceName: "Netflix", Price: money.Amount{Minor: 29990, Exponent: 2}, Currency: "RUB", Version: 3,
+	}}
+
+	tests := []struct {
+		name   string
+		path   string
+		id     string
+		mock   func(mockApp *mocks.MockSubscriptionsService)
+		status int
+		price  string
+	}{
+		{
+			name: "v1",
+			path: "/api/subscriptions/",
+			id:   subID.String(),
+			mock: func(mockApp *mocks.MockSubscriptionsService) {
+				mockApp.EXPECT().Restore(gomock.Any(), &application.RestoreRequest{ID: subID}).Return(restored, nil)
+			},
+			status: fiber.StatusOK,
+			price:  `300`,
+		},
+		{
+			name: "v2",
+			path: "/api/v2/subscriptions/",
+			id:   subID.String(),
+			mock: func(mockApp *mocks.MockSubscriptionsService) {
+				mockApp.EXPECT().Restore(gomock.Any(), &application.RestoreRequest{ID: subID}).Return(restored, nil)
+			},
+			status: fiber.StatusOK,
+			price:  `"299.90"`,
+		},
+		{
+			name: "not deleted",
+			path: "/api/subscriptions/",
+			id:   subID.String(),
+			mock: func(mockApp *mocks.MockSubscriptionsService) {
+				mockApp.EXPECT().Restore(gomock.Any(), gomock.Any()).
+					Return(nil, fmt.Errorf("%w: subscription with id %s is not deleted", application.ErrConflict, subID))
+			},
+			status: fiber.StatusConflict,
+		},
+		{
+			name: "purged",
+			path: "/api/subscriptions/",
+			id:   subID.String(),
+			mock: func(mockApp *mocks.MockSubscriptionsService) {
+				mockApp.EXPECT().Restore(gomock.Any(), gomock.Any()).
+					Return(nil, fmt.Errorf("%w: subscription with id %s", application.ErrNotFound, subID))
+			},
+			status: fiber.StatusNotFound,
+		},
+		{
+			name:   "invalid id",
+			path:   "/api/subscriptions/",
+			id:     "not-a-uuid",
+			mock:   func(*mocks.MockSubscriptionsService) {},
+			status: fiber.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockApp := mocks.NewMockSubscriptionsService(ctrl)
+			tt.mock(mockApp)
+
+			api := rest.NewAPI(slog.Default(), nil, mockApp)
+			app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+			app.Post("/api/subscriptions/:id/restore", api.Restore)
+			app.Post("/api/v2/subscriptions/:id/restore", api.RestoreV2)
+
+			resp, err := app.Test(httptest.NewRequest(http.MethodPost, tt.path+tt.id+"/restore", nil))
+			require.NoError(t, err)
+			assert.Equal(t, tt.status, resp.StatusCode)
+			if tt.price != "" {
+				assert.Equal(t, `"3"`, resp.Header.Get(fiber.HeaderETag))
+				body, _ := io.ReadAll(resp.Body)
+				assert.Contains(t, string(body), `"price":`+tt.price)
+				assert.NotContains(t, string(body), "deleted_at")
+			}
+		})
+	}
+}
+
+func TestGetListV2_IncludeDeleted(t *testing.T) {
+	deletedAt := time.Date(2025, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("deleted subscriptions", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		mockApp := mocks.NewMockSubscriptionsService(ctrl)
+		mockApp.EXPECT().List(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(_ context.Context, req *application.ListRequest) (*application.ListResponse, error) {
+				assert.True(t, req.IncludeDeleted)
+				return &application.ListResponse{
+					Subscriptions: []application.GetInfoResponse{{ID: uuid.New(), DeletedAt: &deletedAt}},
+					Total:         1,
+				}, nil
+			})
+
+		api := rest.NewAPI(slog.Default(), nil, mockApp)
+		app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+		app.Get("/api/v2/subscriptions", api.GetListV2)
+
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/v2/subscriptions?include_deleted=true", nil))
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Contains(t, string(body), `"deleted_at":"2025-09-01T12:00:00Z"`)
+	})
+
+	t.Run("not a boolean", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+
+		api := rest.NewAPI(slog.Default(), nil, mocks.NewMockSubscriptionsService(ctrl))
+		app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+		app.Get("/api/v2/subscriptions", api.GetListV2)
+
+		resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/v2/subscriptions?include_deleted=maybe", nil))
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Contains(t, string(body), "include_deleted")
+	})
+}
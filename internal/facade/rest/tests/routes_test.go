@@ -2,11 +2,14 @@ package tests
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/azaliaz/subs-api/internal/application"
 	"github.com/azaliaz/subs-api/internal/application/mocks"
 	"github.com/azaliaz/subs-api/internal/facade/rest"
+	"github.com/azaliaz/subs-api/pkg/money"
+	"github.com/azaliaz/subs-api/pkg/optional"
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang/mock/gomock"
 	"github.com/google/uuid"
@@ -15,9 +18,32 @@ import (
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
+// validatingApp makes the mocked application reject invalid requests the way
+// the real service does.
+func validatingApp(mockApp *mocks.MockSubscriptionsService) {
+	mockApp.EXPECT().List(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(_ context.Context, req *application.ListRequest) (*application.ListResponse, error) {
+			return nil, req.Validate()
+		})
+	mockApp.EXPECT().Update(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(_ context.Context, _ uuid.UUID, req *application.UpdateRequest) (*application.UpdateResponse, error) {
+			return nil, req.Validate()
+		})
+	mockApp.EXPECT().Replace(gomock.Any(), gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(_ context.Context, _ uuid.UUID, req *application.ReplaceRequest) (*application.UpdateResponse, error) {
+			return nil, req.Validate()
+		})
+	mockApp.EXPECT().GetTotalSubscriptionsPrice(gomock.Any(), gomock.Any()).AnyTimes().
+		DoAndReturn(func(_ context.Context, req *application.TotalRequest) (*application.TotalResponse, error) {
+			return nil, req.Validate()
+		})
+}
+
 func TestCreate_Success(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -26,7 +52,7 @@ func TestCreate_Success(t *testing.T) {
 
 	userID := uuid.New()
 	serviceName := "Netflix"
-	price := 10
+	price := money.Decimal("10")
 	startDate := "09-2025"
 	endDate := "12-2025"
 
@@ -43,7 +69,7 @@ func TestCreate_Success(t *testing.T) {
 		}, nil)
 
 	api := rest.NewAPI(slog.Default(), nil, mockApp)
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
 	app.Add("POST", "/api/create", api.Create)
 
 	requestBody, _ := json.Marshal(map[string]interface{}{
@@ -67,7 +93,7 @@ func TestCreate_InvalidJSON(t *testing.T) {
 
 	mockApp := mocks.NewMockSubscriptionsService(ctrl)
 	api := rest.NewAPI(slog.Default(), nil, mockApp)
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
 	app.Add("POST", "/api/create", api.Create)
 
 	req := httptest.NewRequest(http.MethodPost, "/api/create", bytes.NewReader([]byte("{invalid_json}")))
@@ -82,8 +108,13 @@ func TestCreate_InvalidFields(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockApp := mocks.NewMockSubscriptionsService(ctrl)
+	mockApp.EXPECT().Create(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, req *application.CreateRequest) (*application.CreateResponse, error) {
+			return nil, req.Validate()
+		})
+
 	api := rest.NewAPI(slog.Default(), nil, mockApp)
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
 	app.Add("POST", "/api/create", api.Create)
 
 	userID := uuid.Nil
@@ -101,6 +132,15 @@ func TestCreate_InvalidFields(t *testing.T) {
 	req.Header.Set("Content-Type", "application/json")
 	resp, _ := app.Test(req)
 	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+	var problem rest.Problem
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+	assert.Equal(t, map[string]string{
+		"user_id":      "is required",
+		"service_name": "is required",
+		"price":        "must be greater than 0 and at most 1000000",
+		"start_date":   "must be a month in MM-YYYY format",
+	}, problem.Errors)
 }
 
 func TestCreate_AppError(t *testing.T) {
@@ -110,13 +150,13 @@ func TestCreate_AppError(t *testing.T) {
 	mockApp := mocks.NewMockSubscriptionsService(ctrl)
 	userID := uuid.New()
 	serviceName := "Netflix"
-	price := 10
+	price := money.Decimal("10")
 	startDate := "09-2025"
 
 	mockApp.EXPECT().Create(gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("db error"))
 
 	api := rest.NewAPI(slog.Default(), nil, mockApp)
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
 	app.Add("POST", "/api/create", api.Create)
 
 	requestBody, _ := json.Marshal(map[string]interface{}{
@@ -131,6 +171,69 @@ func TestCreate_AppError(t *testing.T) {
 	resp, _ := app.Test(req)
 	assert.Equal(t, fiber.StatusInternalServerError, resp.StatusCode)
 }
+func TestCreate_IdempotencyKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		replayed bool
+		err      error
+		status   int
+	}{
+		{name: "first request", status: fiber.StatusCreated},
+		{name: "replayed", replayed: true, status: fiber.StatusCreated},
+		{
+			name:   "different payload",
+			err:    fmt.Errorf("%w: key was used with a different request", application.ErrIdempotencyMismatch),
+			status: fiber.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			id := uuid.New()
+			mockApp := mocks.NewMockSubscriptionsService(ctrl)
+			mockApp.EXPECT().Create(gomock.Any(), gomock.Any()).
+				DoAndReturn(func(_ context.Context, req *application.CreateRequest) (*application.CreateResponse, error) {
+					assert.Equal(t, "retry-1", req.IdempotencyKey)
+					if tt.err != nil {
+						return nil, tt.err
+					}
+					return &application.CreateResponse{ID: id, Replayed: tt.replayed}, nil
+				})
+
+			api := rest.NewAPI(slog.Default(), nil, mockApp)
+			app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+			app.Add("POST", "/api/create", api.Create)
+
+			requestBody, _ := json.Marshal(map[string]interface{}{
+				"user_id":      uuid.New(),
+				"service_name": "Netflix",
+				"price":        10,
+				"start_date":   "09-2025",
+			})
+			req := httptest.NewRequest(http.MethodPost, "/api/create", bytes.NewReader(requestBody))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(rest.HeaderIdempotencyKey, "retry-1")
+
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			assert.Equal(t, tt.status, resp.StatusCode)
+			if tt.replayed {
+				assert.Equal(t, "true", resp.Header.Get(rest.HeaderIdempotentReplayed))
+			} else {
+				assert.Empty(t, resp.Header.Get(rest.HeaderIdempotentReplayed))
+			}
+			if tt.err == nil {
+				var body map[string]interface{}
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+				assert.Equal(t, map[string]interface{}{"id": id.String()}, body)
+			}
+		})
+	}
+}
+
 func TestGetInfo_Success(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -144,13 +247,13 @@ func TestGetInfo_Success(t *testing.T) {
 		ID:          subID,
 		UserID:      uuid.New(),
 		ServiceName: "Netflix",
-		Price:       10,
+		Price:       money.Amount{Minor: 1000, Exponent: 2},
 		StartDate:   "09-2025",
 		EndDate:     nil,
 	}, nil)
 
 	api := rest.NewAPI(slog.Default(), nil, mockApp)
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
 	app.Add("GET", "/api/info/:id", api.GetInfo)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/info/"+subID.String(), nil)
@@ -165,7 +268,7 @@ func TestGetInfo_MissingID(t *testing.T) {
 
 	mockApp := mocks.NewMockSubscriptionsService(ctrl)
 	api := rest.NewAPI(slog.Default(), nil, mockApp)
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
 	app.Get("/api/info/*", api.GetInfo)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/info/", nil)
@@ -180,7 +283,7 @@ func TestGetInfo_InvalidIDFormat(t *testing.T) {
 
 	mockApp := mocks.NewMockSubscriptionsService(ctrl)
 	api := rest.NewAPI(slog.Default(), nil, mockApp)
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
 	app.Add("GET", "/api/info/:id", api.GetInfo)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/info/invalid-uuid", nil)
@@ -198,10 +301,10 @@ func TestGetInfo_NotFound(t *testing.T) {
 
 	mockApp.EXPECT().GetInfo(gomock.Any(), &application.GetInfoRequest{
 		ID: subID,
-	}).Return(nil, nil)
+	}).Return(nil, fmt.Errorf("%w: subscription with id %s", application.ErrNotFound, subID))
 
 	api := rest.NewAPI(slog.Default(), nil, mockApp)
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
 	app.Add("GET", "/api/info/:id", api.GetInfo)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/info/"+subID.String(), nil)
@@ -222,7 +325,7 @@ func TestGetInfo_ServiceError(t *testing.T) {
 	}).Return(nil, fmt.Errorf("db error"))
 
 	api := rest.NewAPI(slog.Default(), nil, mockApp)
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
 	app.Add("GET", "/api/info/:id", api.GetInfo)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/info/"+subID.String(), nil)
@@ -244,7 +347,7 @@ func TestGetList_Success(t *testing.T) {
 	offset := 0
 
 	mockApp.EXPECT().List(gomock.Any(), &application.ListRequest{
-		UserID:      &userID,
+		UserIDs:     []uuid.UUID{userID},
 		ServiceName: &serviceName,
 		From:        &from,
 		To:          &to,
@@ -256,7 +359,7 @@ func TestGetList_Success(t *testing.T) {
 				ID:          uuid.New(),
 				UserID:      userID,
 				ServiceName: serviceName,
-				Price:       10,
+				Price:       money.Amount{Minor: 1000, Exponent: 2},
 				StartDate:   from,
 				EndDate:     &to,
 			},
@@ -264,7 +367,7 @@ func TestGetList_Success(t *testing.T) {
 	}, nil)
 
 	api := rest.NewAPI(slog.Default(), nil, mockApp)
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
 	app.Add("GET", "/api/list", api.GetList)
 
 	req := httptest.NewRequest(http.MethodGet,
@@ -277,13 +380,114 @@ func TestGetList_Success(t *testing.T) {
 	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
 }
 
+func TestGetList_PageToken(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	limit := 10
+	mockApp := mocks.NewMockSubscriptionsService(ctrl)
+	mockApp.EXPECT().List(gomock.Any(), &application.ListRequest{Limit: &limit, PageToken: "first"}).
+		Return(&application.ListResponse{Total: 25, HasMore: true, NextPageToken: "second"}, nil)
+
+	api := rest.NewAPI(slog.Default(), nil, mockApp)
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+	app.Add("GET", "/api/list", api.GetList)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/list?limit=10&page_token=first", nil))
+	require.NoError(t, err)
+
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Equal(t, `</api/list?limit=10&page_token=second>; rel="next"`, resp.Header.Get(fiber.HeaderLink))
+
+	var body map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, float64(25), body["total"])
+	assert.Equal(t, true, body["has_more"])
+	assert.Equal(t, "second", body["next_page_token"])
+}
+
+func TestGetList_SortAndFilters(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	first, second, third := uuid.New(), uuid.New(), uuid.New()
+	serviceName := "Netflix"
+	priceMin, priceMax := money.Decimal("100"), money.Decimal("500")
+	asOf := "10-2025"
+
+	mockApp := mocks.NewMockSubscriptionsService(ctrl)
+	mockApp.EXPECT().List(gomock.Any(), &application.ListRequest{
+		UserIDs:      []uuid.UUID{first, second, third},
+		ServiceName:  &serviceName,
+		ServiceMatch: application.ServiceMatchExact,
+		PriceMin:     &priceMin,
+		PriceMax:     &priceMax,
+		Status:       application.StatusActive,
+		AsOf:         &asOf,
+		Sort:         "price:desc",
+	}).Return(&application.ListResponse{}, nil)
+
+	api := rest.NewAPI(slog.Default(), nil, mockApp)
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+	app.Add("GET", "/api/list", api.GetList)
+
+	target := fmt.Sprintf("/api/list?user_id=%s,%s&user_id=%s&service_name=Netflix&service_match=exact"+
+		"&price_min=100&price_max=500&status=active&as_of=10-2025&sort=price:desc", first, second, third)
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, target, nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+}
+
+func TestGetList_InvalidFilters(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockApp := mocks.NewMockSubscriptionsService(ctrl)
+	validatingApp(mockApp)
+
+	api := rest.NewAPI(slog.Default(), nil, mockApp)
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+	app.Add("GET", "/api/list", api.GetList)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet,
+		"/api/list?user_id="+uuid.NewString()+",bad&price_min=cheap&sort=color", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+	var problem struct {
+		Errors map[string]string `json:"errors"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+	assert.Equal(t, "must be a UUID", problem.Errors["user_id"])
+	assert.Equal(t, "must be a decimal number such as 299.90", problem.Errors["price_min"])
+	assert.Contains(t, problem.Errors["sort"], "must be one of")
+}
+
+func TestGetList_LastPageHasNoLink(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockApp := mocks.NewMockSubscriptionsService(ctrl)
+	mockApp.EXPECT().List(gomock.Any(), gomock.Any()).Return(&application.ListResponse{Total: 1}, nil)
+
+	api := rest.NewAPI(slog.Default(), nil, mockApp)
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+	app.Add("GET", "/api/list", api.GetList)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/list?offset=20", nil))
+	require.NoError(t, err)
+
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get(fiber.HeaderLink))
+}
+
 func TestGetList_InvalidUserID(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockApp := mocks.NewMockSubscriptionsService(ctrl)
 	api := rest.NewAPI(slog.Default(), nil, mockApp)
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
 	app.Add("GET", "/api/list", api.GetList)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/list?user_id=invalid-uuid", nil)
@@ -297,8 +501,9 @@ func TestGetList_InvalidFromDate(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockApp := mocks.NewMockSubscriptionsService(ctrl)
+	validatingApp(mockApp)
 	api := rest.NewAPI(slog.Default(), nil, mockApp)
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
 	app.Add("GET", "/api/list", api.GetList)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/list?from=2025-09", nil)
@@ -312,8 +517,9 @@ func TestGetList_InvalidToDate(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockApp := mocks.NewMockSubscriptionsService(ctrl)
+	validatingApp(mockApp)
 	api := rest.NewAPI(slog.Default(), nil, mockApp)
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
 	app.Add("GET", "/api/list", api.GetList)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/list?to=2025-12", nil)
@@ -327,8 +533,9 @@ func TestGetList_InvalidLimit(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockApp := mocks.NewMockSubscriptionsService(ctrl)
+	validatingApp(mockApp)
 	api := rest.NewAPI(slog.Default(), nil, mockApp)
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
 	app.Add("GET", "/api/list", api.GetList)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/list?limit=-1", nil)
@@ -342,8 +549,9 @@ func TestGetList_InvalidOffset(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockApp := mocks.NewMockSubscriptionsService(ctrl)
+	validatingApp(mockApp)
 	api := rest.NewAPI(slog.Default(), nil, mockApp)
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
 	app.Add("GET", "/api/list", api.GetList)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/list?offset=-5", nil)
@@ -362,7 +570,7 @@ func TestGetList_ServiceError(t *testing.T) {
 	mockApp.EXPECT().List(gomock.Any(), gomock.Any()).Return(nil, fmt.Errorf("db error"))
 
 	api := rest.NewAPI(slog.Default(), nil, mockApp)
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
 	app.Add("GET", "/api/list", api.GetList)
 
 	req := httptest.NewRequest(http.MethodGet, "/api/list?user_id="+userID.String(), nil)
@@ -370,7 +578,7 @@ func TestGetList_ServiceError(t *testing.T) {
 
 	assert.Equal(t, fiber.StatusInternalServerError, resp.StatusCode)
 }
-func TestUpdate_Success(t *testing.T) {
+func TestPatch_Success(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
@@ -380,71 +588,78 @@ func TestUpdate_Success(t *testing.T) {
 	endDate := "12-2025"
 
 	mockApp.EXPECT().Update(gomock.Any(), subID, &application.UpdateRequest{
-		StartDate: &startDate,
-		EndDate:   &endDate,
+		StartDate: optional.Of(startDate),
+		EndDate:   optional.Of(endDate),
 	}).Return(&application.UpdateResponse{
-		Updated: true,
+		GetInfoResponse: application.GetInfoResponse{
+			ID:        subID,
+			StartDate: startDate,
+			EndDate:   &endDate,
+			UpdatedAt: time.Date(2025, 10, 1, 10, 0, 0, 0, time.UTC),
+		},
 	}, nil)
 
 	api := rest.NewAPI(slog.Default(), nil, mockApp)
-	app := fiber.New()
-	app.Add("PUT", "/api/update/:id", api.Update)
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+	app.Patch("/api/subscriptions/:id", api.Patch)
 
 	body, _ := json.Marshal(map[string]string{
 		"start_date": startDate,
 		"end_date":   endDate,
 	})
-	req := httptest.NewRequest(http.MethodPut, "/api/update/"+subID.String(), bytes.NewReader(body))
-	req.Header.Set("Content-Type", "application/json")
+	req := httptest.NewRequest(http.MethodPatch, "/api/subscriptions/"+subID.String(), bytes.NewReader(body))
+	req.Header.Set("Content-Type", rest.MIMEMergePatch)
 	resp, _ := app.Test(req)
 
 	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
 
-	var respBody application.UpdateResponse
+	var respBody map[string]interface{}
 	_ = json.NewDecoder(resp.Body).Decode(&respBody)
-	assert.True(t, respBody.Updated)
+	assert.Equal(t, subID.String(), respBody["id"])
+	assert.Equal(t, endDate, respBody["end_date"])
+	assert.Equal(t, "2025-10-01T10:00:00Z", respBody["updated_at"])
 }
 
-func TestUpdate_MissingID(t *testing.T) {
+func TestPatch_MissingID(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockApp := mocks.NewMockSubscriptionsService(ctrl)
-	api := rest.NewAPI(slog.Default(), nil, mockApp)
-	app := fiber.New()
-	app.Add("PUT", "/api/update/*", api.Update)
+	api := rest.NewAPI(slog.Default(), &rest.Config{IsAdditionalErrorsEnabled: true}, mockApp)
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+	app.Patch("/api/subscriptions/*", api.Patch)
 
 	body, _ := json.Marshal(map[string]string{
 		"start_date": "09-2025",
 		"end_date":   "12-2025",
 	})
-	req := httptest.NewRequest(http.MethodPut, "/api/update/", bytes.NewReader(body))
-	req.Header.Set("Content-Type", "application/json")
+	req := httptest.NewRequest(http.MethodPatch, "/api/subscriptions/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", rest.MIMEMergePatch)
 	resp, _ := app.Test(req)
 
 	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
 
-	var respBody map[string]string
-	_ = json.NewDecoder(resp.Body).Decode(&respBody)
-	assert.Equal(t, "id parameter is required", respBody["error"])
+	var problem rest.Problem
+	_ = json.NewDecoder(resp.Body).Decode(&problem)
+	assert.Equal(t, "validation failed: id parameter is required", problem.Detail)
 }
 
-func TestUpdate_InvalidIDFormat(t *testing.T) {
+func TestPatch_InvalidIDFormat(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockApp := mocks.NewMockSubscriptionsService(ctrl)
 	api := rest.NewAPI(slog.Default(), nil, mockApp)
-	app := fiber.New()
-	app.Add("PUT", "/api/update/:id", api.Update)
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+	app.Patch("/api/subscriptions/:id", api.Patch)
 
-	req := httptest.NewRequest(http.MethodPut, "/api/update/invalid-uuid", nil)
+	req := httptest.NewRequest(http.MethodPatch, "/api/subscriptions/invalid-uuid", nil)
 	resp, _ := app.Test(req)
 
 	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
 }
 
-func TestUpdate_InvalidBody(t *testing.T) {
+func TestPatch_InvalidBody(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
@@ -452,61 +667,63 @@ func TestUpdate_InvalidBody(t *testing.T) {
 	subID := uuid.New()
 
 	api := rest.NewAPI(slog.Default(), nil, mockApp)
-	app := fiber.New()
-	app.Add("PUT", "/api/update/:id", api.Update)
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+	app.Patch("/api/subscriptions/:id", api.Patch)
 
-	req := httptest.NewRequest(http.MethodPut, "/api/update/"+subID.String(), bytes.NewReader([]byte("{invalid_json}")))
-	req.Header.Set("Content-Type", "application/json")
+	req := httptest.NewRequest(http.MethodPatch, "/api/subscriptions/"+subID.String(), bytes.NewReader([]byte("{invalid_json}")))
+	req.Header.Set("Content-Type", rest.MIMEMergePatch)
 	resp, _ := app.Test(req)
 
 	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
 }
 
-func TestUpdate_InvalidStartDate(t *testing.T) {
+func TestPatch_InvalidStartDate(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockApp := mocks.NewMockSubscriptionsService(ctrl)
+	validatingApp(mockApp)
 	subID := uuid.New()
 	startDate := "2025-09"
 
 	api := rest.NewAPI(slog.Default(), nil, mockApp)
-	app := fiber.New()
-	app.Add("PUT", "/api/update/:id", api.Update)
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+	app.Patch("/api/subscriptions/:id", api.Patch)
 
 	body, _ := json.Marshal(map[string]string{
 		"start_date": startDate,
 	})
-	req := httptest.NewRequest(http.MethodPut, "/api/update/"+subID.String(), bytes.NewReader(body))
-	req.Header.Set("Content-Type", "application/json")
+	req := httptest.NewRequest(http.MethodPatch, "/api/subscriptions/"+subID.String(), bytes.NewReader(body))
+	req.Header.Set("Content-Type", rest.MIMEMergePatch)
 	resp, _ := app.Test(req)
 
 	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
 }
 
-func TestUpdate_InvalidEndDate(t *testing.T) {
+func TestPatch_InvalidEndDate(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
 	mockApp := mocks.NewMockSubscriptionsService(ctrl)
+	validatingApp(mockApp)
 	subID := uuid.New()
 	endDate := "2025-13"
 
 	api := rest.NewAPI(slog.Default(), nil, mockApp)
-	app := fiber.New()
-	app.Add("PUT", "/api/update/:id", api.Update)
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+	app.Patch("/api/subscriptions/:id", api.Patch)
 
 	body, _ := json.Marshal(map[string]string{
 		"end_date": endDate,
 	})
-	req := httptest.NewRequest(http.MethodPut, "/api/update/"+subID.String(), bytes.NewReader(body))
-	req.Header.Set("Content-Type", "application/json")
+	req := httptest.NewRequest(http.MethodPatch, "/api/subscriptions/"+subID.String(), bytes.NewReader(body))
+	req.Header.Set("Content-Type", rest.MIMEMergePatch)
 	resp, _ := app.Test(req)
 
 	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
 }
 
-func TestUpdate_ServiceError(t *testing.T) {
+func TestPatch_ServiceError(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
 
@@ -515,22 +732,145 @@ func TestUpdate_ServiceError(t *testing.T) {
 	startDate := "09-2025"
 
 	mockApp.EXPECT().Update(gomock.Any(), subID, &application.UpdateRequest{
-		StartDate: &startDate,
+		StartDate: optional.Of(startDate),
 	}).Return(nil, fmt.Errorf("db error"))
 
 	api := rest.NewAPI(slog.Default(), nil, mockApp)
-	app := fiber.New()
-	app.Add("PUT", "/api/update/:id", api.Update)
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+	app.Patch("/api/subscriptions/:id", api.Patch)
 
 	body, _ := json.Marshal(map[string]string{
 		"start_date": startDate,
 	})
+	req := httptest.NewRequest(http.MethodPatch, "/api/subscriptions/"+subID.String(), bytes.NewReader(body))
+	req.Header.Set("Content-Type", rest.MIMEMergePatch)
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, fiber.StatusInternalServerError, resp.StatusCode)
+}
+
+func TestPatch_ClearEndDate(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockApp := mocks.NewMockSubscriptionsService(ctrl)
+	subID := uuid.New()
+
+	mockApp.EXPECT().Update(gomock.Any(), subID, &application.UpdateRequest{
+		EndDate: optional.Null[string](),
+	}).Return(&application.UpdateResponse{}, nil)
+
+	api := rest.NewAPI(slog.Default(), &rest.Config{StrictJSON: true}, mockApp)
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+	app.Patch("/api/subscriptions/:id", api.Patch)
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/subscriptions/"+subID.String(), strings.NewReader(`{"end_date":null}`))
+	req.Header.Set("Content-Type", rest.MIMEMergePatch)
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+}
+
+func TestPatch_NullRequiredField(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockApp := mocks.NewMockSubscriptionsService(ctrl)
+	validatingApp(mockApp)
+
+	api := rest.NewAPI(slog.Default(), &rest.Config{StrictJSON: true}, mockApp)
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+	app.Patch("/api/subscriptions/:id", api.Patch)
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/subscriptions/"+uuid.NewString(), strings.NewReader(`{"price":null}`))
+	req.Header.Set("Content-Type", rest.MIMEMergePatch)
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+	var problem rest.Problem
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+	assert.Equal(t, map[string]string{"price": "must not be null"}, problem.Errors)
+}
+
+func TestPatch_UnsupportedMediaType(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockApp := mocks.NewMockSubscriptionsService(ctrl)
+	api := rest.NewAPI(slog.Default(), nil, mockApp)
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+	app.Patch("/api/subscriptions/:id", api.Patch)
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/subscriptions/"+uuid.NewString(), strings.NewReader(`price=10`))
+	req.Header.Set("Content-Type", fiber.MIMEApplicationForm)
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, fiber.StatusUnsupportedMediaType, resp.StatusCode)
+	assert.Equal(t, rest.MIMEMergePatch, resp.Header.Get(rest.HeaderAcceptPatch))
+}
+
+func TestReplace_Success(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockApp := mocks.NewMockSubscriptionsService(ctrl)
+	subID := uuid.New()
+	userID := uuid.New()
+
+	mockApp.EXPECT().Replace(gomock.Any(), subID, &application.ReplaceRequest{
+		CreateRequest: application.CreateRequest{
+			UserID:      userID,
+			ServiceName: "Netflix",
+			Price:       "400",
+			StartDate:   "09-2025",
+		},
+	}).Return(&application.UpdateResponse{}, nil)
+
+	api := rest.NewAPI(slog.Default(), nil, mockApp)
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+	app.Put("/api/update/:id", api.Replace)
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"user_id":      userID,
+		"service_name": "Netflix",
+		"price":        400,
+		"start_date":   "09-2025",
+	})
 	req := httptest.NewRequest(http.MethodPut, "/api/update/"+subID.String(), bytes.NewReader(body))
 	req.Header.Set("Content-Type", "application/json")
 	resp, _ := app.Test(req)
 
-	assert.Equal(t, fiber.StatusInternalServerError, resp.StatusCode)
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
 }
+
+func TestReplace_IncompleteBody(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockApp := mocks.NewMockSubscriptionsService(ctrl)
+	validatingApp(mockApp)
+
+	api := rest.NewAPI(slog.Default(), nil, mockApp)
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+	app.Put("/api/subscriptions/:id", api.Replace)
+
+	req := httptest.NewRequest(http.MethodPut, "/api/subscriptions/"+uuid.NewString(), strings.NewReader(`{"end_date":"12-2025"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp, _ := app.Test(req)
+
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+	var problem rest.Problem
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+	assert.Equal(t, map[string]string{
+		"user_id":      "is required",
+		"service_name": "is required",
+		"price":        "is required",
+		"start_date":   "is required",
+	}, problem.Errors)
+}
+
 func TestDelete_Success(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -543,7 +883,7 @@ func TestDelete_Success(t *testing.T) {
 	}).Return(&application.DeleteResponse{Deleted: true}, nil)
 
 	api := rest.NewAPI(slog.Default(), nil, mockApp)
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
 	app.Add("DELETE", "/api/delete/:id", api.Delete)
 
 	req := httptest.NewRequest(http.MethodDelete, "/api/delete/"+subID.String(), nil)
@@ -558,7 +898,7 @@ func TestDelete_MissingID(t *testing.T) {
 
 	mockApp := mocks.NewMockSubscriptionsService(ctrl)
 	api := rest.NewAPI(slog.Default(), nil, mockApp)
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
 	app.Add("DELETE", "/api/delete/:id?", api.Delete)
 
 	req := httptest.NewRequest(http.MethodDelete, "/api/delete", nil)
@@ -572,7 +912,7 @@ func TestDelete_InvalidIDFormat(t *testing.T) {
 
 	mockApp := mocks.NewMockSubscriptionsService(ctrl)
 	api := rest.NewAPI(slog.Default(), nil, mockApp)
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
 	app.Add("DELETE", "/api/delete/:id", api.Delete)
 
 	req := httptest.NewRequest(http.MethodDelete, "/api/delete/invalid-uuid", nil)
@@ -590,10 +930,10 @@ func TestDelete_NotFound(t *testing.T) {
 
 	mockApp.EXPECT().Delete(gomock.Any(), &application.DeleteRequest{
 		ID: subID,
-	}).Return(nil, nil)
+	}).Return(nil, fmt.Errorf("%w: subscription with id %s", application.ErrNotFound, subID))
 
 	api := rest.NewAPI(slog.Default(), nil, mockApp)
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
 	app.Add("DELETE", "/api/delete/:id", api.Delete)
 
 	req := httptest.NewRequest(http.MethodDelete, "/api/delete/"+subID.String(), nil)
@@ -614,7 +954,7 @@ func TestDelete_ServiceError(t *testing.T) {
 	}).Return(nil, fmt.Errorf("db error"))
 
 	api := rest.NewAPI(slog.Default(), nil, mockApp)
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
 	app.Add("DELETE", "/api/delete/:id", api.Delete)
 
 	req := httptest.NewRequest(http.MethodDelete, "/api/delete/"+subID.String(), nil)
@@ -635,10 +975,10 @@ func TestGetTotalSubscriptionsPrice_Success(t *testing.T) {
 	}
 	mockApp.EXPECT().
 		GetTotalSubscriptionsPrice(gomock.Any(), &req).
-		Return(&application.TotalResponse{Total: 100}, nil)
+		Return(&application.TotalResponse{Total: money.Amount{Minor: 10000, Exponent: 2}}, nil)
 
 	api := rest.NewAPI(slog.Default(), nil, mockApp)
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
 	app.Add("GET", "/api/total", api.GetTotalSubscriptionsPrice)
 
 	url := "/api/total?from=09-2025&to=12-2025"
@@ -648,6 +988,69 @@ func TestGetTotalSubscriptionsPrice_Success(t *testing.T) {
 	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
 }
 
+func TestGetTotalSubscriptionsPrice_GroupBy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockApp := mocks.NewMockSubscriptionsService(ctrl)
+
+	month := "09-2025"
+	req := application.TotalRequest{
+		From:    "09-2025",
+		To:      "12-2025",
+		GroupBy: []string{application.GroupByMonth, application.GroupByServiceName, application.GroupByUserID},
+	}
+	mockApp.EXPECT().
+		GetTotalSubscriptionsPrice(gomock.Any(), &req).
+		Return(&application.TotalResponse{
+			Total:   money.Amount{Minor: 10000, Exponent: 2},
+			Buckets: []application.TotalBucket{{Month: &month, Total: money.Amount{Minor: 10000, Exponent: 2}, Count: 1}},
+		}, nil)
+
+	api := rest.NewAPI(slog.Default(), nil, mockApp)
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+	app.Add("GET", "/api/total", api.GetTotalSubscriptionsPrice)
+
+	url := "/api/total?from=09-2025&to=12-2025&group_by=month,service_name&group_by=user_id"
+	reqHTTP := httptest.NewRequest(http.MethodGet, url, nil)
+	resp, _ := app.Test(reqHTTP)
+
+	assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+
+	var body struct {
+		Total   int `json:"total"`
+		Buckets []struct {
+			Month *string `json:"month"`
+		} `json:"buckets"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, 100, body.Total)
+	require.Len(t, body.Buckets, 1)
+	assert.Equal(t, month, *body.Buckets[0].Month)
+}
+
+func TestGetTotalSubscriptionsPrice_InvalidGroupBy(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockApp := mocks.NewMockSubscriptionsService(ctrl)
+	validatingApp(mockApp)
+
+	api := rest.NewAPI(slog.Default(), nil, mockApp)
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+	app.Add("GET", "/api/total", api.GetTotalSubscriptionsPrice)
+
+	for _, url := range []string{
+		"/api/total?from=09-2025&to=12-2025&group_by=year",
+		"/api/total?from=09-2025&to=12-2025&group_by=month,month",
+	} {
+		reqHTTP := httptest.NewRequest(http.MethodGet, url, nil)
+		resp, _ := app.Test(reqHTTP)
+
+		assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode, url)
+	}
+}
+
 func TestGetTotalSubscriptionsPrice_InvalidUserID(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -655,7 +1058,7 @@ func TestGetTotalSubscriptionsPrice_InvalidUserID(t *testing.T) {
 	mockApp := mocks.NewMockSubscriptionsService(ctrl)
 
 	api := rest.NewAPI(slog.Default(), nil, mockApp)
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
 	app.Add("GET", "/api/total", api.GetTotalSubscriptionsPrice)
 
 	url := "/api/total?user_id=not-a-uuid&from=09-2025&to=12-2025"
@@ -670,9 +1073,10 @@ func TestGetTotalSubscriptionsPrice_MissingFrom(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockApp := mocks.NewMockSubscriptionsService(ctrl)
+	validatingApp(mockApp)
 
 	api := rest.NewAPI(slog.Default(), nil, mockApp)
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
 	app.Add("GET", "/api/total", api.GetTotalSubscriptionsPrice)
 
 	url := "/api/total?to=12-2025"
@@ -687,9 +1091,10 @@ func TestGetTotalSubscriptionsPrice_InvalidFromFormat(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockApp := mocks.NewMockSubscriptionsService(ctrl)
+	validatingApp(mockApp)
 
 	api := rest.NewAPI(slog.Default(), nil, mockApp)
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
 	app.Add("GET", "/api/total", api.GetTotalSubscriptionsPrice)
 
 	url := "/api/total?from=2025-09&to=12-2025"
@@ -704,9 +1109,10 @@ func TestGetTotalSubscriptionsPrice_MissingTo(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockApp := mocks.NewMockSubscriptionsService(ctrl)
+	validatingApp(mockApp)
 
 	api := rest.NewAPI(slog.Default(), nil, mockApp)
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
 	app.Add("GET", "/api/total", api.GetTotalSubscriptionsPrice)
 
 	url := "/api/total?from=09-2025"
@@ -721,9 +1127,10 @@ func TestGetTotalSubscriptionsPrice_InvalidToFormat(t *testing.T) {
 	defer ctrl.Finish()
 
 	mockApp := mocks.NewMockSubscriptionsService(ctrl)
+	validatingApp(mockApp)
 
 	api := rest.NewAPI(slog.Default(), nil, mockApp)
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
 	app.Add("GET", "/api/total", api.GetTotalSubscriptionsPrice)
 
 	url := "/api/total?from=09-2025&to=2025-12"
@@ -748,7 +1155,7 @@ func TestGetTotalSubscriptionsPrice_ServiceError(t *testing.T) {
 		Return(nil, fmt.Errorf("db error"))
 
 	api := rest.NewAPI(slog.Default(), nil, mockApp)
-	app := fiber.New()
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
 	app.Add("GET", "/api/total", api.GetTotalSubscriptionsPrice)
 
 	url := "/api/total?from=09-2025&to=12-2025"
@@ -757,3 +1164,25 @@ func TestGetTotalSubscriptionsPrice_ServiceError(t *testing.T) {
 
 	assert.Equal(t, fiber.StatusInternalServerError, resp.StatusCode)
 }
+
+func TestGetList_ReportsAllInvalidFields(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockApp := mocks.NewMockSubscriptionsService(ctrl)
+	api := rest.NewAPI(slog.Default(), nil, mockApp)
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+	app.Get("/api/list", api.GetList)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/list?user_id=invalid-uuid&limit=ten&from=13-2025", nil)
+	resp, _ := app.Test(req)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+
+	var problem rest.Problem
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+	assert.Equal(t, map[string]string{
+		"user_id": "must be a UUID",
+		"limit":   "must be an integer",
+		"from":    "must be a month in MM-YYYY format",
+	}, problem.Errors)
+}
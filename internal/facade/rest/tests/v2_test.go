@@ -0,0 +1,204 @@
+package tests
+
+import (
+	"github.com/azaliaz/subs-api/internal/application"
+	"github.com/azaliaz/subs-api/internal/application/mocks"
+	"github.com/azaliaz/subs-api/internal/facade/rest"
+	"github.com/azaliaz/subs-api/pkg/money"
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetListV2(t *testing.T) {
+	subID := uuid.New()
+
+	tests := []struct {
+		name string
+		resp *application.ListResponse
+		body string
+		link string
+	}{
+		{
+			name: "empty",
+			resp: &application.ListResponse{},
+			body: `{"items":[],"page":{"total":0,"has_more":false,"next_page_token":null}}`,
+		},
+		{
+			name: "more pages",
+			resp: &application.ListResponse{
+				Subscriptions: []application.GetInfoResponse{{
+					ID:                  subID,
+					Price:               money.Amount{Minor: 29990, Exponent: 2},
+					Currency:            "RUB",
+					StartDate:           "09-2025",
+					BillingPeriod:       application.BillingMonth,
+					BillingPeriodMonths: 1,
+					MonthlyPrice:        money.Amount{Minor: 29990, Exponent: 2},
+				}},
+				Total:         2,
+				HasMore:       true,
+				NextPageToken: "next",
+			},
+			body: `{"items":[{"id":"` + subID.String() + `","user_id":"00000000-0000-0000-0000-000000000000",` +
+				`"service_name":"","price":"299.90","currency":"RUB","start_date":"09-2025","end_date":null,` +
+				`"billing_period":"month","billing_period_months":1,"billing_anchor":null,"monthly_price":"299.90",` +
+				`"created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z","version":0}],` +
+				`"page":{"total":2,"has_more":true,"next_page_token":"next"}}`,
+			link: `</api/v2/subscriptions?limit=1&page_token=next>; rel="next"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockApp := mocks.NewMockSubscriptionsService(ctrl)
+			mockApp.EXPECT().List(gomock.Any(), gomock.Any()).Return(tt.resp, nil)
+
+			api := rest.NewAPI(slog.Default(), nil, mockApp)
+			app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+			app.Get("/api/v2/subscriptions", api.GetListV2)
+
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/v2/subscriptions?limit=1", nil))
+			require.NoError(t, err)
+
+			assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+			body, _ := io.ReadAll(resp.Body)
+			assert.JSONEq(t, tt.body, string(body))
+			assert.Equal(t, tt.link, resp.Header.Get(fiber.HeaderLink))
+		})
+	}
+}
+
+func TestGetListV2_InvalidQuery(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockApp := mocks.NewMockSubscriptionsService(ctrl)
+	validatingApp(mockApp)
+
+	api := rest.NewAPI(slog.Default(), nil, mockApp)
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+	app.Get("/api/v2/subscriptions", api.GetListV2)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/v2/subscriptions?limit=0", nil))
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+}
+
+// The v1 list keeps its original shape for existing clients.
+func TestGetList_V1Shape(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockApp := mocks.NewMockSubscriptionsService(ctrl)
+	mockApp.EXPECT().List(gomock.Any(), gomock.Any()).Return(&application.ListResponse{}, nil)
+
+	api := rest.NewAPI(slog.Default(), nil, mockApp)
+	app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+	app.Get("/api/list", api.GetList)
+
+	resp, err := app.Test(httptest.NewRequest(http.MethodGet, "/api/list", nil))
+	require.NoError(t, err)
+
+	body, _ := io.ReadAll(resp.Body)
+	assert.JSONEq(t, `{"Subscriptions":null,"total":0,"has_more":false}`, string(body))
+}
+
+func TestPriceVersions(t *testing.T) {
+	subID := uuid.New()
+	sub := application.GetInfoResponse{
+		ID:                  subID,
+		ServiceName:         "Yandex Plus",
+		Price:               money.Amount{Minor: 299990, Exponent: 2},
+		Currency:            "RUB",
+		StartDate:           "09-2025",
+		BillingPeriod:       application.BillingYear,
+		BillingPeriodMonths: 12,
+		MonthlyPrice:        money.Amount{Minor: 24999, Exponent: 2},
+		Version:             3,
+	}
+	total := &application.TotalResponse{
+		Currency:        "RUB",
+		Total:           money.Amount{Minor: 299990, Exponent: 2},
+		NormalizedTotal: money.Amount{Minor: 99997, Exponent: 2},
+	}
+
+	tests := []struct {
+		name  string
+		path  string
+		route func(api *rest.Service) fiber.Handler
+		price string
+		total string
+	}{
+		{
+			name:  "v1 subscription keeps integer prices",
+			path:  "/api/info/",
+			route: func(api *rest.Service) fiber.Handler { return api.GetInfo },
+			price: `"price":3000,"monthly_price":250`,
+		},
+		{
+			name:  "v2 subscription has exact prices",
+			path:  "/api/v2/subscriptions/",
+			route: func(api *rest.Service) fiber.Handler { return api.GetInfoV2 },
+			price: `"price":"2999.90","monthly_price":"249.99"`,
+		},
+		{
+			name:  "v1 total keeps integer totals",
+			path:  "/api/total",
+			route: func(api *rest.Service) fiber.Handler { return api.GetTotalSubscriptionsPrice },
+			total: `{"currency":"RUB","total":3000,"normalized_total":1000}`,
+		},
+		{
+			name:  "v2 total has exact sums",
+			path:  "/api/v2/total",
+			route: func(api *rest.Service) fiber.Handler { return api.GetTotalV2 },
+			total: `{"currency":"RUB","total":"2999.90","normalized_total":"999.97"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockApp := mocks.NewMockSubscriptionsService(ctrl)
+			api := rest.NewAPI(slog.Default(), nil, mockApp)
+			app := fiber.New(fiber.Config{ErrorHandler: api.ErrorHandler})
+
+			target := tt.path + "?from=09-2025&to=09-2025"
+			if tt.total == "" {
+				mockApp.EXPECT().GetInfo(gomock.Any(), &application.GetInfoRequest{ID: subID}).Return(&sub, nil)
+				app.Get(tt.path+":id", tt.route(api))
+				target = tt.path + subID.String()
+			} else {
+				mockApp.EXPECT().GetTotalSubscriptionsPrice(gomock.Any(), gomock.Any()).Return(total, nil)
+				app.Get(tt.path, tt.route(api))
+			}
+
+			resp, err := app.Test(httptest.NewRequest(http.MethodGet, target, nil))
+			require.NoError(t, err)
+			assert.Equal(t, fiber.StatusOK, resp.StatusCode)
+			body, _ := io.ReadAll(resp.Body)
+
+			if tt.total != "" {
+				assert.JSONEq(t, tt.total, string(body))
+				return
+			}
+			assert.Equal(t, `"3"`, resp.Header.Get(fiber.HeaderETag))
+			assert.JSONEq(t, `{"id":"`+subID.String()+`","user_id":"00000000-0000-0000-0000-000000000000",`+
+				`"service_name":"Yandex Plus",`+tt.price+`,"currency":"RUB","start_date":"09-2025","end_date":null,`+
+				`"billing_period":"year","billing_period_months":12,"billing_anchor":null,`+
+				`"created_at":"0001-01-01T00:00:00Z","updated_at":"0001-01-01T00:00:00Z","version":3}`, string(body))
+		})
+	}
+}
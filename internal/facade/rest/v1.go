@@ -0,0 +1,200 @@
+package rest
+
+import (
+	"github.com/azaliaz/subs-api/internal/application"
+	"github.com/azaliaz/subs-api/pkg/money"
+	"github.com/azaliaz/subs-api/pkg/optional"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"strconv"
+)
+
+// The v1 routes keep the JSON they had when prices were whole rubles: every
+// amount, including those spread over a billing period, is an integer
+// rounded half away from zero to major units. The fields declared here
+// shadow the exact amounts of the embedded v2 responses.
+// Requests take the price as an integer too, so that a v1 client reads back
+// what it wrote.
+
+// createRequestV1 is the body of the v1 create and replace routes.
+type createRequestV1 struct {
+	UserID      uuid.UUID `json:"user_id"`
+	ServiceName string    `json:"service_name"`
+	// Price is in whole major units of Currency.
+	Price               *int64  `json:"price"`
+	Currency            string  `json:"currency"`
+	StartDate           string  `json:"start_date"`
+	EndDate             *string `json:"end_date"`
+	BillingPeriod       string  `json:"billing_period"`
+	BillingPeriodMonths *int    `json:"billing_period_months"`
+	BillingAnchor       *string `json:"billing_anchor"`
+}
+
+func (r *createRequestV1) request() application.CreateRequest {
+	req := application.CreateRequest{
+		UserID:              r.UserID,
+		ServiceName:         r.ServiceName,
+		Currency:            r.Currency,
+		StartDate:           r.StartDate,
+		EndDate:             r.EndDate,
+		BillingPeriod:       r.BillingPeriod,
+		BillingPeriodMonths: r.BillingPeriodMonths,
+		BillingAnchor:       r.BillingAnchor,
+	}
+	if r.Price != nil {
+		req.Price = majorUnits(*r.Price)
+	}
+	return req
+}
+
+// updateRequestV1 is the body of the v1 PATCH route.
+type updateRequestV1 struct {
+	ServiceName         optional.Value[string] `json:"service_name"`
+	Price               optional.Value[int64]  `json:"price"`
+	PriceEffectiveFrom  optional.Value[string] `json:"price_effective_from"`
+	Currency            optional.Value[string] `json:"currency"`
+	StartDate           optional.Value[string] `json:"start_date"`
+	EndDate             optional.Value[string] `json:"end_date"`
+	BillingPeriod       optional.Value[string] `json:"billing_period"`
+	BillingPeriodMonths optional.Value[int]    `json:"billing_period_months"`
+	BillingAnchor       optional.Value[string] `json:"billing_anchor"`
+}
+
+func (r *updateRequestV1) request() application.UpdateRequest {
+	req := application.UpdateRequest{
+		ServiceName:         r.ServiceName,
+		PriceEffectiveFrom:  r.PriceEffectiveFrom,
+		Currency:            r.Currency,
+		StartDate:           r.StartDate,
+		EndDate:             r.EndDate,
+		BillingPeriod:       r.BillingPeriod,
+		BillingPeriodMonths: r.BillingPeriodMonths,
+		BillingAnchor:       r.BillingAnchor,
+	}
+	switch {
+	case r.Price.Null:
+		req.Price = optional.Null[money.Decimal]()
+	case r.Price.Set:
+		req.Price = optional.Of(majorUnits(r.Price.Value))
+	}
+	return req
+}
+
+func majorUnits(price int64) money.Decimal {
+	return money.Decimal(strconv.FormatInt(price, 10))
+}
+
+func (api *Service) decodeCreateV1(c *fiber.Ctx, req *application.CreateRequest) error {
+	var body createRequestV1
+	if err := api.decodeBody(c, &body); err != nil {
+		return err
+	}
+	*req = body.request()
+	return nil
+}
+
+func (api *Service) decodeUpdateV1(c *fiber.Ctx, req *application.UpdateRequest) error {
+	var body updateRequestV1
+	if err := api.decodeBody(c, &body); err != nil {
+		return err
+	}
+	*req = body.request()
+	return nil
+}
+
+type subscriptionV1 struct {
+	application.GetInfoResponse
+	Price        int64 `json:"price"`
+	MonthlyPrice int64 `json:"monthly_price"`
+}
+
+func newSubscriptionV1(sub *application.GetInfoResponse) subscriptionV1 {
+	return subscriptionV1{
+		GetInfoResponse: *sub,
+		Price:           sub.Price.Major(),
+		MonthlyPrice:    sub.MonthlyPrice.Major(),
+	}
+}
+
+type updateResponseV1 struct {
+	subscriptionV1
+	Overlaps []uuid.UUID `json:"overlaps,omitempty"`
+}
+
+func newUpdateResponseV1(resp *application.UpdateResponse) updateResponseV1 {
+	return updateResponseV1{
+		subscriptionV1: newSubscriptionV1(&resp.GetInfoResponse),
+		Overlaps:       resp.Overlaps,
+	}
+}
+
+type listResponseV1 struct {
+	Subscriptions []subscriptionV1
+	Total         int    `json:"total"`
+	HasMore       bool   `json:"has_more"`
+	NextPageToken string `json:"next_page_token,omitempty"`
+}
+
+func newListResponseV1(resp *application.ListResponse) listResponseV1 {
+	body := listResponseV1{
+		Total:         resp.Total,
+		HasMore:       resp.HasMore,
+		NextPageToken: resp.NextPageToken,
+	}
+	for i := range resp.Subscriptions {
+		body.Subscriptions = append(body.Subscriptions, newSubscriptionV1(&resp.Subscriptions[i]))
+	}
+	return body
+}
+
+type totalBucketV1 struct {
+	application.TotalBucket
+	Total           int64 `json:"total"`
+	NormalizedTotal int64 `json:"normalized_total"`
+}
+
+type totalResponseV1 struct {
+	application.TotalResponse
+	Total           int64           `json:"total"`
+	NormalizedTotal int64           `json:"normalized_total"`
+	Buckets         []totalBucketV1 `json:"buckets,omitempty"`
+}
+
+func newTotalResponseV1(resp *application.TotalResponse) totalResponseV1 {
+	body := totalResponseV1{
+		TotalResponse:   *resp,
+		Total:           resp.Total.Major(),
+		NormalizedTotal: resp.NormalizedTotal.Major(),
+	}
+	for _, bucket := range resp.Buckets {
+		body.Buckets = append(body.Buckets, totalBucketV1{
+			TotalBucket:     bucket,
+			Total:           bucket.Total.Major(),
+			NormalizedTotal: bucket.NormalizedTotal.Major(),
+		})
+	}
+	return body
+}
+
+type priceChangeV1 struct {
+	application.PriceChange
+	Price        int64 `json:"price"`
+	MonthlyPrice int64 `json:"monthly_price"`
+}
+
+type pricesResponseV1 struct {
+	application.PricesResponse
+	Prices []priceChangeV1 `json:"prices"`
+}
+
+func newPricesResponseV1(resp *application.PricesResponse) pricesResponseV1 {
+	body := pricesResponseV1{PricesResponse: *resp}
+	for _, change := range resp.Prices {
+		body.Prices = append(body.Prices, priceChangeV1{
+			PriceChange:  change,
+			Price:        change.Price.Major(),
+			MonthlyPrice: change.MonthlyPrice.Major(),
+		})
+	}
+	return body
+}
@@ -0,0 +1,101 @@
+package rest
+
+import (
+	"github.com/azaliaz/subs-api/internal/application"
+	"github.com/gofiber/fiber/v2"
+)
+
+// The v2 routes return prices and totals as exact decimal strings in major
+// units of their currency, such as "299.90".
+
+// listEnvelope is the body of GET /api/v2/subscriptions. Unlike the v1 list
+// every field is snake_case and items is an empty array, not null, when
+// nothing matches.
+type listEnvelope struct {
+	Items []application.GetInfoResponse `json:"items"`
+	Page  pageInfo                      `json:"page"`
+}
+
+type pageInfo struct {
+	Total   int  `json:"total"`
+	HasMore bool `json:"has_more"`
+	// NextPageToken is null on the last page.
+	NextPageToken *string `json:"next_page_token"`
+}
+
+func (api *Service) GetListV2(c *fiber.Ctx) error {
+	resp, err := api.list(c)
+	if err != nil {
+		return err
+	}
+
+	body := listEnvelope{
+		Items: resp.Subscriptions,
+		Page: pageInfo{
+			Total:   resp.Total,
+			HasMore: resp.HasMore,
+		},
+	}
+	if body.Items == nil {
+		body.Items = []application.GetInfoResponse{}
+	}
+	if resp.NextPageToken != "" {
+		body.Page.NextPageToken = &resp.NextPageToken
+	}
+	return c.Status(fiber.StatusOK).JSON(body)
+}
+
+func (api *Service) CreateV2(c *fiber.Ctx) error {
+	return api.create(c, api.decodeCreateV2)
+}
+
+func (api *Service) GetInfoV2(c *fiber.Ctx) error {
+	resp, err := api.getInfo(c)
+	if err != nil {
+		return err
+	}
+	if notModified(c, etag(resp.Version)) {
+		return c.SendStatus(fiber.StatusNotModified)
+	}
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+func (api *Service) ReplaceV2(c *fiber.Ctx) error {
+	resp, err := api.replace(c, api.decodeCreateV2)
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+func (api *Service) PatchV2(c *fiber.Ctx) error {
+	resp, err := api.patch(c, api.decodeUpdateV2)
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+func (api *Service) RestoreV2(c *fiber.Ctx) error {
+	resp, err := api.restore(c)
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+func (api *Service) GetTotalV2(c *fiber.Ctx) error {
+	resp, err := api.total(c)
+	if err != nil {
+		return err
+	}
+	return c.Status(fiber.StatusOK).JSON(resp)
+}
+
+func (api *Service) decodeCreateV2(c *fiber.Ctx, req *application.CreateRequest) error {
+	return api.decodeBody(c, req)
+}
+
+func (api *Service) decodeUpdateV2(c *fiber.Ctx, req *application.UpdateRequest) error {
+	return api.decodeBody(c, req)
+}
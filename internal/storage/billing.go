@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"fmt"
+	"github.com/azaliaz/subs-api/pkg/month"
+)
+
+// MaxBillingPeriodMonths matches the check constraint on
+// billing_period_months.
+const MaxBillingPeriodMonths = 120
+
+// billingPeriodMonths defaults an unset billing period to one month.
+func billingPeriodMonths(months int) (int, error) {
+	if months == 0 {
+		return 1, nil
+	}
+	if months < 1 || months > MaxBillingPeriodMonths {
+		return 0, fmt.Errorf("%w: billing_period_months must be between 1 and %d", ErrValidation, MaxBillingPeriodMonths)
+	}
+	return months, nil
+}
+
+// billingAnchor parses an optional MM-YYYY anchor month. Without an anchor a
+// subscription is charged from its start_date.
+func billingAnchor(value *string) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	t, err := month.Parse(*value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid billing_anchor format, expected MM-YYYY", ErrValidation)
+	}
+	return t, nil
+}
@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"errors"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v5"
+	"strings"
+)
+
+var (
+	ErrNotFound   = errors.New("not found")
+	ErrValidation = errors.New("validation failed")
+	ErrConflict   = errors.New("conflict")
+	// ErrPreconditionFailed means the subscription exists but its version
+	// differs from the one the caller expected.
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrIdempotencyMismatch means an idempotency key was reused for a
+	// request with a different payload.
+	ErrIdempotencyMismatch = errors.New("idempotency key mismatch")
+	// ErrMissingFXRate means a total cannot be converted into the requested
+	// currency because no exchange rate is known for some billed month.
+	ErrMissingFXRate = errors.New("missing exchange rate")
+)
+
+// isConflict reports whether err is a PostgreSQL unique or exclusion
+// constraint violation.
+func isConflict(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == "23505" || pgErr.Code == "23P01"
+}
+
+// isCheckViolation reports whether err is a PostgreSQL check constraint
+// violation.
+func isCheckViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23514"
+}
+
+// isNoRows reports whether a single-row query found nothing. The pool is pgx
+// v4, so its sentinel is matched by message as well.
+func isNoRows(err error) bool {
+	return errors.Is(err, pgx.ErrNoRows) || strings.Contains(err.Error(), "no rows")
+}
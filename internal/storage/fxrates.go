@@ -0,0 +1,215 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"github.com/azaliaz/subs-api/pkg/money"
+	"github.com/azaliaz/subs-api/pkg/month"
+	"github.com/azaliaz/subs-api/pkg/tracing"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"time"
+)
+
+// DefaultCurrency matches the default of subscriptions.currency. Requests
+// that leave the currency empty are in it.
+const DefaultCurrency = "RUB"
+
+// FXRateScale is the number of decimal places fx_rates keeps for a rate.
+const FXRateScale = 10
+
+// FXRate converts one unit of Base into Rate units of Quote from Month on,
+// until a rate for a later month of the same pair takes over. The rate is
+// kept as a decimal string and passed to PostgreSQL as numeric, so it is
+// never rounded through a binary float.
+type FXRate struct {
+	Base  string        `json:"base"`
+	Quote string        `json:"quote"`
+	Month string        `json:"month"`
+	Rate  money.Decimal `json:"rate"`
+}
+
+type FXRatesRequest struct {
+	Base  *string `json:"base"`
+	Quote *string `json:"quote"`
+}
+
+type FXRateKey struct {
+	Base  string `json:"base"`
+	Quote string `json:"quote"`
+	Month string `json:"month"`
+}
+
+func currencyOrDefault(currency string) string {
+	if currency == "" {
+		return DefaultCurrency
+	}
+	return currency
+}
+
+// priceExponent checks that a price is in the minor units of its currency.
+func priceExponent(price money.Amount, currency string) error {
+	if exponent := money.Exponent(currency); price.Exponent != exponent {
+		return fmt.Errorf("%w: a %s price must have %d decimal places, not %d", ErrValidation, currency, exponent, price.Exponent)
+	}
+	return nil
+}
+
+// fxRateExpr is the rate converting the price in effect in month m into the
+// currency $5: the latest direct rate in effect in that month, or the
+// inverse of the opposite pair. It is NULL when neither is known.
+const fxRateExpr = `
+	CASE WHEN price.currency = $5 THEN 1::numeric ELSE COALESCE(
+		(SELECT r.rate FROM fx_rates r
+		 WHERE r.base_currency = price.currency AND r.quote_currency = $5 AND r.valid_from <= m
+		 ORDER BY r.valid_from DESC LIMIT 1),
+		(SELECT 1 / r.rate FROM fx_rates r
+		 WHERE r.base_currency = $5 AND r.quote_currency = price.currency AND r.valid_from <= m
+		 ORDER BY r.valid_from DESC LIMIT 1)
+	) END`
+
+func (r *Service) ListFXRates(ctx context.Context, request *FXRatesRequest) (_ []FXRate, err error) {
+	ctx, span := startSpan(ctx, "ListFXRates")
+	defer func() { tracing.End(span, err) }()
+	span.SetAttributes(semconv.DBCollectionName("fx_rates"))
+
+	log := r.requestLogger(ctx)
+
+	if request == nil {
+		request = &FXRatesRequest{}
+	}
+
+	query := `
+		SELECT base_currency, quote_currency, valid_from, trim_scale(rate)::text
+		FROM fx_rates
+		WHERE ($1::text IS NULL OR base_currency = $1)
+		  AND ($2::text IS NULL OR quote_currency = $2)
+		ORDER BY base_currency, quote_currency, valid_from`
+	setQuery(span, query)
+
+	rows, err := r.Pool().Query(ctx, query, request.Base, request.Quote)
+	if err != nil {
+		log.Error("failed to list fx rates in storage layer", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rates []FXRate
+	for rows.Next() {
+		var (
+			rate      FXRate
+			validFrom time.Time
+		)
+		if err := rows.Scan(&rate.Base, &rate.Quote, &validFrom, &rate.Rate); err != nil {
+			log.Error("failed to scan fx rate in storage layer", "error", err)
+			return nil, err
+		}
+		rate.Month = month.Format(validFrom)
+		rates = append(rates, rate)
+	}
+	if err := rows.Err(); err != nil {
+		log.Error("failed to read fx rates in storage layer", "error", err)
+		return nil, err
+	}
+
+	return rates, nil
+}
+
+// SaveFXRates inserts the rates or replaces those already stored for the
+// same pair and month. Either all of them are saved or none.
+func (r *Service) SaveFXRates(ctx context.Context, rates []FXRate) (err error) {
+	ctx, span := startSpan(ctx, "SaveFXRates")
+	defer func() { tracing.End(span, err) }()
+	span.SetAttributes(semconv.DBCollectionName("fx_rates"))
+
+	log := r.requestLogger(ctx)
+
+	validFrom := make([]time.Time, len(rates))
+	for i, rate := range rates {
+		t, err := month.Parse(rate.Month)
+		if err != nil {
+			log.Error("invalid fx rate month in storage layer", "month", rate.Month)
+			return fmt.Errorf("%w: invalid fx rate month %q, expected MM-YYYY", ErrValidation, rate.Month)
+		}
+		if amount, err := rate.Rate.Amount(FXRateScale); err != nil || amount.Minor <= 0 {
+			log.Error("invalid fx rate in storage layer", "rate", rate.Rate)
+			return fmt.Errorf("%w: fx rate must be positive with at most %d decimal places", ErrValidation, FXRateScale)
+		}
+		validFrom[i] = t
+	}
+
+	conn, err := r.Pool().Acquire(ctx)
+	if err != nil {
+		log.Error("failed to acquire DB connection", "error", err)
+		return err
+	}
+	defer conn.Release()
+
+	query := `
+		INSERT INTO fx_rates (base_currency, quote_currency, valid_from, rate)
+		VALUES ($1, $2, $3, $4::numeric)
+		ON CONFLICT (base_currency, quote_currency, valid_from) DO UPDATE
+		SET rate       = EXCLUDED.rate,
+			updated_at = now()`
+	setQuery(span, query)
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		log.Error("failed to begin transaction in storage layer", "error", err)
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	for i, rate := range rates {
+		if _, err := tx.Exec(ctx, query, rate.Base, rate.Quote, validFrom[i], string(rate.Rate)); err != nil {
+			log.Error("failed to save fx rate in storage layer", "error", err, "base", rate.Base, "quote", rate.Quote)
+			if isCheckViolation(err) {
+				return fmt.Errorf("%w: %w", ErrValidation, err)
+			}
+			return err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		log.Error("failed to commit transaction in storage layer", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+func (r *Service) DeleteFXRate(ctx context.Context, key *FXRateKey) (err error) {
+	ctx, span := startSpan(ctx, "DeleteFXRate")
+	defer func() { tracing.End(span, err) }()
+	span.SetAttributes(semconv.DBCollectionName("fx_rates"))
+
+	log := r.requestLogger(ctx)
+
+	if key == nil {
+		log.Error("request object is nil in storage layer")
+		return fmt.Errorf("%w: request object is nil", ErrValidation)
+	}
+	validFrom, err := month.Parse(key.Month)
+	if err != nil {
+		log.Error("invalid fx rate month in storage layer", "month", key.Month)
+		return fmt.Errorf("%w: invalid fx rate month %q, expected MM-YYYY", ErrValidation, key.Month)
+	}
+
+	query := `DELETE FROM fx_rates WHERE base_currency = $1 AND quote_currency = $2 AND valid_from = $3`
+	setQuery(span, query)
+
+	tag, err := r.Pool().Exec(ctx, query, key.Base, key.Quote, validFrom)
+	if err != nil {
+		log.Error("failed to delete fx rate in storage layer", "error", err)
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("%w: %s/%s rate for %s", ErrNotFound, key.Base, key.Quote, key.Month)
+	}
+
+	return nil
+}
+
+// missingFXRate reports a month in which subscriptions in currency from
+// cannot be converted into currency to.
+func missingFXRate(from, to string, m time.Time) error {
+	return fmt.Errorf("%w: no %s/%s rate for %s", ErrMissingFXRate, from, to, month.Format(m))
+}
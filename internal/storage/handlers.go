@@ -4,355 +4,906 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"github.com/azaliaz/subs-api/pkg/money"
+	"github.com/azaliaz/subs-api/pkg/month"
+	"github.com/azaliaz/subs-api/pkg/tracing"
 	"github.com/google/uuid"
-	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v4"
 	"strings"
 	"time"
 )
 
-func (r *Service) Create(ctx context.Context, request *CreateRequest) (*CreateResponse, error) {
+func (r *Service) Create(ctx context.Context, request *CreateRequest) (_ *CreateResponse, err error) {
+	ctx, span := startSpan(ctx, "Create")
+	defer func() { tracing.End(span, err) }()
+
+	log := r.requestLogger(ctx)
+
 	conn, err := r.Pool().Acquire(ctx)
 	if err != nil {
-		r.log.Error("failed to acquire DB connection", "error", err)
+		log.Error("failed to acquire DB connection", "error", err)
 		return nil, err
 	}
 	defer conn.Release()
 
-	parts := strings.Split(request.StartDate, "-")
-	if len(parts) != 2 {
-		r.log.Error("invalid start_date format in storage layer", "start_date", request.StartDate)
-		return nil, fmt.Errorf("invalid start_date format, expected MM-YYYY")
+	startDate, err := month.Parse(request.StartDate)
+	if err != nil {
+		log.Error("invalid start_date format in storage layer", "start_date", request.StartDate)
+		return nil, fmt.Errorf("%w: invalid start_date format, expected MM-YYYY", ErrValidation)
 	}
-	startISO := fmt.Sprintf("%s-%s-01", parts[1], parts[0])
 
-	var endVal interface{} = nil
+	var endDate interface{} = nil
 	if request.EndDate != nil {
-		ep := strings.Split(*request.EndDate, "-")
-		if len(ep) != 2 {
-			r.log.Error("invalid end_date format in storage layer", "end_date", *request.EndDate)
-			return nil, fmt.Errorf("invalid end_date format, expected MM-YYYY")
+		t, err := month.Parse(*request.EndDate)
+		if err != nil {
+			log.Error("invalid end_date format in storage layer", "end_date", *request.EndDate)
+			return nil, fmt.Errorf("%w: invalid end_date format, expected MM-YYYY", ErrValidation)
+		}
+		endDate = t
+	}
+
+	periodMonths, err := billingPeriodMonths(request.BillingPeriodMonths)
+	if err != nil {
+		log.Error("invalid billing period in storage layer", "billing_period_months", request.BillingPeriodMonths)
+		return nil, err
+	}
+	anchor, err := billingAnchor(request.BillingAnchor)
+	if err != nil {
+		log.Error("invalid billing_anchor format in storage layer", "billing_anchor", *request.BillingAnchor)
+		return nil, err
+	}
+	currency := currencyOrDefault(request.Currency)
+	if err := priceExponent(request.Price, currency); err != nil {
+		log.Error("invalid price exponent in storage layer", "price", request.Price, "currency", currency)
+		return nil, err
+	}
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		log.Error("failed to begin transaction in storage layer", "error", err)
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	if request.Idempotency != nil {
+		replay, err := claimIdempotencyKey(ctx, tx, request.Idempotency)
+		if err != nil {
+			log.Warn("idempotency key rejected in storage layer", "error", err)
+			return nil, err
+		}
+		if replay != nil {
+			log.Info("replaying idempotent create in storage layer", "id", replay.ID)
+			return replay, nil
 		}
-		endVal = fmt.Sprintf("%s-%s-01", ep[1], ep[0])
 	}
 
+	query := `INSERT INTO subscriptions (user_id, service_name, start_date, end_date, billing_period_months, billing_anchor, current_price)
+         VALUES ($1, $2, $3, $4, $5, $6, $7::numeric)
+         RETURNING id`
+	setQuery(span, query)
+
 	var id uuid.UUID
-	err = conn.QueryRow(ctx,
-		`INSERT INTO subscriptions (user_id, service_name, price, start_date, end_date)
-         VALUES ($1, $2, $3, $4, $5)
-         RETURNING id`,
+	err = tx.QueryRow(ctx, query,
 		request.UserID,
 		request.ServiceName,
-		request.Price,
-		startISO,
-		endVal,
+		startDate,
+		endDate,
+		periodMonths,
+		anchor,
+		request.Price.String(),
 	).Scan(&id)
 	if err != nil {
-		r.log.Error("failed to insert subscription in storage layer",
+		log.Error("failed to insert subscription in storage layer",
 			"error", err,
 			"user_id", request.UserID,
 			"service_name", request.ServiceName,
 		)
+		if isConflict(err) {
+			return nil, fmt.Errorf("%w: %w", ErrConflict, err)
+		}
+		if isCheckViolation(err) {
+			return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+		}
+		return nil, err
+	}
+	if err := appendPrice(ctx, tx, id, request.Price, &currency, &startDate); err != nil {
+		log.Error("failed to insert subscription price in storage layer", "error", err, "id", id)
+		return nil, err
+	}
+
+	overlaps, err := checkOverlaps(ctx, tx, id, request.Overlap)
+	if err != nil {
+		log.Warn("subscription overlap check failed in storage layer", "error", err, "user_id", request.UserID)
 		return nil, err
 	}
 
-	return &CreateResponse{ID: id}, nil
+	resp := &CreateResponse{ID: id, Overlaps: overlaps}
+	if request.Idempotency != nil {
+		if err := saveIdempotentResponse(ctx, tx, request.Idempotency, resp); err != nil {
+			log.Error("failed to save idempotent response in storage layer", "error", err)
+			return nil, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		log.Error("failed to commit transaction in storage layer", "error", err)
+		return nil, err
+	}
+
+	return resp, nil
 }
 
-func (r *Service) GetInfo(ctx context.Context, id uuid.UUID) (*GetInfoResponse, error) {
+func (r *Service) GetInfo(ctx context.Context, id uuid.UUID) (_ *GetInfoResponse, err error) {
+	ctx, span := startSpan(ctx, "GetInfo")
+	defer func() { tracing.End(span, err) }()
+
+	log := r.requestLogger(ctx)
+
 	if id == uuid.Nil {
-		r.log.Error("invalid subscription id in storage layer")
-		return nil, errors.New("subscription with id not found")
+		log.Error("invalid subscription id in storage layer")
+		return nil, fmt.Errorf("%w: id is required", ErrValidation)
 	}
 
 	conn, err := r.Pool().Acquire(ctx)
 	if err != nil {
-		r.log.Error("failed to acquire DB connection", "error", err)
+		log.Error("failed to acquire DB connection", "error", err)
 		return nil, err
 	}
 	defer conn.Release()
 
-	row := conn.QueryRow(ctx,
-		`SELECT user_id, service_name, price, start_date, end_date
-         FROM subscriptions
-         WHERE id = $1`,
-		id,
-	)
+	query := `SELECT ` + subscriptionColumns + `
+         FROM ` + subscriptionsFrom + `
+         WHERE id = $1 AND deleted_at IS NULL`
+	setQuery(span, query)
 
-	var userID uuid.UUID
-	var serviceName string
-	var startDate time.Time
-	var endDate *time.Time
-	var price int
-	err = row.Scan(&userID, &serviceName, &price, &startDate, &endDate)
+	resp, err := scanSubscription(conn.QueryRow(ctx, query, id))
 	if err != nil {
-		if err == pgx.ErrNoRows || errors.Is(err, pgx.ErrNoRows) || strings.Contains(err.Error(), "no rows") {
-			r.log.Warn("subscription not found in DB", "id", id)
+		if isNoRows(err) {
+			log.Warn("subscription not found in DB", "id", id)
 			return nil, nil
 		}
-		r.log.Error("failed to scan subscription row in storage layer", "error", err, "id", id)
+		log.Error("failed to scan subscription row in storage layer", "error", err, "id", id)
 		return nil, err
 	}
 
-	startStr := startDate.Format("01-2006")
-	var endStr *string
-	if endDate != nil {
-		s := endDate.Format("01-2006")
-		endStr = &s
-	}
-
-	resp := &GetInfoResponse{
-		ID:          id,
-		UserID:      userID,
-		ServiceName: serviceName,
-		Price:       price,
-		StartDate:   startStr,
-		EndDate:     endStr,
-	}
-
-	r.log.Info("subscription info retrieved successfully in storage layer",
-		"id", id,
-		"user_id", userID,
-		"service_name", serviceName,
-		"price", price,
-		"start_date", startStr,
-		"end_date", endStr,
-	)
+	log.Debug("subscription info retrieved in storage layer", "id", id)
 
 	return resp, nil
 }
 
-func (r *Service) List(ctx context.Context, request *ListRequest) (*ListResponse, error) {
+func (r *Service) List(ctx context.Context, request *ListRequest) (_ *ListResponse, err error) {
+	ctx, span := startSpan(ctx, "List")
+	defer func() { tracing.End(span, err) }()
+
+	log := r.requestLogger(ctx)
+
 	if request == nil {
-		r.log.Error("request object is nil in storage layer")
-		return nil, errors.New("request object is nil")
+		log.Error("request object is nil in storage layer")
+		return nil, fmt.Errorf("%w: request object is nil", ErrValidation)
+	}
+
+	sortName := request.Sort
+	if sortName == "" {
+		sortName = SortByStartDate
+	}
+	order, ok := listSorts[sortName]
+	if !ok {
+		log.Error("unsupported sort in storage layer", "sort", request.Sort)
+		return nil, fmt.Errorf("%w: unsupported sort %q", ErrValidation, request.Sort)
 	}
 
 	conn, err := r.Pool().Acquire(ctx)
 	if err != nil {
-		r.log.Error("failed to acquire DB connection", "error", err)
+		log.Error("failed to acquire DB connection", "error", err)
 		return nil, err
 	}
 	defer conn.Release()
 
 	var args []interface{}
 	conds := []string{"1=1"}
+	if !request.IncludeDeleted {
+		conds = append(conds, "deleted_at IS NULL")
+	}
 
 	argIdx := 1
-	if request.UserID != nil && *request.UserID != uuid.Nil {
-		conds = append(conds, fmt.Sprintf("user_id = $%d", argIdx))
-		args = append(args, *request.UserID)
+	if len(request.UserIDs) > 0 {
+		userIDs := make([]string, len(request.UserIDs))
+		for i, id := range request.UserIDs {
+			userIDs[i] = id.String()
+		}
+		conds = append(conds, fmt.Sprintf("user_id = ANY($%d::uuid[])", argIdx))
+		args = append(args, userIDs)
 		argIdx++
 	}
 	if request.ServiceName != nil && *request.ServiceName != "" {
-		conds = append(conds, fmt.Sprintf("service_name ILIKE $%d", argIdx))
-		args = append(args, "%"+*request.ServiceName+"%")
+		if request.ServiceNameExact {
+			conds = append(conds, fmt.Sprintf("service_name = $%d", argIdx))
+			args = append(args, *request.ServiceName)
+		} else {
+			conds = append(conds, fmt.Sprintf("service_name ILIKE $%d", argIdx))
+			args = append(args, likePattern(*request.ServiceName))
+		}
 		argIdx++
 	}
 	if request.From != nil {
-		fromDate, err := time.Parse("01-2006", *request.From)
+		fromDate, err := month.Parse(*request.From)
 		if err != nil {
-			r.log.Error("invalid From date in storage layer", "from", *request.From, "error", err)
-			return nil, fmt.Errorf("invalid From date: %w", err)
+			log.Error("invalid From date in storage layer", "from", *request.From, "error", err)
+			return nil, fmt.Errorf("%w: invalid From date: %w", ErrValidation, err)
 		}
 		conds = append(conds, fmt.Sprintf("start_date >= $%d", argIdx))
 		args = append(args, fromDate)
 		argIdx++
 	}
 	if request.To != nil {
-		toDate, err := time.Parse("01-2006", *request.To)
+		toDate, err := month.Parse(*request.To)
 		if err != nil {
-			r.log.Error("invalid To date in storage layer", "to", *request.To, "error", err)
-			return nil, fmt.Errorf("invalid To date: %w", err)
+			log.Error("invalid To date in storage layer", "to", *request.To, "error", err)
+			return nil, fmt.Errorf("%w: invalid To date: %w", ErrValidation, err)
 		}
 		toDate = toDate.AddDate(0, 1, -1)
 		conds = append(conds, fmt.Sprintf("start_date <= $%d", argIdx))
 		args = append(args, toDate)
 		argIdx++
 	}
+	if request.PriceMin != nil {
+		conds = append(conds, fmt.Sprintf("%s >= $%d::numeric", priceExpr, argIdx))
+		args = append(args, string(*request.PriceMin))
+		argIdx++
+	}
+	if request.PriceMax != nil {
+		conds = append(conds, fmt.Sprintf("%s <= $%d::numeric", priceExpr, argIdx))
+		args = append(args, string(*request.PriceMax))
+		argIdx++
+	}
+	if request.Status != "" {
+		asOf := time.Now()
+		if request.AsOf != nil {
+			asOf, err = month.Parse(*request.AsOf)
+			if err != nil {
+				log.Error("invalid as_of date in storage layer", "as_of", *request.AsOf, "error", err)
+				return nil, fmt.Errorf("%w: invalid as_of date: %w", ErrValidation, err)
+			}
+		}
+		asOf = time.Date(asOf.Year(), asOf.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+		switch request.Status {
+		case StatusActive:
+			conds = append(conds, fmt.Sprintf("start_date <= $%d AND (end_date IS NULL OR end_date >= $%d)", argIdx, argIdx))
+		case StatusEnded:
+			conds = append(conds, fmt.Sprintf("end_date < $%d", argIdx))
+		case StatusFuture:
+			conds = append(conds, fmt.Sprintf("start_date > $%d", argIdx))
+		default:
+			log.Error("unsupported status in storage layer", "status", request.Status)
+			return nil, fmt.Errorf("%w: unsupported status %q", ErrValidation, request.Status)
+		}
+		args = append(args, asOf)
+		argIdx++
+	}
+
+	if err := refreshCurrentPrices(ctx, conn); err != nil {
+		log.Error("failed to refresh current prices in storage layer", "error", err)
+		return nil, err
+	}
+
+	// The filters only use columns of subscriptions, so counting needs no
+	// price history.
+	var resp ListResponse
+	countQuery := fmt.Sprintf(`SELECT count(*) FROM subscriptions s WHERE %s`, strings.Join(conds, " AND "))
+	if err := conn.QueryRow(ctx, countQuery, args...).Scan(&resp.Total); err != nil {
+		log.Error("failed to count subscriptions in storage layer", "error", err)
+		return nil, err
+	}
+
+	direction, compare := "ASC", ">"
+	if request.Desc {
+		direction, compare = "DESC", "<"
+	}
+	if request.After != nil {
+		if !order.valid(request.After.Value) {
+			log.Error("invalid cursor in storage layer", "sort", sortName, "value", request.After.Value)
+			return nil, fmt.Errorf("%w: invalid cursor for sort %q", ErrValidation, sortName)
+		}
+		conds = append(conds, fmt.Sprintf("(%s, id) %s ($%d::%s, $%d)", order.expr, compare, argIdx, order.typ, argIdx+1))
+		args = append(args, request.After.Value, request.After.ID)
+		argIdx += 2
+	}
 
 	limit := 50
 	if request.Limit != nil && *request.Limit > 0 {
 		limit = *request.Limit
 	}
 	offset := 0
-	if request.Offset != nil && *request.Offset >= 0 {
+	if request.After == nil && request.Offset != nil && *request.Offset >= 0 {
 		offset = *request.Offset
 	}
 
+	// One extra row tells whether another page follows; id breaks ties
+	// between subscriptions with the same sort key.
 	query := fmt.Sprintf(`
-		SELECT id, user_id, service_name, price, start_date, end_date
-		FROM subscriptions
+		SELECT %s
+		FROM %s
 		WHERE %s
-		ORDER BY start_date
-		LIMIT $%d OFFSET $%d`, strings.Join(conds, " AND "), argIdx, argIdx+1)
+		ORDER BY %s %s, id %s
+		LIMIT $%d OFFSET $%d`,
+		subscriptionColumns, subscriptionsFrom, strings.Join(conds, " AND "), order.expr, direction, direction, argIdx, argIdx+1)
 
-	args = append(args, limit, offset)
+	args = append(args, limit+1, offset)
 
-	r.log.Info("executing query in storage layer", "query", query, "args", args)
+	log.Debug("executing query in storage layer", "query", query, "args", redactArgs(args))
+	setQuery(span, query)
 
 	rows, err := conn.Query(ctx, query, args...)
 	if err != nil {
-		r.log.Error("failed to execute query in storage layer", "error", err)
+		log.Error("failed to execute query in storage layer", "error", err)
 		return nil, err
 	}
 	defer rows.Close()
 
-	var resp ListResponse
 	for rows.Next() {
-		var (
-			id          uuid.UUID
-			userID      uuid.UUID
-			serviceName string
-			price       int
-			startDate   time.Time
-			endDate     *time.Time
-		)
-		if err := rows.Scan(&id, &userID, &serviceName, &price, &startDate, &endDate); err != nil {
-			r.log.Error("failed to scan row in storage layer", "error", err)
+		sub, err := scanSubscription(rows)
+		if err != nil {
+			log.Error("failed to scan row in storage layer", "error", err)
 			return nil, err
 		}
+		resp.Subscriptions = append(resp.Subscriptions, *sub)
+	}
+	if err := rows.Err(); err != nil {
+		log.Error("failed to read rows in storage layer", "error", err)
+		return nil, err
+	}
+	if len(resp.Subscriptions) > limit {
+		resp.Subscriptions = resp.Subscriptions[:limit]
+		resp.HasMore = true
 
-		startStr := startDate.Format("01-2006")
-		var endStr *string
-		if endDate != nil {
-			s := endDate.Format("01-2006")
-			endStr = &s
-		}
-
-		resp.Subscriptions = append(resp.Subscriptions, GetInfoResponse{
-			ID:          id,
-			UserID:      userID,
-			ServiceName: serviceName,
-			Price:       price,
-			StartDate:   startStr,
-			EndDate:     endStr,
-		})
+		last := &resp.Subscriptions[limit-1]
+		resp.Next = &ListCursor{Value: order.key(last), ID: last.ID}
 	}
 
 	return &resp, nil
 }
 
-func (r *Service) Update(ctx context.Context, id uuid.UUID, request *UpdateRequest) (*UpdateResponse, error) {
+func (r *Service) Update(ctx context.Context, id uuid.UUID, request *UpdateRequest) (_ *UpdateResponse, err error) {
+	ctx, span := startSpan(ctx, "Update")
+	defer func() { tracing.End(span, err) }()
+
+	log := r.requestLogger(ctx)
+
 	if request == nil {
-		r.log.Error("request object is nil in storage layer")
-		return nil, errors.New("request object is nil")
+		log.Error("request object is nil in storage layer")
+		return nil, fmt.Errorf("%w: request object is nil", ErrValidation)
 	}
 
 	conn, err := r.Pool().Acquire(ctx)
 	if err != nil {
-		r.log.Error("failed to acquire DB connection", "error", err)
+		log.Error("failed to acquire DB connection", "error", err)
 		return nil, err
 	}
 	defer conn.Release()
 
+	if request.UserID.Null || request.ServiceName.Null || request.Price.Null || request.Currency.Null ||
+		request.StartDate.Null || request.BillingPeriodMonths.Null {
+		log.Error("required field set to null in storage layer")
+		return nil, fmt.Errorf("%w: only end_date and billing_anchor can be cleared", ErrValidation)
+	}
+	if request.Currency.Set && !request.Price.Set {
+		log.Error("currency without a price in storage layer")
+		return nil, fmt.Errorf("%w: currency can only change together with price", ErrValidation)
+	}
+
 	var startDate, endDate interface{}
-	if request.StartDate != nil {
-		t, err := time.Parse("01-2006", *request.StartDate)
+	if request.StartDate.Set {
+		t, err := month.Parse(request.StartDate.Value)
 		if err != nil {
-			r.log.Error("invalid start_date format in storage layer", "startDate", *request.StartDate, "error", err)
-			return nil, fmt.Errorf("invalid start_date format: %w", err)
+			log.Error("invalid start_date format in storage layer", "startDate", request.StartDate.Value, "error", err)
+			return nil, fmt.Errorf("%w: invalid start_date format: %w", ErrValidation, err)
 		}
 		startDate = t
 	}
-	if request.EndDate != nil {
-		t, err := time.Parse("01-2006", *request.EndDate)
+	if request.EndDate.Set && !request.EndDate.Null {
+		t, err := month.Parse(request.EndDate.Value)
 		if err != nil {
-			r.log.Error("invalid end_date format in storage layer", "endDate", *request.EndDate, "error", err)
-			return nil, fmt.Errorf("invalid end_date format: %w", err)
+			log.Error("invalid end_date format in storage layer", "endDate", request.EndDate.Value, "error", err)
+			return nil, fmt.Errorf("%w: invalid end_date format: %w", ErrValidation, err)
 		}
 		endDate = t
 	}
+	var periodMonths interface{}
+	if request.BillingPeriodMonths.Set {
+		months, err := billingPeriodMonths(request.BillingPeriodMonths.Value)
+		if err != nil {
+			log.Error("invalid billing period in storage layer", "billing_period_months", request.BillingPeriodMonths.Value)
+			return nil, err
+		}
+		periodMonths = months
+	}
+	anchor, err := billingAnchor(request.BillingAnchor.Ptr())
+	if err != nil {
+		log.Error("invalid billing_anchor format in storage layer", "billing_anchor", request.BillingAnchor.Value)
+		return nil, err
+	}
+	var priceFrom *time.Time
+	if request.PriceEffectiveFrom.Set {
+		t, err := month.Parse(request.PriceEffectiveFrom.Value)
+		if err != nil {
+			log.Error("invalid price_effective_from format in storage layer", "price_effective_from", request.PriceEffectiveFrom.Value, "error", err)
+			return nil, fmt.Errorf("%w: invalid price_effective_from format: %w", ErrValidation, err)
+		}
+		priceFrom = &t
+	}
 
-	cmdTag, err := conn.Exec(ctx, `
+	// Every column is paired with a flag so that a field which is set to
+	// null can be told apart from one that is left unchanged.
+	query := `
 		UPDATE subscriptions
 		SET
-			service_name = COALESCE($2, service_name),
-			price        = COALESCE($3, price),
-			start_date   = COALESCE($4, start_date),
-			end_date     = COALESCE($5, end_date)
-		WHERE id = $1
-	`, id, request.ServiceName, request.Price, startDate, endDate)
+			user_id               = CASE WHEN $2::boolean THEN $3::uuid ELSE user_id END,
+			service_name          = CASE WHEN $4::boolean THEN $5::text ELSE service_name END,
+			start_date            = CASE WHEN $6::boolean THEN $7::date ELSE start_date END,
+			end_date              = CASE WHEN $8::boolean THEN $9::date ELSE end_date END,
+			billing_period_months = CASE WHEN $11::boolean THEN $12::integer ELSE billing_period_months END,
+			billing_anchor        = CASE WHEN $13::boolean THEN $14::date ELSE billing_anchor END,
+			reject_overlaps       = reject_overlaps AND NOT ($2 OR $4 OR $6 OR $8),
+			updated_at            = now(),
+			version               = version + 1
+		WHERE id = $1 AND deleted_at IS NULL AND ($10::bigint IS NULL OR version = $10)
+		RETURNING id`
+	setQuery(span, query)
+
+	tx, err := conn.Begin(ctx)
 	if err != nil {
-		r.log.Error("failed to update subscription in storage layer", "error", err)
+		log.Error("failed to begin transaction in storage layer", "error", err)
 		return nil, err
 	}
+	defer func() { _ = tx.Rollback(ctx) }()
 
-	updated := cmdTag.RowsAffected() > 0
-	return &UpdateResponse{Updated: updated}, nil
+	err = tx.QueryRow(ctx, query, id,
+		request.UserID.Set, request.UserID.Ptr(),
+		request.ServiceName.Set, request.ServiceName.Ptr(),
+		request.StartDate.Set, startDate,
+		request.EndDate.Set, endDate,
+		request.Version,
+		request.BillingPeriodMonths.Set, periodMonths,
+		request.BillingAnchor.Set, anchor,
+	).Scan(&id)
+	if err != nil {
+		if isNoRows(err) {
+			log.Warn("subscription not updated in DB", "id", id, "version", request.Version)
+			return nil, missingOrChanged(ctx, tx, id, request.Version)
+		}
+		log.Error("failed to update subscription in storage layer", "error", err)
+		if isConflict(err) {
+			return nil, fmt.Errorf("%w: %w", ErrConflict, err)
+		}
+		if isCheckViolation(err) {
+			return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+		}
+		return nil, err
+	}
+
+	// A price is never overwritten: a new one, in its own currency, is
+	// appended to the history.
+	if request.Price.Set {
+		if err := appendPrice(ctx, tx, id, request.Price.Value, request.Currency.Ptr(), priceFrom); err != nil {
+			log.Error("failed to append subscription price in storage layer", "error", err, "id", id)
+			if errors.Is(err, ErrValidation) {
+				return nil, err
+			}
+			if isCheckViolation(err) {
+				return nil, fmt.Errorf("%w: %w", ErrValidation, err)
+			}
+			return nil, err
+		}
+	}
+
+	sub, err := scanSubscription(tx.QueryRow(ctx, `SELECT `+subscriptionColumns+` FROM `+subscriptionsFrom+` WHERE id = $1`, id))
+	if err != nil {
+		log.Error("failed to read updated subscription in storage layer", "error", err, "id", id)
+		return nil, err
+	}
+
+	// Changing only the price keeps existing overlaps as they are.
+	var overlaps []uuid.UUID
+	if request.UserID.Set || request.ServiceName.Set || request.StartDate.Set || request.EndDate.Set {
+		overlaps, err = checkOverlaps(ctx, tx, id, request.Overlap)
+		if err != nil {
+			log.Warn("subscription overlap check failed in storage layer", "error", err, "id", id)
+			return nil, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		log.Error("failed to commit transaction in storage layer", "error", err)
+		return nil, err
+	}
+
+	return &UpdateResponse{GetInfoResponse: *sub, Overlaps: overlaps}, nil
 }
 
-func (r *Service) Delete(ctx context.Context, request *DeleteRequest) error {
+func (r *Service) Delete(ctx context.Context, request *DeleteRequest) (err error) {
+	ctx, span := startSpan(ctx, "Delete")
+	defer func() { tracing.End(span, err) }()
+
+	log := r.requestLogger(ctx)
+
 	if request == nil {
-		r.log.Error("request object is nil in storage layer")
-		return errors.New("request object is nil")
+		log.Error("request object is nil in storage layer")
+		return fmt.Errorf("%w: request object is nil", ErrValidation)
 	}
 	if request.ID == uuid.Nil {
-		r.log.Error("id is required in storage layer")
-		return errors.New("id is required")
+		log.Error("id is required in storage layer")
+		return fmt.Errorf("%w: id is required", ErrValidation)
 	}
 
 	conn, err := r.Pool().Acquire(ctx)
 	if err != nil {
-		r.log.Error("failed to acquire DB connection", "error", err)
+		log.Error("failed to acquire DB connection", "error", err)
 		return err
 	}
 	defer conn.Release()
 
-	cmdTag, err := conn.Exec(ctx, `DELETE FROM subscriptions WHERE id = $1`, request.ID)
+	// The row is kept, so that a deletion can be undone, until PurgeDeleted
+	// removes it.
+	query := `
+		UPDATE subscriptions
+		SET deleted_at = now(), updated_at = now(), version = version + 1
+		WHERE id = $1 AND deleted_at IS NULL AND ($2::bigint IS NULL OR version = $2)`
+	setQuery(span, query)
+
+	cmdTag, err := conn.Exec(ctx, query, request.ID, request.Version)
 	if err != nil {
-		r.log.Error("failed to delete subscription in storage layer", "error", err)
+		log.Error("failed to delete subscription in storage layer", "error", err)
 		return err
 	}
 
 	if cmdTag.RowsAffected() == 0 {
-		r.log.Warn("subscription not found in storage layer", "id", request.ID)
-		return fmt.Errorf("subscription with id %s not found", request.ID)
+		log.Warn("subscription not deleted in storage layer", "id", request.ID, "version", request.Version)
+		return missingOrChanged(ctx, conn, request.ID, request.Version)
 	}
 
 	return nil
 }
 
-func (r *Service) GetTotalSubscriptionsPrice(ctx context.Context, request *TotalRequest) (int, error) {
+func (r *Service) Restore(ctx context.Context, request *RestoreRequest) (_ *UpdateResponse, err error) {
+	ctx, span := startSpan(ctx, "Restore")
+	defer func() { tracing.End(span, err) }()
+
+	log := r.requestLogger(ctx)
+
 	if request == nil {
-		r.log.Error("request object is nil in storage layer")
-		return 0, errors.New("request object is nil")
+		log.Error("request object is nil in storage layer")
+		return nil, fmt.Errorf("%w: request object is nil", ErrValidation)
+	}
+	if request.ID == uuid.Nil {
+		log.Error("id is required in storage layer")
+		return nil, fmt.Errorf("%w: id is required", ErrValidation)
 	}
 
 	conn, err := r.Pool().Acquire(ctx)
 	if err != nil {
-		r.log.Error("failed to acquire DB connection", "error", err)
-		return 0, err
+		log.Error("failed to acquire DB connection", "error", err)
+		return nil, err
 	}
 	defer conn.Release()
 
-	fromDate, err := time.Parse("01-2006", request.From)
+	query := `
+		UPDATE subscriptions
+		SET deleted_at = NULL, reject_overlaps = false, updated_at = now(), version = version + 1
+		WHERE id = $1 AND deleted_at IS NOT NULL AND ($2::uuid IS NULL OR user_id = $2)`
+	setQuery(span, query)
+
+	tx, err := conn.Begin(ctx)
 	if err != nil {
-		r.log.Error("invalid From date format in storage layer", "from", request.From, "error", err)
-		return 0, fmt.Errorf("invalid From date: %w", err)
+		log.Error("failed to begin transaction in storage layer", "error", err)
+		return nil, err
 	}
+	defer func() { _ = tx.Rollback(ctx) }()
 
-	toDate, err := time.Parse("01-2006", request.To)
+	cmdTag, err := tx.Exec(ctx, query, request.ID, request.UserID)
 	if err != nil {
-		r.log.Error("invalid To date format in storage layer", "to", request.To, "error", err)
-		return 0, fmt.Errorf("invalid To date: %w", err)
+		log.Error("failed to restore subscription in storage layer", "error", err)
+		return nil, err
+	}
+	if cmdTag.RowsAffected() == 0 {
+		log.Warn("subscription not restored in storage layer", "id", request.ID)
+		return nil, notDeleted(ctx, tx, request.ID, request.UserID)
 	}
-	toDate = toDate.AddDate(0, 1, -1)
 
-	var total int
-	query := `
-		SELECT COALESCE(SUM(price), 0)
-		FROM subscriptions
-		WHERE ($1::uuid IS NULL OR user_id = $1)
-		  AND ($2::text IS NULL OR service_name ILIKE '%' || $2 || '%')
-		  AND start_date >= $3
-		  AND start_date <= $4
-	`
-
-	err = conn.QueryRow(ctx, query, request.UserID, request.ServiceName, fromDate, toDate).Scan(&total)
+	sub, err := scanSubscription(tx.QueryRow(ctx, `SELECT `+subscriptionColumns+` FROM `+subscriptionsFrom+` WHERE id = $1`, request.ID))
 	if err != nil {
-		r.log.Error("failed to get total subscriptions price in storage layer", "error", err)
+		log.Error("failed to read restored subscription in storage layer", "error", err, "id", request.ID)
+		return nil, err
+	}
+
+	// Subscriptions may have been added while this one was deleted.
+	overlaps, err := checkOverlaps(ctx, tx, request.ID, request.Overlap)
+	if err != nil {
+		log.Warn("subscription overlap check failed in storage layer", "error", err, "id", request.ID)
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		log.Error("failed to commit transaction in storage layer", "error", err)
+		return nil, err
+	}
+
+	return &UpdateResponse{GetInfoResponse: *sub, Overlaps: overlaps}, nil
+}
+
+// PurgeDeleted removes the subscriptions deleted before the given time,
+// together with their price history, and returns how many were removed.
+func (r *Service) PurgeDeleted(ctx context.Context, before time.Time) (_ int64, err error) {
+	ctx, span := startSpan(ctx, "PurgeDeleted")
+	defer func() { tracing.End(span, err) }()
+
+	log := r.requestLogger(ctx)
+
+	query := `DELETE FROM subscriptions WHERE deleted_at < $1`
+	setQuery(span, query)
+
+	tag, err := r.Pool().Exec(ctx, query, before)
+	if err != nil {
+		log.Error("failed to purge deleted subscriptions in storage layer", "error", err)
 		return 0, err
 	}
+	return tag.RowsAffected(), nil
+}
+
+func (r *Service) GetTotalSubscriptionsPrice(ctx context.Context, request *TotalRequest) (_ *TotalResponse, err error) {
+	ctx, span := startSpan(ctx, "GetTotalSubscriptionsPrice")
+	defer func() { tracing.End(span, err) }()
+
+	log := r.requestLogger(ctx)
+
+	if request == nil {
+		log.Error("request object is nil in storage layer")
+		return nil, fmt.Errorf("%w: request object is nil", ErrValidation)
+	}
+
+	fromDate, err := month.Parse(request.From)
+	if err != nil {
+		log.Error("invalid From date format in storage layer", "from", request.From, "error", err)
+		return nil, fmt.Errorf("%w: invalid From date: %w", ErrValidation, err)
+	}
+
+	toDate, err := month.Parse(request.To)
+	if err != nil {
+		log.Error("invalid To date format in storage layer", "to", request.To, "error", err)
+		return nil, fmt.Errorf("%w: invalid To date: %w", ErrValidation, err)
+	}
+
+	groupCols := make([]string, 0, len(request.GroupBy))
+	for _, group := range request.GroupBy {
+		switch group {
+		case GroupByMonth, GroupByServiceName, GroupByUserID:
+			groupCols = append(groupCols, group)
+		default:
+			log.Error("unsupported group_by value in storage layer", "group_by", group)
+			return nil, fmt.Errorf("%w: unsupported group_by value %q", ErrValidation, group)
+		}
+	}
+
+	conn, err := r.Pool().Acquire(ctx)
+	if err != nil {
+		log.Error("failed to acquire DB connection", "error", err)
+		return nil, err
+	}
+	defer conn.Release()
+
+	// The active range of a subscription is the intersection of
+	// [start_date, end_date] (end_date inclusive, open-ended when NULL) with
+	// [from, to]; billed expands it into one row per month so that totals can
+	// be grouped by month as well as by subscription attributes. The price in
+	// effect in the month is charged when it is a whole number of billing
+	// periods away from the anchor, and normalized spreads it evenly over the
+	// period. Both are converted with the rate in effect in the month into
+	// minor units of the requested currency, whose exponent is $6. A deleted
+	// subscription still counts in the months before the one it was deleted
+	// in, so that deleting it does not rewrite past totals.
+	billed := `
+		WITH billed AS (
+			SELECT s.id, s.user_id, s.service_name, price.currency, m::date AS month, fx.rate,
+				CASE WHEN mod(mod(
+					(EXTRACT(YEAR FROM m)::integer - EXTRACT(YEAR FROM COALESCE(s.billing_anchor, s.start_date))::integer) * 12
+					+ EXTRACT(MONTH FROM m)::integer - EXTRACT(MONTH FROM COALESCE(s.billing_anchor, s.start_date))::integer,
+					s.billing_period_months) + s.billing_period_months, s.billing_period_months) = 0
+				THEN price.price_minor ELSE 0 END * power(10::numeric, $6::integer - price.price_exponent) * fx.rate AS charge,
+				price.price_minor::numeric / s.billing_period_months * power(10::numeric, $6::integer - price.price_exponent) * fx.rate AS normalized
+			FROM subscriptions s
+			CROSS JOIN LATERAL generate_series(
+				GREATEST(s.start_date, $3::date)::timestamp,
+				LEAST(COALESCE(s.end_date, $4::date), $4::date)::timestamp,
+				interval '1 month'
+			) AS m
+			CROSS JOIN LATERAL ` + priceAt("m::date") + ` AS price
+			CROSS JOIN LATERAL (SELECT ` + fxRateExpr + ` AS rate) AS fx
+			WHERE ($1::uuid IS NULL OR s.user_id = $1)
+			  AND ($2::text IS NULL OR s.service_name ILIKE $2)
+			  AND s.start_date <= $4::date
+			  AND (s.end_date IS NULL OR s.end_date >= $3::date)
+			  AND (s.deleted_at IS NULL OR m::date < date_trunc('month', s.deleted_at)::date)
+		)`
+	var serviceName *string
+	if request.ServiceName != nil {
+		pattern := likePattern(*request.ServiceName)
+		serviceName = &pattern
+	}
+	currency := currencyOrDefault(request.Currency)
+	exponent := money.Exponent(currency)
+	args := []interface{}{request.UserID, serviceName, fromDate, toDate, currency, exponent}
+
+	var (
+		missingCurrency string
+		missingMonth    time.Time
+	)
+	missingQuery := billed + `
+		SELECT currency, month FROM billed
+		WHERE rate IS NULL
+		ORDER BY month, currency
+		LIMIT 1`
+	err = conn.QueryRow(ctx, missingQuery, args...).Scan(&missingCurrency, &missingMonth)
+	switch {
+	case err == nil:
+		log.Warn("missing fx rate for total in storage layer", "from", missingCurrency, "to", currency, "month", missingMonth)
+		return nil, missingFXRate(missingCurrency, currency, missingMonth)
+	case !isNoRows(err):
+		log.Error("failed to check fx rates in storage layer", "error", err)
+		return nil, err
+	}
+
+	selectCols := append(append([]string{}, groupCols...),
+		"COALESCE(ROUND(SUM(charge)), 0)::bigint",
+		"COALESCE(ROUND(SUM(normalized)), 0)::bigint",
+		"COUNT(DISTINCT id)",
+	)
+	query := billed + fmt.Sprintf(`
+		SELECT %s
+		FROM billed`, strings.Join(selectCols, ", "))
+	if len(groupCols) > 0 {
+		query += fmt.Sprintf(`
+		GROUP BY %[1]s
+		ORDER BY %[1]s`, strings.Join(groupCols, ", "))
+	}
+
+	setQuery(span, query)
+
+	rows, err := conn.Query(ctx, query, args...)
+	if err != nil {
+		log.Error("failed to get total subscriptions price in storage layer", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	resp := TotalResponse{
+		Currency:        currency,
+		Total:           money.Amount{Exponent: exponent},
+		NormalizedTotal: money.Amount{Exponent: exponent},
+	}
+	for rows.Next() {
+		var (
+			bucket      = TotalBucket{Total: money.Amount{Exponent: exponent}, NormalizedTotal: money.Amount{Exponent: exponent}}
+			bucketMonth time.Time
+			serviceName string
+			userID      uuid.UUID
+		)
+		dest := make([]interface{}, 0, len(selectCols))
+		for _, col := range groupCols {
+			switch col {
+			case GroupByMonth:
+				dest = append(dest, &bucketMonth)
+			case GroupByServiceName:
+				dest = append(dest, &serviceName)
+			case GroupByUserID:
+				dest = append(dest, &userID)
+			}
+		}
+		dest = append(dest, &bucket.Total.Minor, &bucket.NormalizedTotal.Minor, &bucket.Count)
+
+		if err := rows.Scan(dest...); err != nil {
+			log.Error("failed to scan total row in storage layer", "error", err)
+			return nil, err
+		}
 
-	return total, nil
+		// The totals are the sums of the rounded buckets, so that the
+		// buckets always add up to them.
+		resp.Total.Minor += bucket.Total.Minor
+		resp.NormalizedTotal.Minor += bucket.NormalizedTotal.Minor
+		if len(groupCols) == 0 {
+			continue
+		}
+		for _, col := range groupCols {
+			switch col {
+			case GroupByMonth:
+				m := month.Format(bucketMonth)
+				bucket.Month = &m
+			case GroupByServiceName:
+				bucket.ServiceName = &serviceName
+			case GroupByUserID:
+				bucket.UserID = &userID
+			}
+		}
+		resp.Buckets = append(resp.Buckets, bucket)
+	}
+	if err := rows.Err(); err != nil {
+		log.Error("failed to read total rows in storage layer", "error", err)
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// redactArgs describes query arguments by type only, keeping user ids and
+// search terms out of the logs.
+func redactArgs(args []interface{}) []string {
+	redacted := make([]string, len(args))
+	for i, arg := range args {
+		redacted[i] = fmt.Sprintf("%T", arg)
+	}
+	return redacted
+}
+
+const subscriptionColumns = `id, user_id, service_name, price_minor, price_exponent, start_date, end_date, created_at, updated_at, version, billing_period_months, billing_anchor, currency, deleted_at`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// rowQuerier is implemented by both connections and transactions.
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
+// scanSubscription reads a row selected with subscriptionColumns.
+func scanSubscription(row rowScanner) (*GetInfoResponse, error) {
+	var (
+		sub       GetInfoResponse
+		startDate time.Time
+		endDate   *time.Time
+		anchor    *time.Time
+	)
+	err := row.Scan(&sub.ID, &sub.UserID, &sub.ServiceName, &sub.Price.Minor, &sub.Price.Exponent, &startDate, &endDate, &sub.CreatedAt, &sub.UpdatedAt, &sub.Version,
+		&sub.BillingPeriodMonths, &anchor, &sub.Currency, &sub.DeletedAt)
+	if err != nil {
+		return nil, err
+	}
+	if anchor != nil {
+		a := month.Format(*anchor)
+		sub.BillingAnchor = &a
+	}
+
+	sub.StartDate = month.Format(startDate)
+	if endDate != nil {
+		end := month.Format(*endDate)
+		sub.EndDate = &end
+	}
+	return &sub, nil
+}
+
+// missingOrChanged explains why a statement conditional on id and version
+// matched no rows: either the subscription is gone or its version moved on.
+func missingOrChanged(ctx context.Context, conn rowQuerier, id uuid.UUID, version *int64) error {
+	if version != nil {
+		var exists bool
+		err := conn.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM subscriptions WHERE id = $1 AND deleted_at IS NULL)`, id).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return fmt.Errorf("%w: subscription with id %s is no longer at version %d", ErrPreconditionFailed, id, *version)
+		}
+	}
+	return fmt.Errorf("%w: subscription with id %s", ErrNotFound, id)
+}
+
+// notDeleted explains why Restore matched no rows: either there is no such
+// subscription of the user or it is not deleted.
+func notDeleted(ctx context.Context, conn rowQuerier, id uuid.UUID, userID *uuid.UUID) error {
+	var exists bool
+	err := conn.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM subscriptions WHERE id = $1 AND ($2::uuid IS NULL OR user_id = $2))`,
+		id, userID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("%w: subscription with id %s is not deleted", ErrConflict, id)
+	}
+	return fmt.Errorf("%w: subscription with id %s", ErrNotFound, id)
 }
@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/azaliaz/subs-api/pkg/tracing"
+	"github.com/jackc/pgx/v4"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"time"
+)
+
+// Idempotency makes Create return the stored response when the same key is
+// used again before it expires.
+type Idempotency struct {
+	// Scope separates the keys of different callers.
+	Scope       string
+	Key         string
+	RequestHash string
+	ExpiresAt   time.Time
+}
+
+// claimIdempotencyKey records the key inside tx. When the key is already in
+// use, the response of the first request is returned instead; a key that
+// has expired is taken over as if it were new.
+func claimIdempotencyKey(ctx context.Context, tx pgx.Tx, key *Idempotency) (*CreateResponse, error) {
+	query := `
+		INSERT INTO idempotency_keys (scope, key, request_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (scope, key) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash,
+			response     = NULL,
+			created_at   = now(),
+			expires_at   = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= now()`
+	tag, err := tx.Exec(ctx, query, key.Scope, key.Key, key.RequestHash, key.ExpiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("claim idempotency key: %w", err)
+	}
+	if tag.RowsAffected() > 0 {
+		return nil, nil
+	}
+
+	var (
+		requestHash string
+		response    []byte
+	)
+	err = tx.QueryRow(ctx,
+		`SELECT request_hash, response FROM idempotency_keys WHERE scope = $1 AND key = $2`,
+		key.Scope, key.Key,
+	).Scan(&requestHash, &response)
+	if err != nil {
+		return nil, fmt.Errorf("read idempotency key: %w", err)
+	}
+	if requestHash != key.RequestHash {
+		return nil, fmt.Errorf("%w: key %q was used with a different request", ErrIdempotencyMismatch, key.Key)
+	}
+	if response == nil {
+		return nil, fmt.Errorf("%w: request with key %q is still in progress", ErrConflict, key.Key)
+	}
+
+	var resp CreateResponse
+	if err := json.Unmarshal(response, &resp); err != nil {
+		return nil, fmt.Errorf("decode idempotent response: %w", err)
+	}
+	resp.Replayed = true
+	return &resp, nil
+}
+
+func saveIdempotentResponse(ctx context.Context, tx pgx.Tx, key *Idempotency, resp *CreateResponse) error {
+	response, err := json.Marshal(resp)
+	if err != nil {
+		return fmt.Errorf("encode idempotent response: %w", err)
+	}
+	_, err = tx.Exec(ctx,
+		`UPDATE idempotency_keys SET response = $3 WHERE scope = $1 AND key = $2`,
+		key.Scope, key.Key, response,
+	)
+	if err != nil {
+		return fmt.Errorf("save idempotent response: %w", err)
+	}
+	return nil
+}
+
+// DeleteExpiredIdempotencyKeys removes keys whose TTL has passed and returns
+// how many were removed.
+func (r *Service) DeleteExpiredIdempotencyKeys(ctx context.Context) (_ int64, err error) {
+	ctx, span := startSpan(ctx, "DeleteExpiredIdempotencyKeys")
+	defer func() { tracing.End(span, err) }()
+	span.SetAttributes(semconv.DBCollectionName("idempotency_keys"))
+
+	log := r.requestLogger(ctx)
+
+	query := `DELETE FROM idempotency_keys WHERE expires_at <= now()`
+	setQuery(span, query)
+
+	tag, err := r.Pool().Exec(ctx, query)
+	if err != nil {
+		log.Error("failed to delete expired idempotency keys in storage layer", "error", err)
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"errors"
+	"github.com/azaliaz/subs-api/pkg/money"
+	"github.com/azaliaz/subs-api/pkg/month"
+	"strings"
+	"time"
+)
+
+const (
+	SortByPrice       = "price"
+	SortByStartDate   = "start_date"
+	SortByEndDate     = "end_date"
+	SortByServiceName = "service_name"
+	SortByCreatedAt   = "created_at"
+)
+
+const (
+	// StatusActive subscriptions have started and not yet ended.
+	StatusActive = "active"
+	// StatusEnded subscriptions ended before the month.
+	StatusEnded = "ended"
+	// StatusFuture subscriptions start after the month.
+	StatusFuture = "future"
+)
+
+// priceExpr is the price in effect in the current month in major units,
+// comparable across currencies with different exponents. It is kept on the
+// subscription, so sorting and filtering on it can use an index.
+const priceExpr = "s.current_price"
+
+// listSort describes how List orders by a field and how the field of the
+// last row on a page becomes the cursor of the next one.
+type listSort struct {
+	// expr is never NULL, so that rows can be compared with the cursor.
+	expr string
+	// typ is the SQL type the cursor value is cast to.
+	typ   string
+	key   func(sub *GetInfoResponse) string
+	valid func(value string) bool
+}
+
+// Open-ended subscriptions sort after every end date.
+var listSorts = map[string]listSort{
+	SortByPrice: {
+		expr: priceExpr,
+		typ:  "numeric",
+		key:  func(sub *GetInfoResponse) string { return sub.Price.String() },
+		valid: func(value string) bool {
+			_, err := money.Parse(value, money.MaxExponent)
+			return !errors.Is(err, money.ErrSyntax)
+		},
+	},
+	SortByStartDate: {
+		expr:  "start_date",
+		typ:   "date",
+		key:   func(sub *GetInfoResponse) string { return monthDate(sub.StartDate) },
+		valid: validDate,
+	},
+	SortByEndDate: {
+		expr: "COALESCE(end_date, 'infinity'::date)",
+		typ:  "date",
+		key: func(sub *GetInfoResponse) string {
+			if sub.EndDate == nil {
+				return "infinity"
+			}
+			return monthDate(*sub.EndDate)
+		},
+		valid: func(value string) bool { return value == "infinity" || validDate(value) },
+	},
+	SortByServiceName: {
+		expr:  "service_name",
+		typ:   "text",
+		key:   func(sub *GetInfoResponse) string { return sub.ServiceName },
+		valid: func(string) bool { return true },
+	},
+	SortByCreatedAt: {
+		expr:  "created_at",
+		typ:   "timestamptz",
+		key:   func(sub *GetInfoResponse) string { return sub.CreatedAt.Format(time.RFC3339Nano) },
+		valid: func(value string) bool { _, err := time.Parse(time.RFC3339Nano, value); return err == nil },
+	},
+}
+
+func monthDate(value string) string {
+	t, err := month.Parse(value)
+	if err != nil {
+		return ""
+	}
+	return t.Format(time.DateOnly)
+}
+
+func validDate(value string) bool {
+	_, err := time.Parse(time.DateOnly, value)
+	return err == nil
+}
+
+// likePattern matches value literally as a substring in ILIKE.
+func likePattern(value string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+	return "%" + escaped + "%"
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	poolAcquiredConnsDesc = prometheus.NewDesc("subs_api_db_pool_acquired_conns",
+		"Number of currently acquired connections in the pool.", nil, nil)
+	poolIdleConnsDesc = prometheus.NewDesc("subs_api_db_pool_idle_conns",
+		"Number of currently idle connections in the pool.", nil, nil)
+	poolTotalConnsDesc = prometheus.NewDesc("subs_api_db_pool_total_conns",
+		"Total number of connections currently in the pool.", nil, nil)
+	poolMaxConnsDesc = prometheus.NewDesc("subs_api_db_pool_max_conns",
+		"Maximum size of the pool.", nil, nil)
+	poolAcquireCountDesc = prometheus.NewDesc("subs_api_db_pool_acquire_total",
+		"Cumulative count of successful acquires from the pool.", nil, nil)
+	poolAcquireDurationDesc = prometheus.NewDesc("subs_api_db_pool_acquire_wait_seconds_total",
+		"Total time spent waiting for successful acquires from the pool.", nil, nil)
+	poolEmptyAcquireCountDesc = prometheus.NewDesc("subs_api_db_pool_empty_acquire_total",
+		"Cumulative count of acquires that had to wait for a connection.", nil, nil)
+	poolCanceledAcquireCountDesc = prometheus.NewDesc("subs_api_db_pool_canceled_acquire_total",
+		"Cumulative count of acquires cancelled by a context.", nil, nil)
+)
+
+// poolCollector exports pgxpool statistics of DB on every scrape.
+type poolCollector struct {
+	db *DB
+}
+
+func (c poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- poolAcquiredConnsDesc
+	ch <- poolIdleConnsDesc
+	ch <- poolTotalConnsDesc
+	ch <- poolMaxConnsDesc
+	ch <- poolAcquireCountDesc
+	ch <- poolAcquireDurationDesc
+	ch <- poolEmptyAcquireCountDesc
+	ch <- poolCanceledAcquireCountDesc
+}
+
+func (c poolCollector) Collect(ch chan<- prometheus.Metric) {
+	pool := c.db.Pool()
+	if pool == nil {
+		return
+	}
+	stat := pool.Stat()
+
+	ch <- prometheus.MustNewConstMetric(poolAcquiredConnsDesc, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(poolIdleConnsDesc, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(poolTotalConnsDesc, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(poolMaxConnsDesc, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(poolAcquireCountDesc, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolAcquireDurationDesc, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+	ch <- prometheus.MustNewConstMetric(poolEmptyAcquireCountDesc, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	ch <- prometheus.MustNewConstMetric(poolCanceledAcquireCountDesc, prometheus.CounterValue, float64(stat.CanceledAcquireCount()))
+}
+
+func registerPoolCollector(db *DB) error {
+	err := prometheus.Register(poolCollector{db: db})
+	var alreadyRegistered prometheus.AlreadyRegisteredError
+	if errors.As(err, &alreadyRegistered) {
+		return nil
+	}
+	return err
+}
@@ -7,6 +7,7 @@ package mocks
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	storage "github.com/azaliaz/subs-api/internal/storage"
 	gomock "github.com/golang/mock/gomock"
@@ -65,6 +66,35 @@ func (mr *MockSubscriptionsStorageMockRecorder) Delete(ctx, request interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockSubscriptionsStorage)(nil).Delete), ctx, request)
 }
 
+// DeleteExpiredIdempotencyKeys mocks base method.
+func (m *MockSubscriptionsStorage) DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredIdempotencyKeys", ctx)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteExpiredIdempotencyKeys indicates an expected call of DeleteExpiredIdempotencyKeys.
+func (mr *MockSubscriptionsStorageMockRecorder) DeleteExpiredIdempotencyKeys(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredIdempotencyKeys", reflect.TypeOf((*MockSubscriptionsStorage)(nil).DeleteExpiredIdempotencyKeys), ctx)
+}
+
+// DeleteFXRate mocks base method.
+func (m *MockSubscriptionsStorage) DeleteFXRate(ctx context.Context, key *storage.FXRateKey) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteFXRate", ctx, key)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteFXRate indicates an expected call of DeleteFXRate.
+func (mr *MockSubscriptionsStorageMockRecorder) DeleteFXRate(ctx, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteFXRate", reflect.TypeOf((*MockSubscriptionsStorage)(nil).DeleteFXRate), ctx, key)
+}
+
 // GetInfo mocks base method.
 func (m *MockSubscriptionsStorage) GetInfo(ctx context.Context, id uuid.UUID) (*storage.GetInfoResponse, error) {
 	m.ctrl.T.Helper()
@@ -81,10 +111,10 @@ func (mr *MockSubscriptionsStorageMockRecorder) GetInfo(ctx, id interface{}) *go
 }
 
 // GetTotalSubscriptionsPrice mocks base method.
-func (m *MockSubscriptionsStorage) GetTotalSubscriptionsPrice(ctx context.Context, request *storage.TotalRequest) (int, error) {
+func (m *MockSubscriptionsStorage) GetTotalSubscriptionsPrice(ctx context.Context, request *storage.TotalRequest) (*storage.TotalResponse, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "GetTotalSubscriptionsPrice", ctx, request)
-	ret0, _ := ret[0].(int)
+	ret0, _ := ret[0].(*storage.TotalResponse)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -110,6 +140,80 @@ func (mr *MockSubscriptionsStorageMockRecorder) List(ctx, request interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockSubscriptionsStorage)(nil).List), ctx, request)
 }
 
+// ListFXRates mocks base method.
+func (m *MockSubscriptionsStorage) ListFXRates(ctx context.Context, request *storage.FXRatesRequest) ([]storage.FXRate, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListFXRates", ctx, request)
+	ret0, _ := ret[0].([]storage.FXRate)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListFXRates indicates an expected call of ListFXRates.
+func (mr *MockSubscriptionsStorageMockRecorder) ListFXRates(ctx, request interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListFXRates", reflect.TypeOf((*MockSubscriptionsStorage)(nil).ListFXRates), ctx, request)
+}
+
+// ListPrices mocks base method.
+func (m *MockSubscriptionsStorage) ListPrices(ctx context.Context, id uuid.UUID) ([]storage.PriceChange, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPrices", ctx, id)
+	ret0, _ := ret[0].([]storage.PriceChange)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPrices indicates an expected call of ListPrices.
+func (mr *MockSubscriptionsStorageMockRecorder) ListPrices(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPrices", reflect.TypeOf((*MockSubscriptionsStorage)(nil).ListPrices), ctx, id)
+}
+
+// PurgeDeleted mocks base method.
+func (m *MockSubscriptionsStorage) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeDeleted", ctx, before)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeDeleted indicates an expected call of PurgeDeleted.
+func (mr *MockSubscriptionsStorageMockRecorder) PurgeDeleted(ctx, before interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeDeleted", reflect.TypeOf((*MockSubscriptionsStorage)(nil).PurgeDeleted), ctx, before)
+}
+
+// Restore mocks base method.
+func (m *MockSubscriptionsStorage) Restore(ctx context.Context, request *storage.RestoreRequest) (*storage.UpdateResponse, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Restore", ctx, request)
+	ret0, _ := ret[0].(*storage.UpdateResponse)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Restore indicates an expected call of Restore.
+func (mr *MockSubscriptionsStorageMockRecorder) Restore(ctx, request interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Restore", reflect.TypeOf((*MockSubscriptionsStorage)(nil).Restore), ctx, request)
+}
+
+// SaveFXRates mocks base method.
+func (m *MockSubscriptionsStorage) SaveFXRates(ctx context.Context, rates []storage.FXRate) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveFXRates", ctx, rates)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveFXRates indicates an expected call of SaveFXRates.
+func (mr *MockSubscriptionsStorageMockRecorder) SaveFXRates(ctx, rates interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveFXRates", reflect.TypeOf((*MockSubscriptionsStorage)(nil).SaveFXRates), ctx, rates)
+}
+
 // Update mocks base method.
 func (m *MockSubscriptionsStorage) Update(ctx context.Context, id uuid.UUID, req *storage.UpdateRequest) (*storage.UpdateResponse, error) {
 	m.ctrl.T.Helper()
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"strings"
+)
+
+// OverlapPolicy decides what Create and Update do when a subscription is
+// active in the same month as another subscription of the same user to the
+// same service.
+type OverlapPolicy string
+
+const (
+	OverlapOff    OverlapPolicy = "off"
+	OverlapWarn   OverlapPolicy = "warn"
+	OverlapReject OverlapPolicy = "reject"
+)
+
+// checkOverlaps applies policy to subscription id, which has already been
+// written in tx. Writers of the same user and service are serialized by an
+// advisory lock held until tx ends, so two overlapping subscriptions cannot
+// be committed side by side. A subscription that passes the reject policy is
+// marked with reject_overlaps, and from then on the subscriptions_no_overlap
+// constraint keeps it apart from every other one so marked, whoever writes
+// them. Writes that change its dates, service or user clear the mark until
+// it is checked again.
+func checkOverlaps(ctx context.Context, tx pgx.Tx, id uuid.UUID, policy OverlapPolicy) ([]uuid.UUID, error) {
+	if policy == "" || policy == OverlapOff {
+		return nil, nil
+	}
+
+	_, err := tx.Exec(ctx, `
+		SELECT pg_advisory_xact_lock(hashtextextended(user_id::text || '/' || lower(service_name), 0))
+		FROM subscriptions
+		WHERE id = $1`, id)
+	if err != nil {
+		return nil, fmt.Errorf("lock overlapping subscriptions: %w", err)
+	}
+
+	// Dates are stored as the first day of their month, so an inclusive
+	// range intersects another exactly when they share a month.
+	rows, err := tx.Query(ctx, `
+		SELECT o.id
+		FROM subscriptions s
+		JOIN subscriptions o
+		  ON o.user_id = s.user_id
+		 AND lower(o.service_name) = lower(s.service_name)
+		 AND daterange(o.start_date, o.end_date, '[]') && daterange(s.start_date, s.end_date, '[]')
+		 AND o.id <> s.id
+		 AND o.deleted_at IS NULL
+		WHERE s.id = $1
+		ORDER BY o.start_date, o.id`, id)
+	if err != nil {
+		return nil, fmt.Errorf("find overlapping subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	var overlaps []uuid.UUID
+	for rows.Next() {
+		var other uuid.UUID
+		if err := rows.Scan(&other); err != nil {
+			return nil, fmt.Errorf("scan overlapping subscription: %w", err)
+		}
+		overlaps = append(overlaps, other)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("find overlapping subscriptions: %w", err)
+	}
+
+	if len(overlaps) > 0 && policy == OverlapReject {
+		ids := make([]string, len(overlaps))
+		for i, other := range overlaps {
+			ids[i] = other.String()
+		}
+		return nil, fmt.Errorf("%w: subscription overlaps %s", ErrConflict, strings.Join(ids, ", "))
+	}
+	if policy == OverlapReject {
+		_, err := tx.Exec(ctx, `UPDATE subscriptions SET reject_overlaps = true WHERE id = $1`, id)
+		if isConflict(err) {
+			return nil, fmt.Errorf("%w: subscription overlaps another one: %w", ErrConflict, err)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("mark subscription as not overlapping: %w", err)
+		}
+	}
+	return overlaps, nil
+}
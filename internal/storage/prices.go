@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"github.com/azaliaz/subs-api/pkg/money"
+	"github.com/azaliaz/subs-api/pkg/month"
+	"github.com/azaliaz/subs-api/pkg/tracing"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"time"
+)
+
+const currentMonth = `date_trunc('month', current_date)::date`
+
+// subscriptionsFrom joins every subscription, aliased s, with the price it
+// has in the current month, so that price_minor, price_exponent and currency
+// can be selected and filtered on as if they were its columns.
+var subscriptionsFrom = `subscriptions s CROSS JOIN LATERAL ` + priceAt(currentMonth) + ` AS price`
+
+// priceAt selects the price of subscription s in effect in the month given
+// by the SQL expression m, with its currency: the latest entry of its
+// history from that month or before, or the first entry for the months
+// before the history starts.
+func priceAt(m string) string {
+	return `(
+		SELECT p.price_minor, p.price_exponent, p.currency
+		FROM subscription_prices p
+		WHERE p.subscription_id = s.id
+		ORDER BY p.effective_from > ` + m + `, abs(p.effective_from - ` + m + `)
+		LIMIT 1)`
+}
+
+// setCurrentPrice sets current_price of subscription s to the price in effect
+// in the current month and current_price_until to the month of the next
+// change, from which it is out of date.
+var setCurrentPrice = `
+	current_price = (SELECT price.price_minor / power(10::numeric, price.price_exponent)
+		FROM ` + priceAt(currentMonth) + ` AS price),
+	current_price_until = (SELECT min(p.effective_from) FROM subscription_prices p
+		WHERE p.subscription_id = s.id AND p.effective_from > ` + currentMonth + `)`
+
+// refreshCurrentPrices sets current_price again for the subscriptions whose
+// scheduled price has come into effect since it was last set.
+func refreshCurrentPrices(ctx context.Context, conn *pgxpool.Conn) error {
+	_, err := conn.Exec(ctx, `UPDATE subscriptions s SET `+setCurrentPrice+`
+		WHERE current_price_until <= `+currentMonth)
+	return err
+}
+
+// appendPrice records that subscription id costs price from the month
+// effectiveFrom on, or when it is nil from the current month, or from its
+// start_date if it has not started yet. The price is in currency, or when it
+// is nil in the currency of the price it follows, and has to be in its minor
+// units. Earlier entries keep their own currency, so a new one never changes
+// what past months cost. A price equal to the one already in effect in that
+// month adds nothing, and a second change in the same month replaces the
+// first. Once a subscription has a price, a new one may neither start
+// before the current month nor after its end_date; both are judged by the
+// clock of the database, which also picks the default month.
+func appendPrice(ctx context.Context, tx pgx.Tx, id uuid.UUID, price money.Amount, currency *string, effectiveFrom *time.Time) error {
+	var (
+		from                             time.Time
+		priceCurrency                    *string
+		inEffect, unchanged, past, ended bool
+	)
+	err := tx.QueryRow(ctx, `
+		SELECT s.month, COALESCE($3::text, in_effect.currency),
+			in_effect.price_minor IS NOT NULL,
+			COALESCE(in_effect.price_minor = $4 AND in_effect.price_exponent = $5
+				AND in_effect.currency = COALESCE($3::text, in_effect.currency), false),
+			s.month < `+currentMonth+`,
+			COALESCE(s.month > s.end_date, false)
+		FROM (
+			SELECT id, end_date, COALESCE($2::date, GREATEST(`+currentMonth+`, start_date)) AS month
+			FROM subscriptions
+			WHERE id = $1
+		) s
+		LEFT JOIN LATERAL `+priceAt("s.month")+` AS in_effect ON true`,
+		id, effectiveFrom, currency, price.Minor, price.Exponent).Scan(&from, &priceCurrency, &inEffect, &unchanged, &past, &ended)
+	if err != nil {
+		return fmt.Errorf("find price month: %w", err)
+	}
+	if priceCurrency == nil {
+		return fmt.Errorf("%w: currency is required for the first price", ErrValidation)
+	}
+	if unchanged {
+		return nil
+	}
+	if inEffect && past {
+		return fmt.Errorf("%w: price_effective_from must not be before the current month", ErrValidation)
+	}
+	if ended {
+		if effectiveFrom != nil {
+			return fmt.Errorf("%w: price_effective_from must not be after end_date", ErrValidation)
+		}
+		return fmt.Errorf("%w: the price of a subscription that has ended cannot change", ErrValidation)
+	}
+	if err := priceExponent(price, *priceCurrency); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO subscription_prices (subscription_id, effective_from, price_minor, price_exponent, currency)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (subscription_id, effective_from) DO UPDATE
+		SET price_minor = EXCLUDED.price_minor, price_exponent = EXCLUDED.price_exponent,
+			currency = EXCLUDED.currency, created_at = now()`,
+		id, from, price.Minor, price.Exponent, *priceCurrency)
+	if err != nil {
+		return fmt.Errorf("append price: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE subscriptions s SET `+setCurrentPrice+` WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("set current price: %w", err)
+	}
+	return nil
+}
+
+func (r *Service) ListPrices(ctx context.Context, id uuid.UUID) (_ []PriceChange, err error) {
+	ctx, span := startSpan(ctx, "ListPrices")
+	defer func() { tracing.End(span, err) }()
+
+	log := r.requestLogger(ctx)
+
+	if id == uuid.Nil {
+		log.Error("invalid subscription id in storage layer")
+		return nil, fmt.Errorf("%w: id is required", ErrValidation)
+	}
+
+	conn, err := r.Pool().Acquire(ctx)
+	if err != nil {
+		log.Error("failed to acquire DB connection", "error", err)
+		return nil, err
+	}
+	defer conn.Release()
+
+	query := `SELECT effective_from, price_minor, price_exponent, currency
+         FROM subscription_prices
+         WHERE subscription_id = $1
+         ORDER BY effective_from`
+	setQuery(span, query)
+
+	rows, err := conn.Query(ctx, query, id)
+	if err != nil {
+		log.Error("failed to list prices in storage layer", "error", err, "id", id)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var prices []PriceChange
+	for rows.Next() {
+		var (
+			change        PriceChange
+			effectiveFrom time.Time
+		)
+		if err := rows.Scan(&effectiveFrom, &change.Price.Minor, &change.Price.Exponent, &change.Currency); err != nil {
+			log.Error("failed to scan price row in storage layer", "error", err)
+			return nil, err
+		}
+		change.EffectiveFrom = month.Format(effectiveFrom)
+		prices = append(prices, change)
+	}
+	if err := rows.Err(); err != nil {
+		log.Error("failed to read price rows in storage layer", "error", err)
+		return nil, err
+	}
+	return prices, nil
+}
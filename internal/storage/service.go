@@ -2,10 +2,16 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"github.com/azaliaz/subs-api/migrations"
+	"github.com/azaliaz/subs-api/pkg/logging"
+	"github.com/azaliaz/subs-api/pkg/money"
+	"github.com/azaliaz/subs-api/pkg/optional"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"log/slog"
+	"time"
 )
 
 //go:generate mockgen -source=service.go -destination=./mocks/service_mock.go -package=mocks
@@ -16,60 +22,188 @@ type SubscriptionsStorage interface {
 	List(ctx context.Context, request *ListRequest) (*ListResponse, error)
 	Update(ctx context.Context, id uuid.UUID, req *UpdateRequest) (*UpdateResponse, error)
 	Delete(ctx context.Context, request *DeleteRequest) error
-	GetTotalSubscriptionsPrice(ctx context.Context, request *TotalRequest) (int, error)
+	Restore(ctx context.Context, request *RestoreRequest) (*UpdateResponse, error)
+	PurgeDeleted(ctx context.Context, before time.Time) (int64, error)
+	GetTotalSubscriptionsPrice(ctx context.Context, request *TotalRequest) (*TotalResponse, error)
+	DeleteExpiredIdempotencyKeys(ctx context.Context) (int64, error)
+	ListFXRates(ctx context.Context, request *FXRatesRequest) ([]FXRate, error)
+	SaveFXRates(ctx context.Context, rates []FXRate) error
+	DeleteFXRate(ctx context.Context, key *FXRateKey) error
+	ListPrices(ctx context.Context, id uuid.UUID) ([]PriceChange, error)
 }
 type CreateRequest struct {
 	UserID      uuid.UUID `json:"user_id"`
 	ServiceName string    `json:"service_name"`
-	Price       int       `json:"price"`
-	StartDate   string    `json:"start_date"`
-	EndDate     *string   `json:"end_date"`
+	// Price is in minor units of Currency, with its money.Exponent.
+	Price money.Amount `json:"price"`
+	// Currency is an ISO 4217 code, DefaultCurrency when empty.
+	Currency  string  `json:"currency"`
+	StartDate string  `json:"start_date"`
+	EndDate   *string `json:"end_date"`
+	// BillingPeriodMonths is the number of months price is charged for,
+	// one when zero.
+	BillingPeriodMonths int           `json:"billing_period_months"`
+	BillingAnchor       *string       `json:"billing_anchor"`
+	Idempotency         *Idempotency  `json:"-"`
+	Overlap             OverlapPolicy `json:"-"`
 }
 type CreateResponse struct {
 	ID uuid.UUID `json:"id"`
+	// Overlaps lists the subscriptions the new one overlaps with when the
+	// overlap policy only warns.
+	Overlaps []uuid.UUID `json:"overlaps,omitempty"`
+	// Replayed is set when the response was stored by an earlier request
+	// with the same idempotency key.
+	Replayed bool `json:"-"`
 }
 
 type GetInfoRequest struct {
 	ID uuid.UUID `json:"id"`
 }
 type GetInfoResponse struct {
-	ID          uuid.UUID `json:"id"`
-	UserID      uuid.UUID `json:"user_id"`
-	ServiceName string    `json:"service_name"`
-	Price       int       `json:"price"`
-	StartDate   string    `json:"start_date"`
-	EndDate     *string   `json:"end_date"`
+	ID          uuid.UUID    `json:"id"`
+	UserID      uuid.UUID    `json:"user_id"`
+	ServiceName string       `json:"service_name"`
+	Price       money.Amount `json:"price"`
+	Currency    string       `json:"currency"`
+	StartDate   string       `json:"start_date"`
+	EndDate     *string      `json:"end_date"`
+	// Price is charged every BillingPeriodMonths months, counted from
+	// BillingAnchor or, when it is nil, from StartDate.
+	BillingPeriodMonths int       `json:"billing_period_months"`
+	BillingAnchor       *string   `json:"billing_anchor"`
+	CreatedAt           time.Time `json:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at"`
+	Version             int64     `json:"version"`
+	// DeletedAt is set on a deleted subscription until it is purged.
+	DeletedAt *time.Time `json:"deleted_at"`
 }
 
 type ListRequest struct {
-	UserID      *uuid.UUID `json:"user_id"`
-	ServiceName *string    `json:"service_name"`
-	From        *string    `json:"from"`
-	To          *string    `json:"to"`
-	Limit       *int       `json:"limit"`
-	Offset      *int       `json:"offset"`
+	UserIDs     []uuid.UUID `json:"user_id"`
+	ServiceName *string     `json:"service_name"`
+	// ServiceNameExact matches ServiceName as a whole instead of as a
+	// case-insensitive substring.
+	ServiceNameExact bool    `json:"service_name_exact"`
+	From             *string `json:"from"`
+	To               *string `json:"to"`
+	// PriceMin and PriceMax bound the price in major units of its currency.
+	PriceMin *money.Decimal `json:"price_min"`
+	PriceMax *money.Decimal `json:"price_max"`
+	// Status is one of the Status constants evaluated in the AsOf month.
+	Status string  `json:"status"`
+	AsOf   *string `json:"as_of"`
+	// Sort is one of the SortBy constants, start_date when empty.
+	Sort   string `json:"sort"`
+	Desc   bool   `json:"desc"`
+	Limit  *int   `json:"limit"`
+	Offset *int   `json:"offset"`
+	// IncludeDeleted lists deleted subscriptions along with the others.
+	IncludeDeleted bool `json:"include_deleted"`
+	// After continues the listing past the given subscription instead of
+	// skipping Offset rows.
+	After *ListCursor `json:"-"`
+}
+
+// ListCursor is a position in the (sort key, id) order of List. Value is
+// the sort key of the subscription in the text form PostgreSQL accepts.
+type ListCursor struct {
+	Value string
+	ID    uuid.UUID
 }
 type ListResponse struct {
 	Subscriptions []GetInfoResponse
+	// Total counts every subscription matching the filters, on all pages.
+	Total   int
+	HasMore bool
+	// Next is the cursor of the following page when HasMore is set.
+	Next *ListCursor
 }
+
+// UpdateRequest changes only the fields that are set; a null EndDate makes
+// the subscription open-ended again.
 type UpdateRequest struct {
-	ServiceName *string `json:"service_name"`
-	Price       *int    `json:"price"`
-	StartDate   *string `json:"start_date"`
-	EndDate     *string `json:"end_date"`
+	UserID      optional.Value[uuid.UUID] `json:"user_id"`
+	ServiceName optional.Value[string]    `json:"service_name"`
+	// Price is added to the price history from the PriceEffectiveFrom
+	// month, by default the current one or start_date if it is later, in
+	// Currency or, when it is unset, in the currency of the price it
+	// follows. Currency can only be set together with Price: earlier
+	// entries keep the currency they were charged in.
+	Price              optional.Value[money.Amount] `json:"price"`
+	PriceEffectiveFrom optional.Value[string]       `json:"price_effective_from"`
+	Currency           optional.Value[string]       `json:"currency"`
+	StartDate          optional.Value[string]       `json:"start_date"`
+	EndDate            optional.Value[string]       `json:"end_date"`
+	// A null BillingAnchor charges the subscription from its start_date.
+	BillingPeriodMonths optional.Value[int]    `json:"billing_period_months"`
+	BillingAnchor       optional.Value[string] `json:"billing_anchor"`
+	// Version, when set, makes the update conditional on the stored version.
+	Version *int64        `json:"-"`
+	Overlap OverlapPolicy `json:"-"`
 }
 
+// UpdateResponse is the subscription as stored after the update.
 type UpdateResponse struct {
-	Updated bool `json:"updated"`
+	GetInfoResponse
+	Overlaps []uuid.UUID `json:"overlaps,omitempty"`
 }
+
+// PriceChange is an entry of the price history of a subscription: Price
+// applies from the EffectiveFrom month until the next entry.
+type PriceChange struct {
+	EffectiveFrom string       `json:"effective_from"`
+	Price         money.Amount `json:"price"`
+	Currency      string       `json:"currency"`
+}
+
+// DeleteRequest marks a subscription as deleted; it is left out of every
+// read but the listing of deleted ones until it is restored or purged.
 type DeleteRequest struct {
-	ID uuid.UUID `json:"id"`
+	ID      uuid.UUID `json:"id"`
+	Version *int64    `json:"-"`
+}
+
+// RestoreRequest undoes the deletion of a subscription. UserID, when set,
+// restricts the restore to subscriptions of that user.
+type RestoreRequest struct {
+	ID      uuid.UUID     `json:"id"`
+	UserID  *uuid.UUID    `json:"user_id"`
+	Overlap OverlapPolicy `json:"-"`
 }
+
+const (
+	GroupByMonth       = "month"
+	GroupByServiceName = "service_name"
+	GroupByUserID      = "user_id"
+)
+
 type TotalRequest struct {
 	UserID      *uuid.UUID `json:"user_id"`
 	ServiceName *string    `json:"service_name"`
 	From        string     `json:"from"`
 	To          string     `json:"to"`
+	GroupBy     []string   `json:"group_by"`
+	// Currency is the currency the totals are converted into,
+	// DefaultCurrency when empty.
+	Currency string `json:"currency"`
+}
+type TotalBucket struct {
+	Month       *string    `json:"month,omitempty"`
+	ServiceName *string    `json:"service_name,omitempty"`
+	UserID      *uuid.UUID `json:"user_id,omitempty"`
+	// Total is what is charged in the window; NormalizedTotal spreads the
+	// price of every subscription evenly over its billing period instead.
+	// Both are rounded to minor units of the requested currency.
+	Total           money.Amount `json:"total"`
+	NormalizedTotal money.Amount `json:"normalized_total"`
+	Count           int          `json:"count"`
+}
+type TotalResponse struct {
+	Currency        string        `json:"currency"`
+	Total           money.Amount  `json:"total"`
+	NormalizedTotal money.Amount  `json:"normalized_total"`
+	Buckets         []TotalBucket `json:"buckets,omitempty"`
 }
 
 func NewService(db *DB, logger *slog.Logger) *Service {
@@ -81,6 +215,12 @@ type Service struct {
 	logger *slog.Logger
 }
 
+// requestLogger returns the request-scoped logger from ctx, falling back to
+// the storage logger.
+func (r *Service) requestLogger(ctx context.Context) *slog.Logger {
+	return logging.FromContext(ctx, r.log)
+}
+
 func NewDB(config *Config, logEntry *slog.Logger) *DB {
 	return &DB{
 		config: config,
@@ -89,16 +229,23 @@ func NewDB(config *Config, logEntry *slog.Logger) *DB {
 }
 
 type DB struct {
-	config *Config
-	log    *slog.Logger
-	pool   *pgxpool.Pool
-	cancel func()
+	config        *Config
+	log           *slog.Logger
+	pool          *pgxpool.Pool
+	cancel        func()
+	schemaVersion uint
 }
 
 func (r *DB) Init() error {
 	ctx, cancel := context.WithCancel(context.Background())
 	r.cancel = cancel
 
+	schemaVersion, err := migrations.Version()
+	if err != nil {
+		return fmt.Errorf("error on reading embedded migrations: %w", err)
+	}
+	r.schemaVersion = schemaVersion
+
 	poolCfg, err := pgxpool.ParseConfig(r.config.dsnPostgres(r.log))
 	if err != nil {
 		return fmt.Errorf("error on parsing rw storage config: %w", err)
@@ -115,11 +262,44 @@ func (r *DB) Init() error {
 
 	r.pool = pool
 
+	if err := registerPoolCollector(r); err != nil {
+		return fmt.Errorf("error on registering storage metrics: %w", err)
+	}
+
 	r.log.Info("connected to postgres")
 	return nil
 }
 
-func (r *DB) Run(_ context.Context) {
+func (r *DB) Run(_ context.Context) error {
+	return nil
+}
+
+// Health pings the pool and checks that the database schema is migrated to
+// the version embedded into the binary.
+func (r *DB) Health(ctx context.Context) error {
+	if r.pool == nil {
+		return errors.New("storage is not connected")
+	}
+	if err := r.pool.Ping(ctx); err != nil {
+		return fmt.Errorf("ping postgres: %w", err)
+	}
+
+	var (
+		version int64
+		dirty   bool
+	)
+	err := r.pool.QueryRow(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+	if dirty {
+		return fmt.Errorf("schema version %d is dirty", version)
+	}
+	if version != int64(r.schemaVersion) {
+		return fmt.Errorf("schema version %d does not match expected %d", version, r.schemaVersion)
+	}
+
+	return nil
 }
 
 func (r *DB) Stop() {
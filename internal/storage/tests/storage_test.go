@@ -5,7 +5,11 @@ import (
 	"fmt"
 	"github.com/azaliaz/subs-api/internal/storage"
 	"github.com/azaliaz/subs-api/migrations"
+	"github.com/azaliaz/subs-api/pkg/money"
+	"github.com/azaliaz/subs-api/pkg/month"
+	"github.com/azaliaz/subs-api/pkg/optional"
 	"github.com/google/uuid"
+	"github.com/jackc/pgconn"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
@@ -24,7 +28,7 @@ func (s *RepositoryTestSuite) TestCreateSubscription() {
 
 	userID := uuid.New()
 	serviceName := "Netflix"
-	price := 10
+	price := rub(10)
 	startDate := "09-2025"
 	endDate := "12-2025"
 
@@ -112,6 +116,93 @@ func (s *RepositoryTestSuite) TestCreateSubscription() {
 		assert.Nil(t, resp)
 	})
 
+	s.T().Run("Replay create with the same idempotency key", func(t *testing.T) {
+		prepare()
+
+		key := &storage.Idempotency{
+			Scope:       userID.String(),
+			Key:         "replay",
+			RequestHash: "hash",
+			ExpiresAt:   time.Now().Add(time.Hour),
+		}
+		req := &storage.CreateRequest{
+			UserID:      userID,
+			ServiceName: serviceName,
+			Price:       price,
+			StartDate:   startDate,
+			Idempotency: key,
+		}
+
+		first, err := s.repo.Create(ctx, req)
+		require.NoError(t, err)
+		assert.False(t, first.Replayed)
+
+		second, err := s.repo.Create(ctx, req)
+		require.NoError(t, err)
+		assert.True(t, second.Replayed)
+		assert.Equal(t, first.ID, second.ID)
+
+		list, err := s.repo.List(ctx, &storage.ListRequest{UserIDs: []uuid.UUID{userID}})
+		require.NoError(t, err)
+		assert.Len(t, list.Subscriptions, 1)
+	})
+
+	s.T().Run("Reuse idempotency key with a different request", func(t *testing.T) {
+		prepare()
+
+		key := &storage.Idempotency{
+			Scope:       userID.String(),
+			Key:         "mismatch",
+			RequestHash: "hash",
+			ExpiresAt:   time.Now().Add(time.Hour),
+		}
+		req := &storage.CreateRequest{
+			UserID:      userID,
+			ServiceName: serviceName,
+			Price:       price,
+			StartDate:   startDate,
+			Idempotency: key,
+		}
+		_, err := s.repo.Create(ctx, req)
+		require.NoError(t, err)
+
+		other := *key
+		other.RequestHash = "other"
+		req.Idempotency = &other
+		resp, err := s.repo.Create(ctx, req)
+		assert.ErrorIs(t, err, storage.ErrIdempotencyMismatch)
+		assert.Nil(t, resp)
+	})
+
+	s.T().Run("Expired idempotency key is reused and cleaned up", func(t *testing.T) {
+		prepare()
+
+		key := &storage.Idempotency{
+			Scope:       userID.String(),
+			Key:         "expired",
+			RequestHash: "hash",
+			ExpiresAt:   time.Now().Add(-time.Minute),
+		}
+		req := &storage.CreateRequest{
+			UserID:      userID,
+			ServiceName: serviceName,
+			Price:       price,
+			StartDate:   startDate,
+			Idempotency: key,
+		}
+		first, err := s.repo.Create(ctx, req)
+		require.NoError(t, err)
+
+		second, err := s.repo.Create(ctx, req)
+		require.NoError(t, err)
+		assert.False(t, second.Replayed)
+		assert.NotEqual(t, first.ID, second.ID)
+
+		deleted, err := s.repo.DeleteExpiredIdempotencyKeys(ctx)
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, deleted, int64(1))
+	})
+
 	clear()
 }
 func (s *RepositoryTestSuite) TestGetInfo() {
@@ -120,7 +211,7 @@ func (s *RepositoryTestSuite) TestGetInfo() {
 	userID := uuid.New()
 	subscriptionID := uuid.New()
 	serviceName := "Netflix"
-	price := 15
+	price := rub(15)
 	startDate := "09-2025"
 	endDate := "12-2025"
 
@@ -133,12 +224,12 @@ func (s *RepositoryTestSuite) TestGetInfo() {
 		require.NoError(s.T(), err)
 
 		_, err = conn.Exec(ctx,
-			`INSERT INTO subscriptions (id, user_id, service_name, price, start_date, end_date)
-			 VALUES ($1, $2, $3, $4, $5, $6)`,
+			insertSubscription,
 			subscriptionID,
 			userID,
 			serviceName,
-			price,
+			price.Minor,
+			price.Exponent,
 			fmt.Sprintf("%s-%s-01", strings.Split(startDate, "-")[1], strings.Split(startDate, "-")[0]),
 			fmt.Sprintf("%s-%s-01", strings.Split(endDate, "-")[1], strings.Split(endDate, "-")[0]),
 		)
@@ -208,22 +299,22 @@ func (s *RepositoryTestSuite) TestListSubscriptions() {
 			id          uuid.UUID
 			userID      uuid.UUID
 			serviceName string
-			price       int
+			price       money.Amount
 			start       string
 			end         string
 		}{
-			{sub1ID, userID, service1, 10, start1, end1},
-			{sub2ID, otherUserID, service2, 15, start2, end2},
+			{sub1ID, userID, service1, rub(10), start1, end1},
+			{sub2ID, otherUserID, service2, rub(15), start2, end2},
 		}
 
 		for _, sub := range subs {
 			_, err := conn.Exec(ctx,
-				`INSERT INTO subscriptions (id, user_id, service_name, price, start_date, end_date)
-				 VALUES ($1, $2, $3, $4, $5, $6)`,
+				insertSubscription,
 				sub.id,
 				sub.userID,
 				sub.serviceName,
-				sub.price,
+				sub.price.Minor,
+				sub.price.Exponent,
 				fmt.Sprintf("%s-%s-01", strings.Split(sub.start, "-")[1], strings.Split(sub.start, "-")[0]),
 				fmt.Sprintf("%s-%s-01", strings.Split(sub.end, "-")[1], strings.Split(sub.end, "-")[0]),
 			)
@@ -247,7 +338,7 @@ func (s *RepositoryTestSuite) TestListSubscriptions() {
 	})
 
 	s.T().Run("Filter by UserID", func(t *testing.T) {
-		req := &storage.ListRequest{UserID: &userID}
+		req := &storage.ListRequest{UserIDs: []uuid.UUID{userID}}
 		resp, err := s.repo.List(ctx, req)
 		require.NoError(t, err)
 		require.Len(t, resp.Subscriptions, 1)
@@ -286,6 +377,172 @@ func (s *RepositoryTestSuite) TestListSubscriptions() {
 		assert.Nil(t, resp)
 	})
 
+	s.T().Run("Page through subscriptions starting in the same month", func(t *testing.T) {
+		prepare()
+		for i := 0; i < 3; i++ {
+			_, err := s.repo.Create(ctx, &storage.CreateRequest{
+				UserID:      userID,
+				ServiceName: fmt.Sprintf("Service %d", i),
+				Price:       rub(10),
+				StartDate:   start1,
+			})
+			require.NoError(t, err)
+		}
+
+		limit := 2
+		var (
+			seen  []uuid.UUID
+			after *storage.ListCursor
+		)
+		for page := 0; page < 3; page++ {
+			resp, err := s.repo.List(ctx, &storage.ListRequest{Limit: &limit, After: after})
+			require.NoError(t, err)
+			assert.Equal(t, 5, resp.Total)
+			for _, sub := range resp.Subscriptions {
+				seen = append(seen, sub.ID)
+			}
+			if !resp.HasMore {
+				break
+			}
+			after = resp.Next
+		}
+
+		require.Len(t, seen, 5)
+		unique := map[uuid.UUID]bool{}
+		for _, id := range seen {
+			unique[id] = true
+		}
+		assert.Len(t, unique, 5)
+	})
+
+	s.T().Run("Sort by price descending across pages", func(t *testing.T) {
+		prepare()
+		limit := 1
+		first, err := s.repo.List(ctx, &storage.ListRequest{Sort: storage.SortByPrice, Desc: true, Limit: &limit})
+		require.NoError(t, err)
+		require.Len(t, first.Subscriptions, 1)
+		assert.Equal(t, rub(15), first.Subscriptions[0].Price)
+		require.NotNil(t, first.Next)
+
+		second, err := s.repo.List(ctx, &storage.ListRequest{Sort: storage.SortByPrice, Desc: true, Limit: &limit, After: first.Next})
+		require.NoError(t, err)
+		require.Len(t, second.Subscriptions, 1)
+		assert.Equal(t, rub(10), second.Subscriptions[0].Price)
+		assert.False(t, second.HasMore)
+	})
+
+	s.T().Run("Sort by a scheduled price once it is in effect", func(t *testing.T) {
+		prepare()
+		conn, err := s.db.Pool().Acquire(ctx)
+		require.NoError(t, err)
+		// The change was scheduled before this month began.
+		defer conn.Release()
+		_, err = conn.Exec(ctx, `
+			INSERT INTO subscription_prices (subscription_id, effective_from, price_minor, price_exponent, currency)
+			VALUES ($1, date_trunc('month', current_date), 2000, 2, 'RUB')`, sub1ID)
+		require.NoError(t, err)
+		_, err = conn.Exec(ctx, `UPDATE subscriptions SET current_price_until = date_trunc('month', current_date) WHERE id = $1`, sub1ID)
+		require.NoError(t, err)
+
+		limit := 1
+		resp, err := s.repo.List(ctx, &storage.ListRequest{Sort: storage.SortByPrice, Desc: true, Limit: &limit})
+		require.NoError(t, err)
+		require.Len(t, resp.Subscriptions, 1)
+		assert.Equal(t, sub1ID, resp.Subscriptions[0].ID)
+		assert.Equal(t, rub(20), resp.Subscriptions[0].Price)
+
+		priceMin := money.Decimal("20")
+		resp, err = s.repo.List(ctx, &storage.ListRequest{PriceMin: &priceMin})
+		require.NoError(t, err)
+		assert.Equal(t, 1, resp.Total)
+	})
+
+	s.T().Run("Open-ended subscriptions sort last by end date", func(t *testing.T) {
+		prepare()
+		_, err := s.repo.Create(ctx, &storage.CreateRequest{
+			UserID:      userID,
+			ServiceName: "Open",
+			Price:       rub(5),
+			StartDate:   start1,
+		})
+		require.NoError(t, err)
+
+		limit := 2
+		first, err := s.repo.List(ctx, &storage.ListRequest{Sort: storage.SortByEndDate, Limit: &limit})
+		require.NoError(t, err)
+		require.Len(t, first.Subscriptions, 2)
+		assert.Equal(t, end2, *first.Subscriptions[0].EndDate)
+		assert.Equal(t, end1, *first.Subscriptions[1].EndDate)
+
+		second, err := s.repo.List(ctx, &storage.ListRequest{Sort: storage.SortByEndDate, Limit: &limit, After: first.Next})
+		require.NoError(t, err)
+		require.Len(t, second.Subscriptions, 1)
+		assert.Nil(t, second.Subscriptions[0].EndDate)
+	})
+
+	s.T().Run("Filter by several users, price range and status", func(t *testing.T) {
+		prepare()
+		resp, err := s.repo.List(ctx, &storage.ListRequest{UserIDs: []uuid.UUID{userID, otherUserID}})
+		require.NoError(t, err)
+		assert.Len(t, resp.Subscriptions, 2)
+
+		priceMin := money.Decimal("12.50")
+		resp, err = s.repo.List(ctx, &storage.ListRequest{PriceMin: &priceMin})
+		require.NoError(t, err)
+		require.Len(t, resp.Subscriptions, 1)
+		assert.Equal(t, sub2ID, resp.Subscriptions[0].ID)
+
+		asOf := "12-2025"
+		resp, err = s.repo.List(ctx, &storage.ListRequest{Status: storage.StatusEnded, AsOf: &asOf})
+		require.NoError(t, err)
+		require.Len(t, resp.Subscriptions, 1)
+		assert.Equal(t, sub2ID, resp.Subscriptions[0].ID)
+
+		resp, err = s.repo.List(ctx, &storage.ListRequest{Status: storage.StatusActive, AsOf: &asOf})
+		require.NoError(t, err)
+		require.Len(t, resp.Subscriptions, 1)
+		assert.Equal(t, sub1ID, resp.Subscriptions[0].ID)
+
+		asOf = "09-2025"
+		resp, err = s.repo.List(ctx, &storage.ListRequest{Status: storage.StatusFuture, AsOf: &asOf})
+		require.NoError(t, err)
+		require.Len(t, resp.Subscriptions, 1)
+		assert.Equal(t, sub2ID, resp.Subscriptions[0].ID)
+	})
+
+	s.T().Run("Exact and substring service match", func(t *testing.T) {
+		prepare()
+		name := "Net"
+		resp, err := s.repo.List(ctx, &storage.ListRequest{ServiceName: &name})
+		require.NoError(t, err)
+		assert.Len(t, resp.Subscriptions, 1)
+
+		resp, err = s.repo.List(ctx, &storage.ListRequest{ServiceName: &name, ServiceNameExact: true})
+		require.NoError(t, err)
+		assert.Empty(t, resp.Subscriptions)
+
+		wildcard := "%"
+		resp, err = s.repo.List(ctx, &storage.ListRequest{ServiceName: &wildcard})
+		require.NoError(t, err)
+		assert.Empty(t, resp.Subscriptions)
+	})
+
+	s.T().Run("Offset page reports more results", func(t *testing.T) {
+		prepare()
+		limit, offset := 1, 0
+		resp, err := s.repo.List(ctx, &storage.ListRequest{Limit: &limit, Offset: &offset})
+		require.NoError(t, err)
+		require.Len(t, resp.Subscriptions, 1)
+		assert.True(t, resp.HasMore)
+		assert.Equal(t, 2, resp.Total)
+
+		offset = 1
+		resp, err = s.repo.List(ctx, &storage.ListRequest{Limit: &limit, Offset: &offset})
+		require.NoError(t, err)
+		require.Len(t, resp.Subscriptions, 1)
+		assert.False(t, resp.HasMore)
+	})
+
 	clear()
 }
 func (s *RepositoryTestSuite) TestUpdateSubscription() {
@@ -294,7 +551,7 @@ func (s *RepositoryTestSuite) TestUpdateSubscription() {
 	userID := uuid.New()
 	subID := uuid.New()
 	service := "Netflix"
-	price := 10
+	price := rub(10)
 	start := "09-2025"
 	end := "12-2025"
 
@@ -307,12 +564,12 @@ func (s *RepositoryTestSuite) TestUpdateSubscription() {
 		require.NoError(s.T(), err)
 
 		_, err = conn.Exec(ctx,
-			`INSERT INTO subscriptions (id, user_id, service_name, price, start_date, end_date)
-			 VALUES ($1, $2, $3, $4, $5, $6)`,
+			insertSubscription,
 			subID,
 			userID,
 			service,
-			price,
+			price.Minor,
+			price.Exponent,
 			fmt.Sprintf("%s-%s-01", strings.Split(start, "-")[1], strings.Split(start, "-")[0]),
 			fmt.Sprintf("%s-%s-01", strings.Split(end, "-")[1], strings.Split(end, "-")[0]),
 		)
@@ -331,24 +588,32 @@ func (s *RepositoryTestSuite) TestUpdateSubscription() {
 		prepare()
 
 		newService := "HBO Max"
-		newPrice := 20
+		newPrice := rub(20)
 		newStart := "10-2025"
-		newEnd := "01-2026"
+		newEnd := "12-2099"
 
 		req := &storage.UpdateRequest{
-			ServiceName: &newService,
-			Price:       &newPrice,
-			StartDate:   &newStart,
-			EndDate:     &newEnd,
+			ServiceName: optional.Of(newService),
+			Price:       optional.Of(newPrice),
+			StartDate:   optional.Of(newStart),
+			EndDate:     optional.Of(newEnd),
 		}
 
+		before, err := s.repo.GetInfo(ctx, subID)
+		require.NoError(t, err)
+
 		resp, err := s.repo.Update(ctx, subID, req)
 		require.NoError(t, err)
 		require.NotNil(t, resp)
-		assert.True(t, resp.Updated)
+		assert.Equal(t, subID, resp.ID)
+		assert.Equal(t, newService, resp.ServiceName)
+		assert.Equal(t, &newEnd, resp.EndDate)
+		assert.Equal(t, before.CreatedAt, resp.CreatedAt)
+		assert.True(t, resp.UpdatedAt.After(before.UpdatedAt))
 
 		info, err := s.repo.GetInfo(ctx, subID)
 		require.NoError(t, err)
+		assert.Equal(t, resp.UpdatedAt, info.UpdatedAt)
 		assert.Equal(t, newService, info.ServiceName)
 		assert.Equal(t, newPrice, info.Price)
 		assert.Equal(t, newStart, info.StartDate)
@@ -356,24 +621,64 @@ func (s *RepositoryTestSuite) TestUpdateSubscription() {
 	})
 
 	s.T().Run("Update partial fields", func(t *testing.T) {
-		newPrice := 25
+		newPrice := rub(25)
 		req := &storage.UpdateRequest{
-			ServiceName: nil,
-			Price:       &newPrice,
+			Price: optional.Of(newPrice),
 		}
 
 		resp, err := s.repo.Update(ctx, subID, req)
 		require.NoError(t, err)
-		assert.True(t, resp.Updated)
+		assert.Equal(t, newPrice, resp.Price)
 
 		info, err := s.repo.GetInfo(ctx, subID)
 		require.NoError(t, err)
 		assert.Equal(t, newPrice, info.Price)
+		assert.Equal(t, "HBO Max", info.ServiceName)
+		assert.NotNil(t, info.EndDate)
+	})
+
+	s.T().Run("Clear end date", func(t *testing.T) {
+		req := &storage.UpdateRequest{
+			EndDate: optional.Null[string](),
+		}
+
+		resp, err := s.repo.Update(ctx, subID, req)
+		require.NoError(t, err)
+		assert.Nil(t, resp.EndDate)
+
+		info, err := s.repo.GetInfo(ctx, subID)
+		require.NoError(t, err)
+		assert.Nil(t, info.EndDate)
+		assert.Equal(t, "10-2025", info.StartDate)
+	})
+
+	s.T().Run("Conditional on version", func(t *testing.T) {
+		info, err := s.repo.GetInfo(ctx, subID)
+		require.NoError(t, err)
+
+		stale := info.Version - 1
+		resp, err := s.repo.Update(ctx, subID, &storage.UpdateRequest{Price: optional.Of(rub(30)), Version: &stale})
+		require.ErrorIs(t, err, storage.ErrPreconditionFailed)
+		assert.Nil(t, resp)
+
+		resp, err = s.repo.Update(ctx, subID, &storage.UpdateRequest{Price: optional.Of(rub(30)), Version: &info.Version})
+		require.NoError(t, err)
+		assert.Equal(t, info.Version+1, resp.Version)
+	})
+
+	s.T().Run("Required field set to null", func(t *testing.T) {
+		req := &storage.UpdateRequest{
+			Price: optional.Null[money.Amount](),
+		}
+
+		resp, err := s.repo.Update(ctx, subID, req)
+		require.ErrorIs(t, err, storage.ErrValidation)
+		assert.Nil(t, resp)
 	})
 
 	s.T().Run("Invalid StartDate format", func(t *testing.T) {
 		invalid := "2025-10"
-		req := &storage.UpdateRequest{StartDate: &invalid}
+		req := &storage.UpdateRequest{StartDate: optional.Of(invalid)}
 
 		resp, err := s.repo.Update(ctx, subID, req)
 		require.Error(t, err)
@@ -382,7 +687,7 @@ func (s *RepositoryTestSuite) TestUpdateSubscription() {
 
 	s.T().Run("Invalid EndDate format", func(t *testing.T) {
 		invalid := "2025-13"
-		req := &storage.UpdateRequest{EndDate: &invalid}
+		req := &storage.UpdateRequest{EndDate: optional.Of(invalid)}
 
 		resp, err := s.repo.Update(ctx, subID, req)
 		require.Error(t, err)
@@ -396,13 +701,10 @@ func (s *RepositoryTestSuite) TestUpdateSubscription() {
 	})
 
 	s.T().Run("Update non-existing subscription", func(t *testing.T) {
-		req := &storage.UpdateRequest{
-			ServiceName: nil,
-			Price:       nil,
-		}
+		req := &storage.UpdateRequest{}
 		resp, err := s.repo.Update(ctx, uuid.New(), req)
-		require.NoError(t, err)
-		assert.False(t, resp.Updated)
+		require.ErrorIs(t, err, storage.ErrNotFound)
+		assert.Nil(t, resp)
 	})
 
 	clear()
@@ -414,7 +716,7 @@ func (s *RepositoryTestSuite) TestDeleteSubscription() {
 	userID := uuid.New()
 	subID := uuid.New()
 	service := "Netflix"
-	price := 10
+	price := rub(10)
 	start := "09-2025"
 	end := "12-2025"
 
@@ -427,12 +729,12 @@ func (s *RepositoryTestSuite) TestDeleteSubscription() {
 		require.NoError(s.T(), err)
 
 		_, err = conn.Exec(ctx,
-			`INSERT INTO subscriptions (id, user_id, service_name, price, start_date, end_date)
-			 VALUES ($1, $2, $3, $4, $5, $6)`,
+			insertSubscription,
 			subID,
 			userID,
 			service,
-			price,
+			price.Minor,
+			price.Exponent,
 			fmt.Sprintf("%s-%s-01", strings.Split(start, "-")[1], strings.Split(start, "-")[0]),
 			fmt.Sprintf("%s-%s-01", strings.Split(end, "-")[1], strings.Split(end, "-")[0]),
 		)
@@ -464,6 +766,7 @@ func (s *RepositoryTestSuite) TestDeleteSubscription() {
 		err := s.repo.Delete(ctx, req)
 		require.Error(t, err)
 		assert.Contains(t, err.Error(), "not found")
+		assert.ErrorIs(t, err, storage.ErrNotFound)
 	})
 
 	s.T().Run("Nil request", func(t *testing.T) {
@@ -493,8 +796,7 @@ func (s *RepositoryTestSuite) TestGetTotalSubscriptionsPrice() {
 	start2 := "10-2025"
 	end1 := "12-2025"
 	end2 := "11-2025"
-	price1 := 10
-	price2 := 20
+	price1, price2 := int64(10), int64(20)
 
 	prepare := func() {
 		conn, err := s.db.Pool().Acquire(ctx)
@@ -508,22 +810,22 @@ func (s *RepositoryTestSuite) TestGetTotalSubscriptionsPrice() {
 			id          uuid.UUID
 			userID      uuid.UUID
 			serviceName string
-			price       int
+			price       money.Amount
 			start       string
 			end         string
 		}{
-			{uuid.New(), userID, service1, price1, start1, end1},
-			{uuid.New(), otherUserID, service2, price2, start2, end2},
+			{uuid.New(), userID, service1, rub(price1), start1, end1},
+			{uuid.New(), otherUserID, service2, rub(price2), start2, end2},
 		}
 
 		for _, sub := range subs {
 			_, err := conn.Exec(ctx,
-				`INSERT INTO subscriptions (id, user_id, service_name, price, start_date, end_date)
-				 VALUES ($1, $2, $3, $4, $5, $6)`,
+				insertSubscription,
 				sub.id,
 				sub.userID,
 				sub.serviceName,
-				sub.price,
+				sub.price.Minor,
+				sub.price.Exponent,
 				fmt.Sprintf("%s-%s-01", strings.Split(sub.start, "-")[1], strings.Split(sub.start, "-")[0]),
 				fmt.Sprintf("%s-%s-01", strings.Split(sub.end, "-")[1], strings.Split(sub.end, "-")[0]),
 			)
@@ -545,9 +847,50 @@ func (s *RepositoryTestSuite) TestGetTotalSubscriptionsPrice() {
 			From: "09-2025",
 			To:   "12-2025",
 		}
-		total, err := s.repo.GetTotalSubscriptionsPrice(ctx, req)
+		resp, err := s.repo.GetTotalSubscriptionsPrice(ctx, req)
+		require.NoError(t, err)
+		assert.Equal(t, rub(price1*4+price2*2), resp.Total)
+	})
+
+	s.T().Run("Counts only months inside the window", func(t *testing.T) {
+		req := &storage.TotalRequest{
+			From: "11-2025",
+			To:   "11-2025",
+		}
+		resp, err := s.repo.GetTotalSubscriptionsPrice(ctx, req)
+		require.NoError(t, err)
+		assert.Equal(t, rub(price1+price2), resp.Total)
+	})
+
+	s.T().Run("Includes subscriptions started before the window", func(t *testing.T) {
+		req := &storage.TotalRequest{
+			From: "12-2025",
+			To:   "03-2026",
+		}
+		resp, err := s.repo.GetTotalSubscriptionsPrice(ctx, req)
+		require.NoError(t, err)
+		assert.Equal(t, rub(price1), resp.Total)
+	})
+
+	s.T().Run("Open-ended subscription is billed until the end of the window", func(t *testing.T) {
+		conn, err := s.db.Pool().Acquire(ctx)
+		require.NoError(t, err)
+		_, err = conn.Exec(ctx,
+			insertSubscription, uuid.New(), userID, "Yandex Plus", 500, 2, "2025-06-01", nil,
+		)
+		conn.Release()
+		require.NoError(t, err)
+
+		req := &storage.TotalRequest{
+			UserID: &userID,
+			From:   "01-2026",
+			To:     "06-2026",
+		}
+		resp, err := s.repo.GetTotalSubscriptionsPrice(ctx, req)
 		require.NoError(t, err)
-		assert.Equal(t, price1+price2, total)
+		assert.Equal(t, rub(5*6), resp.Total)
+
+		prepare()
 	})
 
 	s.T().Run("Filter by UserID", func(t *testing.T) {
@@ -556,9 +899,9 @@ func (s *RepositoryTestSuite) TestGetTotalSubscriptionsPrice() {
 			From:   "09-2025",
 			To:     "12-2025",
 		}
-		total, err := s.repo.GetTotalSubscriptionsPrice(ctx, req)
+		resp, err := s.repo.GetTotalSubscriptionsPrice(ctx, req)
 		require.NoError(t, err)
-		assert.Equal(t, price1, total)
+		assert.Equal(t, rub(price1*4), resp.Total)
 	})
 
 	s.T().Run("Filter by ServiceName", func(t *testing.T) {
@@ -568,9 +911,77 @@ func (s *RepositoryTestSuite) TestGetTotalSubscriptionsPrice() {
 			From:        "09-2025",
 			To:          "12-2025",
 		}
-		total, err := s.repo.GetTotalSubscriptionsPrice(ctx, req)
+		resp, err := s.repo.GetTotalSubscriptionsPrice(ctx, req)
+		require.NoError(t, err)
+		assert.Equal(t, rub(price2*2), resp.Total)
+	})
+
+	s.T().Run("Filter by ServiceName matches wildcards literally", func(t *testing.T) {
+		prepare()
+		endDate := "09-2025"
+		_, err := s.repo.Create(ctx, &storage.CreateRequest{
+			UserID: userID, ServiceName: "Yandex_Plus", Price: rub(30), StartDate: "09-2025", EndDate: &endDate,
+		})
+		require.NoError(t, err)
+
+		for filter, want := range map[string]money.Amount{"%": rub(0), "_": rub(30), "x_P": rub(30), "tflix": rub(price1 * 4)} {
+			resp, err := s.repo.GetTotalSubscriptionsPrice(ctx, &storage.TotalRequest{ServiceName: &filter, From: "09-2025", To: "12-2025"})
+			require.NoError(t, err)
+			assert.Equal(t, want, resp.Total, filter)
+		}
+		prepare()
+	})
+
+	s.T().Run("Group by month", func(t *testing.T) {
+		req := &storage.TotalRequest{
+			From:    "09-2025",
+			To:      "12-2025",
+			GroupBy: []string{storage.GroupByMonth},
+		}
+		resp, err := s.repo.GetTotalSubscriptionsPrice(ctx, req)
 		require.NoError(t, err)
-		assert.Equal(t, price2, total)
+		assert.Equal(t, rub(price1*4+price2*2), resp.Total)
+		require.Len(t, resp.Buckets, 4)
+
+		months := []string{"09-2025", "10-2025", "11-2025", "12-2025"}
+		totals := []int64{price1, price1 + price2, price1 + price2, price1}
+		counts := []int{1, 2, 2, 1}
+		for i, bucket := range resp.Buckets {
+			require.NotNil(t, bucket.Month)
+			assert.Equal(t, months[i], *bucket.Month)
+			assert.Nil(t, bucket.ServiceName)
+			assert.Nil(t, bucket.UserID)
+			assert.Equal(t, rub(totals[i]), bucket.Total)
+			assert.Equal(t, counts[i], bucket.Count)
+		}
+	})
+
+	s.T().Run("Group by user and service", func(t *testing.T) {
+		req := &storage.TotalRequest{
+			From:    "09-2025",
+			To:      "12-2025",
+			GroupBy: []string{storage.GroupByServiceName, storage.GroupByUserID},
+		}
+		resp, err := s.repo.GetTotalSubscriptionsPrice(ctx, req)
+		require.NoError(t, err)
+		require.Len(t, resp.Buckets, 2)
+		assert.Equal(t, service1, *resp.Buckets[0].ServiceName)
+		assert.Equal(t, userID, *resp.Buckets[0].UserID)
+		assert.Equal(t, rub(price1*4), resp.Buckets[0].Total)
+		assert.Equal(t, service2, *resp.Buckets[1].ServiceName)
+		assert.Equal(t, otherUserID, *resp.Buckets[1].UserID)
+		assert.Equal(t, rub(price2*2), resp.Buckets[1].Total)
+	})
+
+	s.T().Run("Unsupported group by", func(t *testing.T) {
+		req := &storage.TotalRequest{
+			From:    "09-2025",
+			To:      "12-2025",
+			GroupBy: []string{"year"},
+		}
+		resp, err := s.repo.GetTotalSubscriptionsPrice(ctx, req)
+		require.Error(t, err)
+		assert.Nil(t, resp)
 	})
 
 	s.T().Run("Invalid From date", func(t *testing.T) {
@@ -578,9 +989,9 @@ func (s *RepositoryTestSuite) TestGetTotalSubscriptionsPrice() {
 			From: "2025-09",
 			To:   "12-2025",
 		}
-		total, err := s.repo.GetTotalSubscriptionsPrice(ctx, req)
+		resp, err := s.repo.GetTotalSubscriptionsPrice(ctx, req)
 		require.Error(t, err)
-		assert.Equal(t, 0, total)
+		assert.Nil(t, resp)
 	})
 
 	s.T().Run("Invalid To date", func(t *testing.T) {
@@ -588,15 +999,15 @@ func (s *RepositoryTestSuite) TestGetTotalSubscriptionsPrice() {
 			From: "09-2025",
 			To:   "2025-12",
 		}
-		total, err := s.repo.GetTotalSubscriptionsPrice(ctx, req)
+		resp, err := s.repo.GetTotalSubscriptionsPrice(ctx, req)
 		require.Error(t, err)
-		assert.Equal(t, 0, total)
+		assert.Nil(t, resp)
 	})
 
 	s.T().Run("Nil request", func(t *testing.T) {
-		total, err := s.repo.GetTotalSubscriptionsPrice(ctx, nil)
+		resp, err := s.repo.GetTotalSubscriptionsPrice(ctx, nil)
 		require.Error(t, err)
-		assert.Equal(t, 0, total)
+		assert.Nil(t, resp)
 	})
 
 	s.T().Run("No subscriptions in range", func(t *testing.T) {
@@ -604,14 +1015,745 @@ func (s *RepositoryTestSuite) TestGetTotalSubscriptionsPrice() {
 			From: "01-2020",
 			To:   "02-2020",
 		}
-		total, err := s.repo.GetTotalSubscriptionsPrice(ctx, req)
+		resp, err := s.repo.GetTotalSubscriptionsPrice(ctx, req)
 		require.NoError(t, err)
-		assert.Equal(t, 0, total)
+		assert.Equal(t, rub(0), resp.Total)
 	})
 
 	clear()
 }
 
+func (s *RepositoryTestSuite) TestOverlap() {
+	ctx := context.Background()
+
+	userID := uuid.New()
+	create := func(t *testing.T, serviceName, startDate string, endDate *string, policy storage.OverlapPolicy) (*storage.CreateResponse, error) {
+		t.Helper()
+		return s.repo.Create(ctx, &storage.CreateRequest{
+			UserID:      userID,
+			ServiceName: serviceName,
+			Price:       rub(400),
+			StartDate:   startDate,
+			EndDate:     endDate,
+			Overlap:     policy,
+		})
+	}
+	prepare := func() {
+		conn, err := s.db.Pool().Acquire(ctx)
+		require.NoError(s.T(), err)
+		defer conn.Release()
+
+		_, err = conn.Exec(ctx, `DELETE FROM subscriptions WHERE user_id = $1`, userID)
+		require.NoError(s.T(), err)
+	}
+	endDate := func(v string) *string { return &v }
+
+	s.T().Run("Overlap is allowed when the policy is off", func(t *testing.T) {
+		prepare()
+
+		_, err := create(t, "Yandex Plus", "01-2025", endDate("06-2025"), storage.OverlapOff)
+		require.NoError(t, err)
+		resp, err := create(t, "Yandex Plus", "03-2025", nil, storage.OverlapOff)
+		require.NoError(t, err)
+		assert.Empty(t, resp.Overlaps)
+	})
+
+	s.T().Run("Overlap is reported when the policy warns", func(t *testing.T) {
+		prepare()
+
+		first, err := create(t, "Yandex Plus", "01-2025", endDate("06-2025"), storage.OverlapWarn)
+		require.NoError(t, err)
+		resp, err := create(t, "yandex plus", "06-2025", nil, storage.OverlapWarn)
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{first.ID}, resp.Overlaps)
+	})
+
+	s.T().Run("Overlap is rejected when the policy rejects", func(t *testing.T) {
+		prepare()
+
+		_, err := create(t, "Yandex Plus", "01-2025", nil, storage.OverlapReject)
+		require.NoError(t, err)
+		resp, err := create(t, "Yandex Plus", "12-2025", endDate("12-2025"), storage.OverlapReject)
+		assert.ErrorIs(t, err, storage.ErrConflict)
+		assert.Nil(t, resp)
+
+		list, err := s.repo.List(ctx, &storage.ListRequest{UserIDs: []uuid.UUID{userID}})
+		require.NoError(t, err)
+		assert.Len(t, list.Subscriptions, 1)
+	})
+
+	s.T().Run("Adjacent periods and other services do not overlap", func(t *testing.T) {
+		prepare()
+
+		_, err := create(t, "Yandex Plus", "01-2025", endDate("06-2025"), storage.OverlapReject)
+		require.NoError(t, err)
+		_, err = create(t, "Yandex Plus", "07-2025", nil, storage.OverlapReject)
+		require.NoError(t, err)
+		_, err = create(t, "Netflix", "03-2025", nil, storage.OverlapReject)
+		require.NoError(t, err)
+	})
+
+	s.T().Run("Update into an overlapping period is rejected", func(t *testing.T) {
+		prepare()
+
+		_, err := create(t, "Yandex Plus", "01-2025", endDate("06-2025"), storage.OverlapReject)
+		require.NoError(t, err)
+		second, err := create(t, "Yandex Plus", "07-2025", nil, storage.OverlapReject)
+		require.NoError(t, err)
+
+		resp, err := s.repo.Update(ctx, second.ID, &storage.UpdateRequest{
+			StartDate: optional.Of("05-2025"),
+			Overlap:   storage.OverlapReject,
+		})
+		assert.ErrorIs(t, err, storage.ErrConflict)
+		assert.Nil(t, resp)
+
+		info, err := s.repo.GetInfo(ctx, second.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "07-2025", info.StartDate)
+	})
+
+	s.T().Run("Database keeps rejected subscriptions apart", func(t *testing.T) {
+		prepare()
+
+		_, err := create(t, "Yandex Plus", "01-2025", nil, storage.OverlapReject)
+		require.NoError(t, err)
+
+		conn, err := s.db.Pool().Acquire(ctx)
+		require.NoError(t, err)
+		defer conn.Release()
+		insert := `
+			INSERT INTO subscriptions (user_id, service_name, start_date, current_price, reject_overlaps)
+			VALUES ($1, 'yandex plus', '2025-03-01', 400, $2)`
+		_, err = conn.Exec(ctx, insert, userID, true)
+		var pgErr *pgconn.PgError
+		require.ErrorAs(t, err, &pgErr)
+		assert.Equal(t, "subscriptions_no_overlap", pgErr.ConstraintName)
+
+		_, err = conn.Exec(ctx, insert, userID, false)
+		assert.NoError(t, err)
+	})
+
+	s.T().Run("Warned update of a rejected subscription may overlap", func(t *testing.T) {
+		prepare()
+
+		first, err := create(t, "Yandex Plus", "01-2025", endDate("06-2025"), storage.OverlapReject)
+		require.NoError(t, err)
+		second, err := create(t, "Yandex Plus", "07-2025", nil, storage.OverlapReject)
+		require.NoError(t, err)
+
+		resp, err := s.repo.Update(ctx, second.ID, &storage.UpdateRequest{
+			StartDate: optional.Of("05-2025"),
+			Overlap:   storage.OverlapWarn,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{first.ID}, resp.Overlaps)
+	})
+
+	prepare()
+}
+
+func (s *RepositoryTestSuite) TestBillingPeriod() {
+	ctx := context.Background()
+
+	userID := uuid.New()
+	february, june := "02-2025", "06-2025"
+	prepare := func() {
+		conn, err := s.db.Pool().Acquire(ctx)
+		require.NoError(s.T(), err)
+		defer conn.Release()
+
+		_, err = conn.Exec(ctx, `DELETE FROM subscriptions`)
+		require.NoError(s.T(), err)
+	}
+	create := func(t *testing.T, req *storage.CreateRequest) uuid.UUID {
+		t.Helper()
+		req.UserID = userID
+		resp, err := s.repo.Create(ctx, req)
+		require.NoError(t, err)
+		return resp.ID
+	}
+
+	s.T().Run("Defaults to monthly billing", func(t *testing.T) {
+		prepare()
+		id := create(t, &storage.CreateRequest{ServiceName: "Netflix", Price: rub(10), StartDate: "09-2025"})
+
+		info, err := s.repo.GetInfo(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, 1, info.BillingPeriodMonths)
+		assert.Nil(t, info.BillingAnchor)
+	})
+
+	s.T().Run("Yearly subscription is charged once a year from its start", func(t *testing.T) {
+		prepare()
+		create(t, &storage.CreateRequest{ServiceName: "Yandex Plus", Price: rub(1200), StartDate: "03-2025", BillingPeriodMonths: 12})
+
+		resp, err := s.repo.GetTotalSubscriptionsPrice(ctx, &storage.TotalRequest{
+			From:    "01-2025",
+			To:      "12-2026",
+			GroupBy: []string{storage.GroupByMonth},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, rub(2400), resp.Total)
+		assert.Equal(t, rub(2200), resp.NormalizedTotal)
+
+		charged := map[string]money.Amount{}
+		for _, bucket := range resp.Buckets {
+			if bucket.Total.Minor > 0 {
+				charged[*bucket.Month] = bucket.Total
+			}
+			assert.Equal(t, rub(100), bucket.NormalizedTotal)
+		}
+		assert.Equal(t, map[string]money.Amount{"03-2025": rub(1200), "03-2026": rub(1200)}, charged)
+	})
+
+	s.T().Run("Anchor moves the charge month", func(t *testing.T) {
+		prepare()
+		create(t, &storage.CreateRequest{
+			ServiceName:         "Kinopoisk",
+			Price:               rub(300),
+			StartDate:           "01-2025",
+			BillingPeriodMonths: 3,
+			BillingAnchor:       &february,
+		})
+
+		resp, err := s.repo.GetTotalSubscriptionsPrice(ctx, &storage.TotalRequest{From: "01-2025", To: "06-2025"})
+		require.NoError(t, err)
+		// Charged in 02-2025 and 05-2025.
+		assert.Equal(t, rub(600), resp.Total)
+		assert.Equal(t, rub(600), resp.NormalizedTotal)
+	})
+
+	s.T().Run("Normalized total is rounded to kopecks", func(t *testing.T) {
+		prepare()
+		create(t, &storage.CreateRequest{ServiceName: "Okko", Price: rub(100), StartDate: "01-2025", BillingPeriodMonths: 3})
+
+		resp, err := s.repo.GetTotalSubscriptionsPrice(ctx, &storage.TotalRequest{From: "01-2025", To: "01-2025"})
+		require.NoError(t, err)
+		assert.Equal(t, rub(100), resp.Total)
+		assert.Equal(t, money.Amount{Minor: 3333, Exponent: 2}, resp.NormalizedTotal)
+	})
+
+	s.T().Run("Update changes period and clears anchor", func(t *testing.T) {
+		prepare()
+		id := create(t, &storage.CreateRequest{
+			ServiceName:         "Spotify",
+			Price:               rub(500),
+			StartDate:           "01-2025",
+			BillingPeriodMonths: 12,
+			BillingAnchor:       &june,
+		})
+
+		resp, err := s.repo.Update(ctx, id, &storage.UpdateRequest{
+			BillingPeriodMonths: optional.Of(3),
+			BillingAnchor:       optional.Null[string](),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 3, resp.BillingPeriodMonths)
+		assert.Nil(t, resp.BillingAnchor)
+		assert.Equal(t, "01-2025", resp.StartDate)
+	})
+
+	s.T().Run("Rejects billing period out of range", func(t *testing.T) {
+		resp, err := s.repo.Create(ctx, &storage.CreateRequest{
+			UserID:              userID,
+			ServiceName:         "Netflix",
+			Price:               rub(10),
+			StartDate:           "09-2025",
+			BillingPeriodMonths: storage.MaxBillingPeriodMonths + 1,
+		})
+		assert.ErrorIs(t, err, storage.ErrValidation)
+		assert.Nil(t, resp)
+	})
+
+	prepare()
+}
+
+func (s *RepositoryTestSuite) TestFXRates() {
+	ctx := context.Background()
+
+	userID := uuid.New()
+	april := "04-2025"
+	prepare := func() {
+		conn, err := s.db.Pool().Acquire(ctx)
+		require.NoError(s.T(), err)
+		defer conn.Release()
+
+		_, err = conn.Exec(ctx, `DELETE FROM subscriptions; DELETE FROM fx_rates`)
+		require.NoError(s.T(), err)
+	}
+	create := func(t *testing.T, req *storage.CreateRequest) uuid.UUID {
+		t.Helper()
+		req.UserID = userID
+		resp, err := s.repo.Create(ctx, req)
+		require.NoError(t, err)
+		return resp.ID
+	}
+
+	s.T().Run("Defaults to RUB", func(t *testing.T) {
+		prepare()
+		id := create(t, &storage.CreateRequest{ServiceName: "Netflix", Price: rub(10), StartDate: "09-2025"})
+
+		info, err := s.repo.GetInfo(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, storage.DefaultCurrency, info.Currency)
+
+		resp, err := s.repo.GetTotalSubscriptionsPrice(ctx, &storage.TotalRequest{From: "09-2025", To: "09-2025"})
+		require.NoError(t, err)
+		assert.Equal(t, storage.DefaultCurrency, resp.Currency)
+		assert.Equal(t, rub(10), resp.Total)
+	})
+
+	s.T().Run("Saves, replaces and lists rates", func(t *testing.T) {
+		prepare()
+		require.NoError(t, s.repo.SaveFXRates(ctx, []storage.FXRate{
+			{Base: "USD", Quote: "RUB", Month: "01-2025", Rate: "100"},
+			{Base: "USD", Quote: "RUB", Month: "03-2025", Rate: "90"},
+			{Base: "EUR", Quote: "RUB", Month: "01-2025", Rate: "110"},
+		}))
+		require.NoError(t, s.repo.SaveFXRates(ctx, []storage.FXRate{
+			{Base: "USD", Quote: "RUB", Month: "03-2025", Rate: "95"},
+		}))
+
+		usd := "USD"
+		rates, err := s.repo.ListFXRates(ctx, &storage.FXRatesRequest{Base: &usd})
+		require.NoError(t, err)
+		assert.Equal(t, []storage.FXRate{
+			{Base: "USD", Quote: "RUB", Month: "01-2025", Rate: "100"},
+			{Base: "USD", Quote: "RUB", Month: "03-2025", Rate: "95"},
+		}, rates)
+	})
+
+	s.T().Run("Keeps every decimal place of a rate", func(t *testing.T) {
+		prepare()
+		require.NoError(t, s.repo.SaveFXRates(ctx, []storage.FXRate{
+			{Base: "RUB", Quote: "USD", Month: "01-2025", Rate: "0.0098522167"},
+			{Base: "USD", Quote: "RUB", Month: "01-2025", Rate: "101.50"},
+		}))
+
+		rates, err := s.repo.ListFXRates(ctx, nil)
+		require.NoError(t, err)
+		assert.Equal(t, []storage.FXRate{
+			{Base: "RUB", Quote: "USD", Month: "01-2025", Rate: "0.0098522167"},
+			{Base: "USD", Quote: "RUB", Month: "01-2025", Rate: "101.5"},
+		}, rates)
+
+		err = s.repo.SaveFXRates(ctx, []storage.FXRate{{Base: "RUB", Quote: "USD", Month: "02-2025", Rate: "0.00985221675"}})
+		assert.ErrorIs(t, err, storage.ErrValidation)
+	})
+
+	s.T().Run("Converts each month with the rate in effect", func(t *testing.T) {
+		prepare()
+		require.NoError(t, s.repo.SaveFXRates(ctx, []storage.FXRate{
+			{Base: "USD", Quote: "RUB", Month: "01-2025", Rate: "100"},
+			{Base: "USD", Quote: "RUB", Month: "03-2025", Rate: "90"},
+		}))
+		create(t, &storage.CreateRequest{ServiceName: "Spotify", Price: rub(10), Currency: "USD", StartDate: "01-2025", EndDate: &april})
+		create(t, &storage.CreateRequest{ServiceName: "Okko", Price: rub(500), StartDate: "01-2025", EndDate: &april})
+
+		resp, err := s.repo.GetTotalSubscriptionsPrice(ctx, &storage.TotalRequest{From: "01-2025", To: "04-2025"})
+		require.NoError(t, err)
+		// 1000 + 1000 + 900 + 900 for Spotify and 4 * 500 for Okko.
+		assert.Equal(t, rub(5800), resp.Total)
+
+		resp, err = s.repo.GetTotalSubscriptionsPrice(ctx, &storage.TotalRequest{From: "02-2025", To: "03-2025", Currency: "USD"})
+		require.NoError(t, err)
+		// 10 + 10 for Spotify, and the inverse rate converts Okko: 500 / 100 + 500 / 90.
+		assert.Equal(t, "USD", resp.Currency)
+		assert.Equal(t, money.Amount{Minor: 3056, Exponent: 2}, resp.Total)
+	})
+
+	s.T().Run("Missing rate is an error", func(t *testing.T) {
+		prepare()
+		require.NoError(t, s.repo.SaveFXRates(ctx, []storage.FXRate{
+			{Base: "USD", Quote: "RUB", Month: "03-2025", Rate: "90"},
+		}))
+		create(t, &storage.CreateRequest{ServiceName: "Spotify", Price: rub(10), Currency: "USD", StartDate: "01-2025"})
+
+		resp, err := s.repo.GetTotalSubscriptionsPrice(ctx, &storage.TotalRequest{From: "01-2025", To: "03-2025"})
+		assert.ErrorIs(t, err, storage.ErrMissingFXRate)
+		assert.ErrorContains(t, err, "no USD/RUB rate for 01-2025")
+		assert.Nil(t, resp)
+	})
+
+	s.T().Run("Deletes a rate", func(t *testing.T) {
+		prepare()
+		require.NoError(t, s.repo.SaveFXRates(ctx, []storage.FXRate{
+			{Base: "USD", Quote: "RUB", Month: "03-2025", Rate: "90"},
+		}))
+
+		key := &storage.FXRateKey{Base: "USD", Quote: "RUB", Month: "03-2025"}
+		require.NoError(t, s.repo.DeleteFXRate(ctx, key))
+		assert.ErrorIs(t, s.repo.DeleteFXRate(ctx, key), storage.ErrNotFound)
+	})
+
+	s.T().Run("Rejects invalid currency", func(t *testing.T) {
+		_, err := s.repo.Create(ctx, &storage.CreateRequest{
+			UserID: userID, ServiceName: "Netflix", Price: rub(10), Currency: "usd", StartDate: "09-2025",
+		})
+		assert.ErrorIs(t, err, storage.ErrValidation)
+
+		err = s.repo.SaveFXRates(ctx, []storage.FXRate{{Base: "USD", Quote: "USD", Month: "03-2025", Rate: "1"}})
+		assert.ErrorIs(t, err, storage.ErrValidation)
+	})
+
+	prepare()
+}
+
+func (s *RepositoryTestSuite) TestMinorUnits() {
+	ctx := context.Background()
+
+	userID := uuid.New()
+	prepare := func() {
+		conn, err := s.db.Pool().Acquire(ctx)
+		require.NoError(s.T(), err)
+		defer conn.Release()
+
+		_, err = conn.Exec(ctx, `DELETE FROM subscriptions; DELETE FROM fx_rates`)
+		require.NoError(s.T(), err)
+	}
+	create := func(t *testing.T, req *storage.CreateRequest) uuid.UUID {
+		t.Helper()
+		req.UserID = userID
+		resp, err := s.repo.Create(ctx, req)
+		require.NoError(t, err)
+		return resp.ID
+	}
+
+	s.T().Run("Keeps fractional prices exactly", func(t *testing.T) {
+		prepare()
+		price := money.Amount{Minor: 29990, Exponent: 2}
+		id := create(t, &storage.CreateRequest{ServiceName: "Netflix", Price: price, StartDate: "01-2025"})
+
+		info, err := s.repo.GetInfo(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, price, info.Price)
+
+		resp, err := s.repo.GetTotalSubscriptionsPrice(ctx, &storage.TotalRequest{From: "01-2025", To: "03-2025"})
+		require.NoError(t, err)
+		assert.Equal(t, money.Amount{Minor: 89970, Exponent: 2}, resp.Total)
+	})
+
+	s.T().Run("Converts between exponents", func(t *testing.T) {
+		prepare()
+		require.NoError(t, s.repo.SaveFXRates(ctx, []storage.FXRate{
+			{Base: "JPY", Quote: "RUB", Month: "01-2025", Rate: "0.6"},
+		}))
+		create(t, &storage.CreateRequest{ServiceName: "Abema", Price: money.Amount{Minor: 960, Exponent: 0}, Currency: "JPY", StartDate: "01-2025"})
+
+		resp, err := s.repo.GetTotalSubscriptionsPrice(ctx, &storage.TotalRequest{From: "01-2025", To: "01-2025"})
+		require.NoError(t, err)
+		assert.Equal(t, rub(576), resp.Total)
+
+		resp, err = s.repo.GetTotalSubscriptionsPrice(ctx, &storage.TotalRequest{From: "01-2025", To: "01-2025", Currency: "JPY"})
+		require.NoError(t, err)
+		assert.Equal(t, money.Amount{Minor: 960, Exponent: 0}, resp.Total)
+	})
+
+	s.T().Run("Sorts and filters by value across exponents", func(t *testing.T) {
+		prepare()
+		cheap := create(t, &storage.CreateRequest{ServiceName: "Abema", Price: money.Amount{Minor: 960, Exponent: 0}, Currency: "JPY", StartDate: "01-2025"})
+		dear := create(t, &storage.CreateRequest{ServiceName: "Netflix", Price: money.Amount{Minor: 99999, Exponent: 2}, StartDate: "01-2025"})
+
+		resp, err := s.repo.List(ctx, &storage.ListRequest{Sort: storage.SortByPrice, Desc: true})
+		require.NoError(t, err)
+		require.Len(t, resp.Subscriptions, 2)
+		assert.Equal(t, dear, resp.Subscriptions[0].ID)
+		assert.Equal(t, cheap, resp.Subscriptions[1].ID)
+
+		priceMax := money.Decimal("999.98")
+		resp, err = s.repo.List(ctx, &storage.ListRequest{PriceMax: &priceMax})
+		require.NoError(t, err)
+		require.Len(t, resp.Subscriptions, 1)
+		assert.Equal(t, cheap, resp.Subscriptions[0].ID)
+	})
+
+	s.T().Run("Rejects a price in the wrong exponent", func(t *testing.T) {
+		resp, err := s.repo.Create(ctx, &storage.CreateRequest{
+			UserID: userID, ServiceName: "Abema", Price: rub(960), Currency: "JPY", StartDate: "01-2025",
+		})
+		assert.ErrorIs(t, err, storage.ErrValidation)
+		assert.Nil(t, resp)
+	})
+
+	prepare()
+}
+
+func (s *RepositoryTestSuite) TestPriceHistory() {
+	ctx := context.Background()
+
+	userID := uuid.New()
+	prepare := func() {
+		conn, err := s.db.Pool().Acquire(ctx)
+		require.NoError(s.T(), err)
+		defer conn.Release()
+
+		_, err = conn.Exec(ctx, `DELETE FROM subscriptions WHERE user_id = $1`, userID)
+		require.NoError(s.T(), err)
+	}
+	prepare()
+
+	now := time.Now()
+	thisMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	monthsFrom := func(n int) string {
+		return month.Format(thisMonth.AddDate(0, n, 0))
+	}
+
+	resp, err := s.repo.Create(ctx, &storage.CreateRequest{UserID: userID, ServiceName: "Netflix", Price: rub(100), StartDate: monthsFrom(-3)})
+	require.NoError(s.T(), err)
+	id := resp.ID
+
+	s.T().Run("Appends a price change instead of overwriting", func(t *testing.T) {
+		_, err := s.repo.Update(ctx, id, &storage.UpdateRequest{
+			Price:              optional.Of(rub(150)),
+			PriceEffectiveFrom: optional.Of(monthsFrom(3)),
+		})
+		require.NoError(t, err)
+
+		prices, err := s.repo.ListPrices(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, []storage.PriceChange{
+			{EffectiveFrom: monthsFrom(-3), Price: rub(100), Currency: "RUB"},
+			{EffectiveFrom: monthsFrom(3), Price: rub(150), Currency: "RUB"},
+		}, prices)
+	})
+
+	s.T().Run("Totals use the price of each month", func(t *testing.T) {
+		total, err := s.repo.GetTotalSubscriptionsPrice(ctx, &storage.TotalRequest{UserID: &userID, From: monthsFrom(-3), To: monthsFrom(5)})
+		require.NoError(t, err)
+		assert.Equal(t, rub(6*100+3*150), total.Total)
+	})
+
+	s.T().Run("Schedules a future price", func(t *testing.T) {
+		updated, err := s.repo.Update(ctx, id, &storage.UpdateRequest{
+			Price:              optional.Of(rub(200)),
+			PriceEffectiveFrom: optional.Of("01-2099"),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, rub(100), updated.Price)
+
+		total, err := s.repo.GetTotalSubscriptionsPrice(ctx, &storage.TotalRequest{UserID: &userID, From: "12-2098", To: "01-2099"})
+		require.NoError(t, err)
+		assert.Equal(t, rub(150+200), total.Total)
+	})
+
+	s.T().Run("Skips a price that does not change", func(t *testing.T) {
+		_, err := s.repo.Update(ctx, id, &storage.UpdateRequest{Price: optional.Of(rub(100))})
+		require.NoError(t, err)
+
+		prices, err := s.repo.ListPrices(ctx, id)
+		require.NoError(t, err)
+		assert.Len(t, prices, 3)
+	})
+
+	s.T().Run("Rejects a price for a billed month", func(t *testing.T) {
+		_, err := s.repo.Update(ctx, id, &storage.UpdateRequest{
+			Price:              optional.Of(rub(120)),
+			PriceEffectiveFrom: optional.Of(monthsFrom(-1)),
+		})
+		assert.ErrorIs(t, err, storage.ErrValidation)
+	})
+
+	s.T().Run("Rejects a price after end_date", func(t *testing.T) {
+		_, err := s.repo.Update(ctx, id, &storage.UpdateRequest{
+			Price:              optional.Of(rub(120)),
+			PriceEffectiveFrom: optional.Of(monthsFrom(2)),
+			EndDate:            optional.Of(monthsFrom(1)),
+		})
+		assert.ErrorIs(t, err, storage.ErrValidation)
+
+		prices, err := s.repo.ListPrices(ctx, id)
+		require.NoError(t, err)
+		assert.Len(t, prices, 3)
+	})
+
+	s.T().Run("Keeps earlier prices in their currency", func(t *testing.T) {
+		_, err := s.repo.Update(ctx, id, &storage.UpdateRequest{Currency: optional.Of("JPY")})
+		assert.ErrorIs(t, err, storage.ErrValidation)
+
+		_, err = s.repo.Update(ctx, id, &storage.UpdateRequest{
+			Currency: optional.Of("JPY"),
+			Price:    optional.Of(money.Amount{Minor: 1500, Exponent: 0}),
+		})
+		require.NoError(t, err)
+
+		prices, err := s.repo.ListPrices(ctx, id)
+		require.NoError(t, err)
+		require.Len(t, prices, 4)
+		assert.Equal(t, storage.PriceChange{EffectiveFrom: monthsFrom(-3), Price: rub(100), Currency: "RUB"}, prices[0])
+		assert.Equal(t, storage.PriceChange{
+			EffectiveFrom: monthsFrom(0), Price: money.Amount{Minor: 1500, Exponent: 0}, Currency: "JPY",
+		}, prices[1])
+
+		total, err := s.repo.GetTotalSubscriptionsPrice(ctx, &storage.TotalRequest{UserID: &userID, From: monthsFrom(-3), To: monthsFrom(-1)})
+		require.NoError(t, err)
+		assert.Equal(t, rub(3*100), total.Total)
+
+		_, err = s.repo.Update(ctx, id, &storage.UpdateRequest{
+			Currency: optional.Of("USD"),
+			Price:    optional.Of(money.Amount{Minor: 15, Exponent: 0}),
+		})
+		assert.ErrorIs(t, err, storage.ErrValidation)
+	})
+
+	s.T().Run("Purges the history with the subscription", func(t *testing.T) {
+		require.NoError(t, s.repo.Delete(ctx, &storage.DeleteRequest{ID: id}))
+		_, err := s.repo.PurgeDeleted(ctx, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+
+		prices, err := s.repo.ListPrices(ctx, id)
+		require.NoError(t, err)
+		assert.Empty(t, prices)
+	})
+
+	s.T().Run("Keeps the price of an ended subscription", func(t *testing.T) {
+		endDate := monthsFrom(-1)
+		resp, err := s.repo.Create(ctx, &storage.CreateRequest{
+			UserID: userID, ServiceName: "Spotify", Price: rub(100), StartDate: monthsFrom(-6), EndDate: &endDate,
+		})
+		require.NoError(t, err)
+
+		_, err = s.repo.Update(ctx, resp.ID, &storage.UpdateRequest{Price: optional.Of(rub(120))})
+		assert.ErrorIs(t, err, storage.ErrValidation)
+
+		_, err = s.repo.Update(ctx, resp.ID, &storage.UpdateRequest{Price: optional.Of(rub(100))})
+		require.NoError(t, err)
+
+		prices, err := s.repo.ListPrices(ctx, resp.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []storage.PriceChange{{EffectiveFrom: monthsFrom(-6), Price: rub(100), Currency: "RUB"}}, prices)
+	})
+
+	prepare()
+}
+
+func (s *RepositoryTestSuite) TestSoftDelete() {
+	ctx := context.Background()
+
+	userID := uuid.New()
+	prepare := func() {
+		conn, err := s.db.Pool().Acquire(ctx)
+		require.NoError(s.T(), err)
+		defer conn.Release()
+
+		_, err = conn.Exec(ctx, `DELETE FROM subscriptions WHERE user_id = $1`, userID)
+		require.NoError(s.T(), err)
+	}
+	prepare()
+
+	resp, err := s.repo.Create(ctx, &storage.CreateRequest{UserID: userID, ServiceName: "Netflix", Price: rub(100), StartDate: "01-2020"})
+	require.NoError(s.T(), err)
+	id := resp.ID
+	past := &storage.TotalRequest{UserID: &userID, From: "01-2020", To: "12-2020"}
+
+	s.T().Run("Hides a deleted subscription", func(t *testing.T) {
+		require.NoError(t, s.repo.Delete(ctx, &storage.DeleteRequest{ID: id}))
+
+		info, err := s.repo.GetInfo(ctx, id)
+		require.NoError(t, err)
+		assert.Nil(t, info)
+
+		list, err := s.repo.List(ctx, &storage.ListRequest{UserIDs: []uuid.UUID{userID}})
+		require.NoError(t, err)
+		assert.Empty(t, list.Subscriptions)
+
+		list, err = s.repo.List(ctx, &storage.ListRequest{UserIDs: []uuid.UUID{userID}, IncludeDeleted: true})
+		require.NoError(t, err)
+		require.Len(t, list.Subscriptions, 1)
+		assert.NotNil(t, list.Subscriptions[0].DeletedAt)
+
+		assert.ErrorIs(t, s.repo.Delete(ctx, &storage.DeleteRequest{ID: id}), storage.ErrNotFound)
+		_, err = s.repo.Update(ctx, id, &storage.UpdateRequest{ServiceName: optional.Of("Okko")})
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+	})
+
+	s.T().Run("Keeps past totals", func(t *testing.T) {
+		total, err := s.repo.GetTotalSubscriptionsPrice(ctx, past)
+		require.NoError(t, err)
+		assert.Equal(t, rub(1200), total.Total)
+
+		current := month.Format(time.Now())
+		total, err = s.repo.GetTotalSubscriptionsPrice(ctx, &storage.TotalRequest{UserID: &userID, From: current, To: current})
+		require.NoError(t, err)
+		assert.Equal(t, rub(0), total.Total)
+	})
+
+	s.T().Run("Restores only subscriptions of the user", func(t *testing.T) {
+		other := uuid.New()
+		_, err := s.repo.Restore(ctx, &storage.RestoreRequest{ID: id, UserID: &other})
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+
+		restored, err := s.repo.Restore(ctx, &storage.RestoreRequest{ID: id, UserID: &userID})
+		require.NoError(t, err)
+		assert.Nil(t, restored.DeletedAt)
+		assert.Equal(t, int64(3), restored.Version)
+
+		info, err := s.repo.GetInfo(ctx, id)
+		require.NoError(t, err)
+		require.NotNil(t, info)
+
+		_, err = s.repo.Restore(ctx, &storage.RestoreRequest{ID: id})
+		assert.ErrorIs(t, err, storage.ErrConflict)
+	})
+
+	s.T().Run("Purges after the retention", func(t *testing.T) {
+		require.NoError(t, s.repo.Delete(ctx, &storage.DeleteRequest{ID: id}))
+
+		purged, err := s.repo.PurgeDeleted(ctx, time.Now().Add(-time.Hour))
+		require.NoError(t, err)
+		assert.Zero(t, purged)
+
+		purged, err = s.repo.PurgeDeleted(ctx, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), purged)
+
+		_, err = s.repo.Restore(ctx, &storage.RestoreRequest{ID: id})
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+	})
+
+	prepare()
+}
+
+func (s *RepositoryTestSuite) TestHealth() {
+	ctx := context.Background()
+
+	s.T().Run("Healthy when migrated to the embedded version", func(t *testing.T) {
+		require.NoError(t, s.db.Health(ctx))
+	})
+
+	s.T().Run("Unhealthy when schema version differs", func(t *testing.T) {
+		conn, err := s.db.Pool().Acquire(ctx)
+		require.NoError(t, err)
+		defer conn.Release()
+
+		_, err = conn.Exec(ctx, `UPDATE schema_migrations SET version = version + 1000`)
+		require.NoError(t, err)
+		defer func() {
+			_, err := conn.Exec(ctx, `UPDATE schema_migrations SET version = version - 1000`)
+			require.NoError(t, err)
+		}()
+
+		assert.Error(t, s.db.Health(ctx))
+	})
+}
+
+// insertSubscription adds a subscription from id, user_id, service_name,
+// the minor units and exponent of its price, start_date and end_date,
+// together with the first entry of its price history in RUB.
+const insertSubscription = `
+	WITH s AS (
+		INSERT INTO subscriptions (id, user_id, service_name, start_date, end_date, current_price)
+		VALUES ($1, $2, $3, $6, $7, $4::bigint / power(10::numeric, $5::smallint))
+		RETURNING id, start_date
+	)
+	INSERT INTO subscription_prices (subscription_id, effective_from, price_minor, price_exponent, currency)
+	SELECT id, start_date, $4::bigint, $5::smallint, 'RUB' FROM s`
+
+// rub is a whole number of rubles.
+func rub(major int64) money.Amount {
+	return money.Amount{Minor: major * 100, Exponent: 2}
+}
+
 func TestRepositorySuite(t *testing.T) {
 	suite.Run(t, new(RepositoryTestSuite))
 }
@@ -0,0 +1,27 @@
+package storage
+
+import (
+	"context"
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/azaliaz/subs-api/internal/storage")
+
+// startSpan opens a client span for a single storage operation. The query
+// text is attached separately once it has been built.
+func startSpan(ctx context.Context, operation string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "storage."+operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemNamePostgreSQL,
+			semconv.DBCollectionName("subscriptions"),
+			semconv.DBOperationName(operation),
+		),
+	)
+}
+
+func setQuery(span trace.Span, query string) {
+	span.SetAttributes(semconv.DBQueryText(query))
+}
@@ -7,6 +7,7 @@ import (
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
 	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"os"
 )
 
 //go:embed sql/*
@@ -47,3 +48,27 @@ func PostgresMigrateDown(connStr string) error {
 	_, err = mig.Close()
 	return err
 }
+
+// Version returns the latest migration version embedded into the binary.
+func Version() (uint, error) {
+	d, err := iofs.New(fs, "sql")
+	if err != nil {
+		return 0, err
+	}
+	defer d.Close()
+
+	version, err := d.First()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		next, err := d.Next(version)
+		if errors.Is(err, os.ErrNotExist) {
+			return version, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		version = next
+	}
+}
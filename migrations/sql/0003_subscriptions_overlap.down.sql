@@ -0,0 +1 @@
+DROP INDEX IF EXISTS subscriptions_overlap_idx;
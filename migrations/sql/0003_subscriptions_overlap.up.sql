@@ -0,0 +1,3 @@
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+CREATE INDEX subscriptions_overlap_idx ON subscriptions
+    USING gist (user_id, lower(service_name), daterange(start_date, end_date, '[]'));
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS subscriptions_service_name_trgm_idx;
+DROP INDEX IF EXISTS subscriptions_created_at_id_idx;
+DROP INDEX IF EXISTS subscriptions_service_name_id_idx;
+DROP INDEX IF EXISTS subscriptions_end_date_id_idx;
+DROP INDEX IF EXISTS subscriptions_price_id_idx;
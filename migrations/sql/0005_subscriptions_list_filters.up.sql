@@ -0,0 +1,6 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX subscriptions_price_id_idx ON subscriptions (price, id);
+CREATE INDEX subscriptions_end_date_id_idx ON subscriptions ((COALESCE(end_date, 'infinity'::date)), id);
+CREATE INDEX subscriptions_service_name_id_idx ON subscriptions (service_name, id);
+CREATE INDEX subscriptions_created_at_id_idx ON subscriptions (created_at, id);
+CREATE INDEX subscriptions_service_name_trgm_idx ON subscriptions USING gin (service_name gin_trgm_ops);
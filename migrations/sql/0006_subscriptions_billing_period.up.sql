@@ -0,0 +1,4 @@
+ALTER TABLE subscriptions
+    ADD COLUMN billing_period_months INTEGER NOT NULL DEFAULT 1
+        CHECK (billing_period_months BETWEEN 1 AND 120),
+    ADD COLUMN billing_anchor DATE;
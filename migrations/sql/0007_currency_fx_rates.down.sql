@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS fx_rates;
+ALTER TABLE subscriptions DROP COLUMN currency;
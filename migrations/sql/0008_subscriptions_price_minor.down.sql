@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS subscriptions_price_id_idx;
+
+ALTER TABLE subscriptions ADD COLUMN price INTEGER;
+UPDATE subscriptions SET price = round(price_minor / power(10::numeric, price_exponent));
+ALTER TABLE subscriptions
+    ALTER COLUMN price SET NOT NULL,
+    ADD CHECK (price >= 0),
+    DROP COLUMN price_exponent,
+    DROP COLUMN price_minor;
+
+CREATE INDEX subscriptions_price_id_idx ON subscriptions (price, id);
@@ -0,0 +1,25 @@
+ALTER TABLE subscriptions
+    ADD COLUMN price_minor BIGINT,
+    ADD COLUMN price_exponent SMALLINT;
+
+-- Prices were whole major units; the exponent of each currency matches
+-- money.Exponent.
+UPDATE subscriptions
+SET price_exponent = CASE
+        WHEN currency IN ('BIF', 'CLP', 'DJF', 'GNF', 'ISK', 'JPY', 'KMF', 'KRW', 'PYG',
+                          'RWF', 'UGX', 'UYI', 'VND', 'VUV', 'XAF', 'XOF', 'XPF') THEN 0
+        WHEN currency IN ('BHD', 'IQD', 'JOD', 'KWD', 'LYD', 'OMR', 'TND') THEN 3
+        WHEN currency IN ('CLF', 'UYW') THEN 4
+        ELSE 2
+    END;
+UPDATE subscriptions SET price_minor = price * power(10::numeric, price_exponent);
+
+ALTER TABLE subscriptions
+    ALTER COLUMN price_minor SET NOT NULL,
+    ALTER COLUMN price_exponent SET NOT NULL,
+    ADD CHECK (price_minor >= 0),
+    ADD CHECK (price_exponent BETWEEN 0 AND 4),
+    DROP COLUMN price;
+
+CREATE INDEX subscriptions_price_id_idx
+    ON subscriptions ((price_minor / power(10::numeric, price_exponent)), id);
@@ -0,0 +1,27 @@
+ALTER TABLE subscriptions
+    ADD COLUMN price_minor BIGINT,
+    ADD COLUMN price_exponent SMALLINT;
+
+-- Keep the price in effect in the current month, or the first one of a
+-- subscription that has not started yet.
+UPDATE subscriptions s
+SET price_minor = p.price_minor, price_exponent = p.price_exponent
+FROM (
+    SELECT DISTINCT ON (subscription_id) subscription_id, price_minor, price_exponent
+    FROM subscription_prices
+    ORDER BY subscription_id,
+        effective_from > date_trunc('month', current_date),
+        abs(effective_from - date_trunc('month', current_date)::date)
+) p
+WHERE p.subscription_id = s.id;
+
+ALTER TABLE subscriptions
+    ALTER COLUMN price_minor SET NOT NULL,
+    ALTER COLUMN price_exponent SET NOT NULL,
+    ADD CHECK (price_minor >= 0),
+    ADD CHECK (price_exponent BETWEEN 0 AND 4);
+
+CREATE INDEX subscriptions_price_id_idx
+    ON subscriptions ((price_minor / power(10::numeric, price_exponent)), id);
+
+DROP TABLE subscription_prices;
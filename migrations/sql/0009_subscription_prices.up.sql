@@ -0,0 +1,17 @@
+CREATE TABLE subscription_prices (
+    subscription_id UUID NOT NULL REFERENCES subscriptions (id) ON DELETE CASCADE,
+    effective_from DATE NOT NULL CHECK (EXTRACT(DAY FROM effective_from) = 1),
+    price_minor BIGINT NOT NULL CHECK (price_minor >= 0),
+    price_exponent SMALLINT NOT NULL CHECK (price_exponent BETWEEN 0 AND 4),
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT now() NOT NULL,
+    PRIMARY KEY (subscription_id, effective_from)
+);
+
+-- The price of every subscription so far has applied since it started.
+INSERT INTO subscription_prices (subscription_id, effective_from, price_minor, price_exponent)
+SELECT id, start_date, price_minor, price_exponent FROM subscriptions;
+
+DROP INDEX IF EXISTS subscriptions_price_id_idx;
+ALTER TABLE subscriptions
+    DROP COLUMN price_minor,
+    DROP COLUMN price_exponent;
@@ -0,0 +1,11 @@
+-- Without deleted_at a soft-deleted subscription would become active again,
+-- and dropping it would lose data that can still be restored.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM subscriptions WHERE deleted_at IS NOT NULL) THEN
+        RAISE EXCEPTION 'subscriptions has soft-deleted rows; restore or purge them first';
+    END IF;
+END $$;
+
+DROP INDEX IF EXISTS subscriptions_deleted_at_idx;
+ALTER TABLE subscriptions DROP COLUMN IF EXISTS deleted_at;
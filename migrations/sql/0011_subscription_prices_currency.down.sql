@@ -0,0 +1,22 @@
+-- A subscription has a single currency again, so a price history that
+-- changes currency cannot be kept without reinterpreting past prices.
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM subscription_prices
+        GROUP BY subscription_id
+        HAVING count(DISTINCT currency) > 1
+    ) THEN
+        RAISE EXCEPTION 'subscription_prices has subscriptions priced in several currencies';
+    END IF;
+END $$;
+
+ALTER TABLE subscriptions
+    ADD COLUMN currency TEXT NOT NULL DEFAULT 'RUB' CHECK (currency ~ '^[A-Z]{3}$');
+
+UPDATE subscriptions s
+SET currency = p.currency
+FROM (SELECT DISTINCT subscription_id, currency FROM subscription_prices) p
+WHERE p.subscription_id = s.id;
+
+ALTER TABLE subscription_prices DROP COLUMN currency;
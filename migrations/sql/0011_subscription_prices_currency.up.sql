@@ -0,0 +1,13 @@
+ALTER TABLE subscription_prices
+    ADD COLUMN currency TEXT CHECK (currency ~ '^[A-Z]{3}$');
+
+-- Every price so far was in the current currency of its subscription.
+UPDATE subscription_prices p
+SET currency = s.currency
+FROM subscriptions s
+WHERE s.id = p.subscription_id;
+
+ALTER TABLE subscription_prices ALTER COLUMN currency SET NOT NULL;
+
+-- The currency of a subscription is now the one of its price in effect.
+ALTER TABLE subscriptions DROP COLUMN currency;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS subscriptions_current_price_until_idx;
+DROP INDEX IF EXISTS subscriptions_current_price_id_idx;
+ALTER TABLE subscriptions
+    DROP COLUMN current_price,
+    DROP COLUMN current_price_until;
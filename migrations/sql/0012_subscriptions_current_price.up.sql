@@ -0,0 +1,26 @@
+-- The price in effect in the current month, in major units, is kept on the
+-- subscription so that List can sort and filter on it with an index.
+-- current_price_until is the month of the next change in the history, from
+-- which it has to be set again.
+ALTER TABLE subscriptions
+    ADD COLUMN current_price NUMERIC,
+    ADD COLUMN current_price_until DATE;
+
+UPDATE subscriptions s
+SET current_price = (
+        SELECT p.price_minor / power(10::numeric, p.price_exponent)
+        FROM subscription_prices p
+        WHERE p.subscription_id = s.id
+        ORDER BY p.effective_from > date_trunc('month', current_date)::date,
+            abs(p.effective_from - date_trunc('month', current_date)::date)
+        LIMIT 1),
+    current_price_until = (
+        SELECT min(p.effective_from)
+        FROM subscription_prices p
+        WHERE p.subscription_id = s.id AND p.effective_from > date_trunc('month', current_date)::date);
+
+ALTER TABLE subscriptions ALTER COLUMN current_price SET NOT NULL;
+
+CREATE INDEX subscriptions_current_price_id_idx ON subscriptions (current_price, id);
+CREATE INDEX subscriptions_current_price_until_idx ON subscriptions (current_price_until)
+    WHERE current_price_until IS NOT NULL;
@@ -0,0 +1,11 @@
+-- reject_overlaps marks the subscriptions last checked for overlaps with
+-- APP_OVERLAP_POLICY=reject, and only they are kept apart by the database:
+-- the policy can change at runtime, and subscriptions saved under off or
+-- warn may overlap on purpose.
+ALTER TABLE subscriptions ADD COLUMN reject_overlaps BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE subscriptions ADD CONSTRAINT subscriptions_no_overlap
+    EXCLUDE USING gist (
+        user_id WITH =,
+        lower(service_name) WITH =,
+        daterange(start_date, end_date, '[]') WITH &&
+    ) WHERE (reject_overlaps AND deleted_at IS NULL);
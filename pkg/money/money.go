@@ -0,0 +1,192 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// MaxExponent is the largest number of decimal places of any currency.
+const MaxExponent = 4
+
+var (
+	ErrSyntax    = errors.New("not a decimal number")
+	ErrPrecision = errors.New("too many decimal places")
+	ErrRange     = errors.New("amount out of range")
+)
+
+// exponents lists the ISO 4217 currencies whose minor unit is not a
+// hundredth of the major one.
+var exponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"CLF": 4, "UYW": 4,
+}
+
+// Exponent is the number of decimal places in amounts of currency: 2 for
+// RUB, USD and most others.
+func Exponent(currency string) int {
+	if exponent, ok := exponents[currency]; ok {
+		return exponent
+	}
+	return 2
+}
+
+// Amount is an exact sum of money: Minor units of a currency, of which there
+// are 10^Exponent in one major unit. 299.90 RUB is {29990, 2}.
+type Amount struct {
+	Minor    int64
+	Exponent int
+}
+
+// Parse reads a decimal such as "299.90" into minor units. Decimal places
+// beyond exponent are only accepted when they are zeros.
+func Parse(value string, exponent int) (Amount, error) {
+	negative := strings.HasPrefix(value, "-")
+	whole, fraction, dot := strings.Cut(strings.TrimPrefix(value, "-"), ".")
+	if !isDigits(whole) || dot && !isDigits(fraction) {
+		return Amount{}, fmt.Errorf("%w: %q", ErrSyntax, value)
+	}
+	if len(strings.TrimRight(fraction, "0")) > exponent {
+		return Amount{}, fmt.Errorf("%w: %q has more than %d", ErrPrecision, value, exponent)
+	}
+	fraction += strings.Repeat("0", max(exponent-len(fraction), 0))
+
+	minor, err := strconv.ParseInt(whole+fraction[:exponent], 10, 64)
+	if err != nil {
+		return Amount{}, fmt.Errorf("%w: %q", ErrRange, value)
+	}
+	if negative {
+		minor = -minor
+	}
+	return Amount{Minor: minor, Exponent: exponent}, nil
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// String renders the amount with exactly Exponent decimal places.
+func (a Amount) String() string {
+	digits := new(big.Int).Abs(big.NewInt(a.Minor)).String()
+	if a.Exponent > 0 {
+		digits = strings.Repeat("0", max(a.Exponent+1-len(digits), 0)) + digits
+		digits = digits[:len(digits)-a.Exponent] + "." + digits[len(digits)-a.Exponent:]
+	}
+	if a.Minor < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// MarshalJSON renders the amount as a decimal string, so that clients do
+// not round it through a binary float.
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.String())
+}
+
+// Rescale converts the amount to another exponent. Going to fewer decimal
+// places fails with ErrPrecision unless the dropped digits are zeros.
+func (a Amount) Rescale(exponent int) (Amount, error) {
+	return Parse(a.String(), exponent)
+}
+
+// Cmp compares the amounts by value, whatever their exponents.
+func (a Amount) Cmp(b Amount) int {
+	return a.rat().Cmp(b.rat())
+}
+
+func (a Amount) rat() *big.Rat {
+	denominator := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(a.Exponent)), nil)
+	return new(big.Rat).SetFrac(big.NewInt(a.Minor), denominator)
+}
+
+// Div splits the amount into n equal parts, rounding half away from zero
+// to a minor unit.
+func (a Amount) Div(n int64) Amount {
+	return Amount{Minor: roundDiv(a.Minor, n), Exponent: a.Exponent}
+}
+
+// Major rounds the amount half away from zero to whole major units.
+func (a Amount) Major() int64 {
+	unit := int64(1)
+	for i := 0; i < a.Exponent; i++ {
+		unit *= 10
+	}
+	return roundDiv(a.Minor, unit)
+}
+
+// Float64 is the nearest binary float to the amount in major units.
+func (a Amount) Float64() float64 {
+	f, _ := a.rat().Float64()
+	return f
+}
+
+func roundDiv(x, n int64) int64 {
+	q, r := x/n, x%n
+	if r < 0 {
+		r = -r
+	}
+	if 2*r >= n {
+		if x < 0 {
+			return q - 1
+		}
+		return q + 1
+	}
+	return q
+}
+
+// Decimal is a decimal number exactly as written in a request, such as
+// "299.90". It is decoded from a JSON number or string without going through
+// float64 and becomes an Amount once the currency, and so the exponent, is
+// known.
+type Decimal string
+
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+	value := string(data)
+	if value == "null" {
+		return nil
+	}
+	if strings.HasPrefix(value, `"`) {
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+	}
+	if _, err := Parse(value, MaxExponent); errors.Is(err, ErrSyntax) {
+		return err
+	}
+	*d = Decimal(value)
+	return nil
+}
+
+// MarshalJSON renders the decimal as a JSON number, as it was written, and
+// an empty one as null. Numbers JSON does not allow, such as "007", stay
+// strings.
+func (d Decimal) MarshalJSON() ([]byte, error) {
+	switch {
+	case d == "":
+		return []byte("null"), nil
+	case json.Valid([]byte(d)):
+		return []byte(d), nil
+	default:
+		return json.Marshal(string(d))
+	}
+}
+
+// Amount converts the decimal into minor units of a currency with the given
+// exponent.
+func (d Decimal) Amount(exponent int) (Amount, error) {
+	return Parse(string(d), exponent)
+}
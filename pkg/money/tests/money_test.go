@@ -0,0 +1,112 @@
+package tests
+
+import (
+	"encoding/json"
+	"github.com/azaliaz/subs-api/pkg/money"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		value    string
+		exponent int
+		want     money.Amount
+		err      error
+	}{
+		{value: "299.90", exponent: 2, want: money.Amount{Minor: 29990, Exponent: 2}},
+		{value: "299.9", exponent: 2, want: money.Amount{Minor: 29990, Exponent: 2}},
+		{value: "300", exponent: 2, want: money.Amount{Minor: 30000, Exponent: 2}},
+		{value: "0.01", exponent: 2, want: money.Amount{Minor: 1, Exponent: 2}},
+		{value: "-5.5", exponent: 2, want: money.Amount{Minor: -550, Exponent: 2}},
+		{value: "1500.000", exponent: 0, want: money.Amount{Minor: 1500, Exponent: 0}},
+		{value: "1.234", exponent: 3, want: money.Amount{Minor: 1234, Exponent: 3}},
+		{value: "0.001", exponent: 2, err: money.ErrPrecision},
+		{value: "1500.5", exponent: 0, err: money.ErrPrecision},
+		{value: "", exponent: 2, err: money.ErrSyntax},
+		{value: "1e3", exponent: 2, err: money.ErrSyntax},
+		{value: ".5", exponent: 2, err: money.ErrSyntax},
+		{value: "5.", exponent: 2, err: money.ErrSyntax},
+		{value: "+5", exponent: 2, err: money.ErrSyntax},
+		{value: "1 000", exponent: 2, err: money.ErrSyntax},
+		{value: "99999999999999999999", exponent: 2, err: money.ErrRange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := money.Parse(tt.value, tt.exponent)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestAmount(t *testing.T) {
+	assert.Equal(t, "299.90", money.Amount{Minor: 29990, Exponent: 2}.String())
+	assert.Equal(t, "0.05", money.Amount{Minor: 5, Exponent: 2}.String())
+	assert.Equal(t, "-0.05", money.Amount{Minor: -5, Exponent: 2}.String())
+	assert.Equal(t, "1500", money.Amount{Minor: 1500, Exponent: 0}.String())
+	assert.Equal(t, "-9223372036854775.808", money.Amount{Minor: math.MinInt64, Exponent: 3}.String())
+
+	data, err := json.Marshal(money.Amount{Minor: 29990, Exponent: 2})
+	require.NoError(t, err)
+	assert.Equal(t, `"299.90"`, string(data))
+
+	// 1000.00 over three months.
+	assert.Equal(t, money.Amount{Minor: 33333, Exponent: 2}, money.Amount{Minor: 100000, Exponent: 2}.Div(3))
+	assert.Equal(t, money.Amount{Minor: 50, Exponent: 2}, money.Amount{Minor: 99, Exponent: 2}.Div(2))
+	assert.Equal(t, money.Amount{Minor: -50, Exponent: 2}, money.Amount{Minor: -99, Exponent: 2}.Div(2))
+
+	assert.Equal(t, int64(300), money.Amount{Minor: 29990, Exponent: 2}.Major())
+	assert.Equal(t, int64(299), money.Amount{Minor: 29949, Exponent: 2}.Major())
+	assert.Equal(t, 299.9, money.Amount{Minor: 29990, Exponent: 2}.Float64())
+
+	assert.Equal(t, 0, money.Amount{Minor: 1500, Exponent: 0}.Cmp(money.Amount{Minor: 150000, Exponent: 2}))
+	assert.Equal(t, -1, money.Amount{Minor: 1, Exponent: 3}.Cmp(money.Amount{Minor: 1, Exponent: 2}))
+
+	rescaled, err := money.Amount{Minor: 150000, Exponent: 2}.Rescale(0)
+	require.NoError(t, err)
+	assert.Equal(t, money.Amount{Minor: 1500, Exponent: 0}, rescaled)
+	_, err = money.Amount{Minor: 29990, Exponent: 2}.Rescale(0)
+	assert.ErrorIs(t, err, money.ErrPrecision)
+}
+
+func TestExponent(t *testing.T) {
+	assert.Equal(t, 2, money.Exponent("RUB"))
+	assert.Equal(t, 2, money.Exponent("USD"))
+	assert.Equal(t, 0, money.Exponent("JPY"))
+	assert.Equal(t, 3, money.Exponent("KWD"))
+}
+
+func TestDecimalJSON(t *testing.T) {
+	var v struct {
+		Price money.Decimal `json:"price"`
+	}
+
+	for body, want := range map[string]money.Decimal{
+		`{"price": 299.90}`:   "299.90",
+		`{"price": "299.90"}`: "299.90",
+		`{"price": 400}`:      "400",
+		`{"price": null}`:     "",
+	} {
+		v.Price = ""
+		require.NoError(t, json.Unmarshal([]byte(body), &v), body)
+		assert.Equal(t, want, v.Price, body)
+	}
+
+	for _, body := range []string{`{"price": 1e3}`, `{"price": "abc"}`, `{"price": true}`} {
+		assert.Error(t, json.Unmarshal([]byte(body), &v), body)
+	}
+
+	data, err := json.Marshal(struct {
+		Price money.Decimal `json:"price"`
+	}{Price: "299.90"})
+	require.NoError(t, err)
+	assert.Equal(t, `{"price":299.90}`, string(data))
+}
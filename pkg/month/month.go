@@ -0,0 +1,16 @@
+package month
+
+import "time"
+
+// Layout is the MM-YYYY format in which the API accepts and returns months.
+const Layout = "01-2006"
+
+// Parse returns the first day of the month described by value.
+func Parse(value string) (time.Time, error) {
+	return time.Parse(Layout, value)
+}
+
+// Format renders t as MM-YYYY.
+func Format(t time.Time) string {
+	return t.Format(Layout)
+}
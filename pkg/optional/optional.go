@@ -0,0 +1,64 @@
+package optional
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// Value is a JSON Merge Patch field: it is either absent, explicitly null or
+// set to a value.
+type Value[T any] struct {
+	Set   bool
+	Null  bool
+	Value T
+}
+
+// Of returns a Value set to v.
+func Of[T any](v T) Value[T] {
+	return Value[T]{Set: true, Value: v}
+}
+
+// Null returns a Value that is explicitly null.
+func Null[T any]() Value[T] {
+	return Value[T]{Set: true, Null: true}
+}
+
+// FromPtr returns a Value set to *p, or an explicit null when p is nil.
+func FromPtr[T any](p *T) Value[T] {
+	if p == nil {
+		return Null[T]()
+	}
+	return Of(*p)
+}
+
+// Ptr returns a pointer to the value, or nil when it is absent or null.
+func (o Value[T]) Ptr() *T {
+	if !o.Set || o.Null {
+		return nil
+	}
+	v := o.Value
+	return &v
+}
+
+// Type returns the type of the wrapped value.
+func (Value[T]) Type() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// UnmarshalJSON is only called for keys present in the document, so a Value
+// that was not touched stays absent.
+func (o *Value[T]) UnmarshalJSON(data []byte) error {
+	*o = Value[T]{Set: true}
+	if string(data) == "null" {
+		o.Null = true
+		return nil
+	}
+	return json.Unmarshal(data, &o.Value)
+}
+
+func (o Value[T]) MarshalJSON() ([]byte, error) {
+	if !o.Set || o.Null {
+		return []byte("null"), nil
+	}
+	return json.Marshal(o.Value)
+}
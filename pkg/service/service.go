@@ -2,22 +2,33 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
 	"reflect"
+	"syscall"
 )
 
 type (
+	// Service is a unit managed by Manager. Init prepares resources and must
+	// not block; Run does the work and blocks until ctx is cancelled or Stop
+	// is called. A non-nil error from Run means the service has failed.
+	// Health reports whether the service is able to do its work right now.
 	Service interface {
 		Init() error
-		Run(ctx context.Context)
+		Run(ctx context.Context) error
 		Stop()
+		HealthChecker
+	}
+	HealthChecker interface {
+		Health(ctx context.Context) error
 	}
 	Services interface {
 		AddService(service ...Service)
 		Run(ctx context.Context) error
+		HealthChecker
 	}
 	Manager struct {
 		services []Service
@@ -33,43 +44,73 @@ func (s *Manager) AddService(service ...Service) {
 	s.services = append(s.services, service...)
 }
 
-func (s *Manager) Run(ctx context.Context) (err error) {
+// Run initializes and runs all services and blocks until a termination
+// signal arrives, ctx is done or one of the services fails. The error of the
+// failed service is returned so that the caller can exit with a non-zero code.
+func (s *Manager) Run(ctx context.Context) error {
 	s.log.Info("going to start services")
-	
-	defer func() {
-		if err != nil {
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for i, service := range s.services {
+		if err := service.Init(); err != nil {
+			err = fmt.Errorf("failed to init %s: %w", reflect.TypeOf(service), err)
 			s.log.Error("an error occurred", "err", err)
-			s.stop()
+			s.stop(s.services[:i])
+			return err
 		}
-	}()
+	}
 
+	errCh := make(chan error, len(s.services))
 	for _, service := range s.services {
-		if err = service.Init(); err != nil {
-			return fmt.Errorf("failed to run %s: %w", reflect.TypeOf(service), err)
-		}
-		go service.Run(ctx)
+		go func(service Service) {
+			if err := service.Run(ctx); err != nil {
+				errCh <- fmt.Errorf("failed to run %s: %w", reflect.TypeOf(service), err)
+			}
+		}(service)
 	}
 
 	s.log.Info("the worker has been initialized")
 
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(c)
 
+	var err error
 	select {
-	case <-c:
-		s.log.Info("received an interrupt signal, stopping services")
+	case sig := <-c:
+		s.log.Info("received a termination signal, stopping services", "signal", sig.String())
 	case <-ctx.Done():
 		s.log.Info("context done, stopping services")
+	case err = <-errCh:
+		s.log.Error("an error occurred", "err", err)
 	}
 
-	s.stop()
+	cancel()
+	s.stop(s.services)
 
-	return nil
+	return err
 }
 
-func (s *Manager) stop() {
-	s.log.Info("going to stop")
+// Health combines the health of all services into one answer: nil when every
+// service is healthy, otherwise the joined errors of the unhealthy ones.
+func (s *Manager) Health(ctx context.Context) error {
+	var errs []error
 	for _, service := range s.services {
-		service.Stop()
+		if err := service.Health(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", reflect.TypeOf(service), err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// stop shuts services down in reverse order of AddService, so that a
+// service is stopped before the dependencies it was registered after.
+func (s *Manager) stop(services []Service) {
+	s.log.Info("going to stop")
+	for i := len(services) - 1; i >= 0; i-- {
+		services[i].Stop()
 	}
 }
@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"github.com/azaliaz/subs-api/pkg/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeService struct {
+	name      string
+	initErr   error
+	runErr    error
+	healthErr error
+	block     bool
+
+	mu     *sync.Mutex
+	events *[]string
+}
+
+func (f *fakeService) record(event string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	*f.events = append(*f.events, f.name+":"+event)
+}
+
+func (f *fakeService) Init() error {
+	f.record("init")
+	return f.initErr
+}
+
+func (f *fakeService) Run(ctx context.Context) error {
+	if f.runErr != nil {
+		return f.runErr
+	}
+	if f.block {
+		<-ctx.Done()
+	}
+	return nil
+}
+
+func (f *fakeService) Stop() {
+	f.record("stop")
+}
+
+func (f *fakeService) Health(_ context.Context) error {
+	return f.healthErr
+}
+
+func newFakes(names ...string) ([]*fakeService, *[]string) {
+	mu := &sync.Mutex{}
+	events := &[]string{}
+	fakes := make([]*fakeService, 0, len(names))
+	for _, name := range names {
+		fakes = append(fakes, &fakeService{name: name, mu: mu, events: events})
+	}
+	return fakes, events
+}
+
+func TestManager_StopsInReverseOrder(t *testing.T) {
+	fakes, events := newFakes("db", "app", "api")
+	fakes[2].block = true
+
+	mgr := service.NewManager(slog.Default())
+	mgr.AddService(fakes[0], fakes[1], fakes[2])
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	require.NoError(t, mgr.Run(ctx))
+	assert.Equal(t, []string{
+		"db:init", "app:init", "api:init",
+		"api:stop", "app:stop", "db:stop",
+	}, *events)
+}
+
+func TestManager_RunFailureIsReported(t *testing.T) {
+	fakes, events := newFakes("db", "api")
+	fakes[0].block = true
+	bindErr := errors.New("address already in use")
+	fakes[1].runErr = bindErr
+
+	mgr := service.NewManager(slog.Default())
+	mgr.AddService(fakes[0], fakes[1])
+
+	done := make(chan error, 1)
+	go func() { done <- mgr.Run(context.Background()) }()
+
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, bindErr)
+	case <-time.After(time.Second):
+		t.Fatal("manager did not stop after a service failure")
+	}
+	assert.Equal(t, []string{"db:init", "api:init", "api:stop", "db:stop"}, *events)
+}
+
+func TestManager_InitFailureStopsInitializedServices(t *testing.T) {
+	fakes, events := newFakes("db", "app", "api")
+	initErr := errors.New("connection refused")
+	fakes[1].initErr = initErr
+
+	mgr := service.NewManager(slog.Default())
+	mgr.AddService(fakes[0], fakes[1], fakes[2])
+
+	err := mgr.Run(context.Background())
+	assert.ErrorIs(t, err, initErr)
+	assert.Equal(t, []string{"db:init", "app:init", "db:stop"}, *events)
+}
+
+func TestManager_Health(t *testing.T) {
+	fakes, _ := newFakes("db", "api")
+
+	mgr := service.NewManager(slog.Default())
+	mgr.AddService(fakes[0], fakes[1])
+
+	require.NoError(t, mgr.Health(context.Background()))
+
+	pingErr := errors.New("connection reset")
+	fakes[0].healthErr = pingErr
+
+	err := mgr.Health(context.Background())
+	assert.ErrorIs(t, err, pingErr)
+	assert.Contains(t, err.Error(), "fakeService")
+}
@@ -0,0 +1,15 @@
+package tracing
+
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+type Config struct {
+	Exporter     string  `env:"EXPORTER" envDefault:"none" yaml:"exporter"`
+	OTLPEndpoint string  `env:"OTLP_ENDPOINT" envDefault:"localhost:4318" yaml:"otlp-endpoint"`
+	OTLPInsecure bool    `env:"OTLP_INSECURE" yaml:"otlp-insecure"`
+	ServiceName  string  `env:"SERVICE_NAME" envDefault:"subs-api" yaml:"service-name"`
+	SampleRatio  float64 `env:"SAMPLE_RATIO" envDefault:"1" yaml:"sample-ratio"`
+}
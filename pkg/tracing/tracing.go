@@ -0,0 +1,112 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+	"log/slog"
+	"time"
+)
+
+const shutdownTimeout = 5 * time.Second
+
+// Provider installs the global tracer provider and W3C propagators. It is a
+// service.Service so that the manager flushes pending spans on shutdown.
+type Provider struct {
+	config   *Config
+	log      *slog.Logger
+	provider *sdktrace.TracerProvider
+}
+
+func NewProvider(config *Config, logger *slog.Logger) *Provider {
+	return &Provider{
+		config: config,
+		log:    logger,
+	}
+}
+
+func (p *Provider) Init() error {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch p.config.Exporter {
+	case "", ExporterNone:
+		p.log.Info("tracing exporter is disabled")
+		return nil
+	case ExporterStdout:
+		exporter, err = stdouttrace.New()
+	case ExporterOTLP:
+		opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(p.config.OTLPEndpoint)}
+		if p.config.OTLPInsecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(context.Background(), opts...)
+	default:
+		return fmt.Errorf("unknown tracing exporter %q", p.config.Exporter)
+	}
+	if err != nil {
+		return fmt.Errorf("error on creating %s trace exporter: %w", p.config.Exporter, err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(p.config.ServiceName),
+	))
+	if err != nil {
+		return fmt.Errorf("error on creating trace resource: %w", err)
+	}
+
+	p.provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(p.config.SampleRatio))),
+	)
+	otel.SetTracerProvider(p.provider)
+
+	p.log.Info("tracing is enabled", "exporter", p.config.Exporter)
+	return nil
+}
+
+func (p *Provider) Run(_ context.Context) error {
+	return nil
+}
+
+func (p *Provider) Health(_ context.Context) error {
+	return nil
+}
+
+func (p *Provider) Stop() {
+	if p.provider == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := p.provider.Shutdown(ctx); err != nil {
+		p.log.Error("failed to flush spans", "error", err)
+		return
+	}
+	p.log.Info("tracing has been stopped")
+}
+
+// End records err on the span, if any, and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}